@@ -13,6 +13,13 @@
 // a fetch function in a single batched RPC. Independent calls to Func.Invoke
 // get automatically combined into a single call to the user-supplied Func.Many,
 // resulting in only a single RPC with minimal changes to resolver code.
+//
+// The graphql executor marks the goroutines that resolve expensive fields
+// with Working, so a batch runs as soon as every such goroutine waits for a
+// batch, rather than only once its WaitInterval passes. For example, the
+// resolver of a post's author can call Invoke with the post's author ID, and
+// resolving a list of 100 posts fetches all their authors in one call to
+// Many.
 package batch
 
 import (
@@ -65,6 +72,9 @@ type batchGroup struct {
 	maxSizeCh chan struct{}
 	// intervalTimer is a timer that is reset whenever the batch fn is invoked.
 	intervalTimer *time.Timer
+	// flushCh is a 0-sized channel that is closed to run the batch right away,
+	// once no goroutine marked by Working can add to it.
+	flushCh chan struct{}
 	// doneCh is a 0-sized channel that is closed once result and err are set.
 	doneCh chan struct{}
 	// result is an array of len(args) values with the result of the Func.
@@ -83,6 +93,42 @@ type funcShard struct {
 type batchContext struct {
 	mu                 sync.Mutex
 	pendingBatchGroups map[funcShard]*batchGroup
+	// working counts the goroutines marked by Working that are not waiting
+	// for a batch.
+	working int
+}
+
+// stopWorking records that a goroutine marked by Working stopped working.
+// Once none is working, no more arguments can be added to the pending
+// batches, so they are all run. bctx.mu must be held.
+func (bctx *batchContext) stopWorking() {
+	bctx.working--
+	if bctx.working > 0 {
+		return
+	}
+	for fs, bg := range bctx.pendingBatchGroups {
+		delete(bctx.pendingBatchGroups, fs)
+		close(bg.flushCh)
+	}
+}
+
+// wait calls f, which waits for a batch. If ctx is marked by Working, the
+// calling goroutine is not counted as working while it waits.
+func (bctx *batchContext) wait(ctx context.Context, f func()) {
+	if ctx.Value(workingKey{}) == nil {
+		f()
+		return
+	}
+
+	bctx.mu.Lock()
+	bctx.stopWorking()
+	bctx.mu.Unlock()
+
+	f()
+
+	bctx.mu.Lock()
+	bctx.working++
+	bctx.mu.Unlock()
 }
 
 // batchContextKey is a context.Value key used for type *batchContext.
@@ -100,6 +146,54 @@ func WithBatching(ctx context.Context) context.Context {
 	return context.WithValue(ctx, batchContextKey{}, bctx)
 }
 
+// waitIntervalKey is a context.Value key used for a time.Duration that
+// overrides Func.WaitInterval.
+type waitIntervalKey struct{}
+
+// WithWaitInterval overrides the WaitInterval of every Func invoked with the
+// returned context, or a context derived from it. A zero interval invokes Many
+// as soon as possible, which suits latency-sensitive requests; a larger one
+// collects bigger batches for throughput-oriented requests. MaxDuration still
+// bounds every batch.
+//
+// For example, a graphql middleware can pick the interval from a request
+// header by replacing its ComputationInput's Ctx.
+func WithWaitInterval(ctx context.Context, interval time.Duration) context.Context {
+	return context.WithValue(ctx, waitIntervalKey{}, interval)
+}
+
+// workingKey is a context.Value key marking contexts returned by Working.
+type workingKey struct{}
+
+// Working marks the calling goroutine as working on the batching context
+// ctx, until done is called, and returns the context it must invoke Funcs
+// with. As long as some marked goroutine is working, its invocations might
+// join the pending batches; once all of them are done or waiting for a
+// batch, the pending batches run right away rather than waiting for their
+// WaitInterval.
+//
+// To avoid missing invocations, Working must be called before starting the
+// goroutine it marks. Working does nothing if ctx has no batching support.
+func Working(ctx context.Context) (context.Context, func()) {
+	bctx, ok := ctx.Value(batchContextKey{}).(*batchContext)
+	if !ok {
+		return ctx, func() {}
+	}
+
+	bctx.mu.Lock()
+	bctx.working++
+	bctx.mu.Unlock()
+
+	var once sync.Once
+	return context.WithValue(ctx, workingKey{}, true), func() {
+		once.Do(func() {
+			bctx.mu.Lock()
+			bctx.stopWorking()
+			bctx.mu.Unlock()
+		})
+	}
+}
+
 // HasBatching returns if the given context has batching support.
 func HasBatching(ctx context.Context) bool {
 	return ctx.Value(batchContextKey{}) != nil
@@ -143,7 +237,9 @@ func (f *Func) Invoke(ctx context.Context, arg interface{}) (interface{}, error)
 	}
 
 	waitInterval := DefaultWaitInterval
-	if f.WaitInterval > 0 {
+	if interval, ok := ctx.Value(waitIntervalKey{}).(time.Duration); ok {
+		waitInterval = interval
+	} else if f.WaitInterval > 0 {
 		waitInterval = f.WaitInterval
 	}
 
@@ -154,7 +250,8 @@ func (f *Func) Invoke(ctx context.Context, arg interface{}) (interface{}, error)
 	if !existed {
 		// If none, create a new one.
 		bg = &batchGroup{
-			doneCh: make(chan struct{}, 0),
+			flushCh: make(chan struct{}, 0),
+			doneCh:  make(chan struct{}, 0),
 		}
 		if f.MaxSize > 0 {
 			bg.maxSizeCh = make(chan struct{}, 0)
@@ -200,12 +297,15 @@ func (f *Func) Invoke(ctx context.Context, arg interface{}) (interface{}, error)
 	// finish.
 	if !existed {
 		// Wait for a trigger to run the batchGroup.
-		select {
-		case <-bg.intervalTimer.C: // Resolve if the interval timer expires.
-		case <-ctx.Done(): // Resolve if the context is canceled.
-		case <-timer.C: // Resolve after a timeout to bound latency.
-		case <-bg.maxSizeCh: // Resolve if we hit max batch size.
-		}
+		bctx.wait(ctx, func() {
+			select {
+			case <-bg.intervalTimer.C: // Resolve if the interval timer expires.
+			case <-ctx.Done(): // Resolve if the context is canceled.
+			case <-timer.C: // Resolve after a timeout to bound latency.
+			case <-bg.maxSizeCh: // Resolve if we hit max batch size.
+			case <-bg.flushCh: // Resolve if no one else can join the batch.
+			}
+		})
 
 		// Before we try and resolve, make sure noone will add to the group by
 		// deleting it from the pending groups.
@@ -227,9 +327,11 @@ func (f *Func) Invoke(ctx context.Context, arg interface{}) (interface{}, error)
 		close(bg.doneCh)
 
 	} else {
-		concurrencylimiter.TemporarilyRelease(ctx, func() {
-			// Wait for the result.
-			<-bg.doneCh
+		bctx.wait(ctx, func() {
+			concurrencylimiter.TemporarilyRelease(ctx, func() {
+				// Wait for the result.
+				<-bg.doneCh
+			})
 		})
 	}
 
@@ -115,6 +115,71 @@ func TestWaitInterval(t *testing.T) {
 	}
 }
 
+// TestWithWaitInterval tests that a context's wait interval overrides the
+// Func's.
+func TestWithWaitInterval(t *testing.T) {
+	f := (&batch.Func{
+		WaitInterval: time.Second,
+		MaxDuration:  time.Minute,
+		Many: func(ctx context.Context, args []interface{}) ([]interface{}, error) {
+			return args, nil
+		},
+	}).Invoke
+
+	ctx := batch.WithWaitInterval(batch.WithBatching(context.Background()), 0)
+
+	start := time.Now()
+	if result, err := f(ctx, 1); err != nil || result != 1 {
+		t.Error(err, result)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("expected Many to run without waiting, but it took %v", elapsed)
+	}
+}
+
+// TestWorking tests that a batch runs as soon as every goroutine marked by
+// Working waits for it.
+func TestWorking(t *testing.T) {
+	var mu sync.Mutex
+	calls := 0
+	f := (&batch.Func{
+		WaitInterval: time.Minute,
+		MaxDuration:  time.Minute,
+		Many: func(ctx context.Context, args []interface{}) ([]interface{}, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			calls++
+			return args, nil
+		},
+	}).Invoke
+
+	// Like the graphql executor, stay working until every goroutine started.
+	ctx, starting := batch.Working(batch.WithBatching(context.Background()))
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		ctx, done := batch.Working(ctx)
+		go func(i int) {
+			defer wg.Done()
+			defer done()
+			if result, err := f(ctx, i); err != nil || result != i {
+				t.Error(err, i)
+			}
+		}(i)
+	}
+	starting()
+	wg.Wait()
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected Many to run once every goroutine waited, but it took %v", elapsed)
+	}
+	if calls != 1 {
+		t.Error(calls)
+	}
+}
+
 // TestBackToBack tests that two back-to-back invocations of batch.Func from
 // multiple goroutines get batched in a total of two calls.
 func TestBackToBack(t *testing.T) {
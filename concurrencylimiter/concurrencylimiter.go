@@ -102,6 +102,31 @@ func Acquire(ctx context.Context) (context.Context, ReleaseFunc) {
 		return ctx, func() {}
 	}
 
+	return l.hold(ctx)
+}
+
+// TryAcquire acquires a concurrency limiter token like Acquire if one is
+// available without waiting, and otherwise returns false. If there is no
+// concurrency limit associated with the context it succeeds immediately.
+func TryAcquire(ctx context.Context) (context.Context, ReleaseFunc, bool) {
+	l, ok := ctx.Value(limiterKey{}).(*limiter)
+	if !ok {
+		return ctx, func() {}, true
+	}
+
+	select {
+	case l.ch <- struct{}{}:
+	default:
+		return ctx, nil, false
+	}
+
+	ctx, release := l.hold(ctx)
+	return ctx, release, true
+}
+
+// hold returns a context holding the token just written to l.ch, and the
+// function that releases it.
+func (l *limiter) hold(ctx context.Context) (context.Context, ReleaseFunc) {
 	h := &holder{
 		l:      l,
 		status: acquired,
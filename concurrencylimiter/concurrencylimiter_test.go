@@ -239,3 +239,23 @@ func TestAcquireReleaseNoLimiter(t *testing.T) {
 	ctx, release := concurrencylimiter.Acquire(ctx)
 	release()
 }
+
+// TestTryAcquire tests that TryAcquire only succeeds while a token is
+// available.
+func TestTryAcquire(t *testing.T) {
+	ctx := concurrencylimiter.With(context.Background(), 1)
+
+	_, release, ok := concurrencylimiter.TryAcquire(ctx)
+	assert.True(t, ok)
+	_, _, ok = concurrencylimiter.TryAcquire(ctx)
+	assert.False(t, ok)
+
+	release()
+	_, release, ok = concurrencylimiter.TryAcquire(ctx)
+	assert.True(t, ok)
+	release()
+
+	_, release, ok = concurrencylimiter.TryAcquire(context.Background())
+	assert.True(t, ok)
+	release()
+}
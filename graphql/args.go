@@ -0,0 +1,145 @@
+package graphql
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/samsarahq/thunder/internal"
+)
+
+// This file contains the default argument parser used by fields that don't
+// provide their own ParseArguments. It coerces JSON argument values according
+// to the field's declared argument types.
+
+// parseArguments coerces the JSON args of a selection according to the
+// argument types in args.
+func parseArguments(args map[string]Type, json interface{}) (interface{}, error) {
+	asMap, ok := json.(map[string]interface{})
+	if json != nil && !ok {
+		return nil, errors.New("not an object")
+	}
+
+	parsed := make(map[string]interface{}, len(args))
+	invalid := make(map[string]error)
+	for name, typ := range args {
+		value, err := coerceValue(typ, asMap[name])
+		if err != nil {
+			invalid[name] = err
+			continue
+		}
+		if value != nil {
+			parsed[name] = value
+		}
+	}
+	var unknown []string
+	for name := range asMap {
+		if _, ok := args[name]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+
+	if err := internal.ArgsError(invalid, unknown); err != nil {
+		return nil, err
+	}
+	return parsed, nil
+}
+
+// withDefaults returns args with defaults filled in for absent arguments.
+// An argument that is present but null is left null.
+func withDefaults(args map[string]interface{}, defaults map[string]interface{}) map[string]interface{} {
+	if len(defaults) == 0 {
+		return args
+	}
+	merged := make(map[string]interface{}, len(args)+len(defaults))
+	for name, value := range defaults {
+		merged[name] = value
+	}
+	for name, value := range args {
+		merged[name] = value
+	}
+	return merged
+}
+
+// checkDefaults checks that the Defaults of typ are values of their input
+// fields' types.
+func checkDefaults(typ *InputObject) error {
+	names := make([]string, 0, len(typ.Defaults))
+	for name := range typ.Defaults {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fieldType, ok := typ.InputFields[name]
+		if !ok {
+			return fmt.Errorf("input object %s has a default for unknown field %s", typ.Name, name)
+		}
+		if _, err := coerceValue(fieldType, typ.Defaults[name]); err != nil {
+			return fmt.Errorf("input object %s has a bad default for field %s: %s", typ.Name, name, err)
+		}
+	}
+	return nil
+}
+
+// coerceValue converts a JSON value into the value expected for an input of
+// type typ.
+func coerceValue(typ Type, value interface{}) (interface{}, error) {
+	if nonNull, ok := typ.(*NonNull); ok {
+		if value == nil {
+			return nil, errors.New("required value is missing")
+		}
+		return coerceValue(nonNull.Type, value)
+	}
+	if value == nil {
+		return nil, nil
+	}
+
+	switch typ := typ.(type) {
+	case *Scalar:
+		if typ.ParseValue != nil {
+			return typ.ParseValue(value)
+		}
+		if parse := lookupScalarCodec(typ.Type).parse; parse != nil {
+			return parse(value)
+		}
+		return value, nil
+
+	case *Enum:
+		asString, ok := value.(string)
+		if !ok {
+			return nil, errors.New("not a string")
+		}
+		enumValue, err := typ.MatchValue(asString)
+		if err != nil {
+			return nil, err
+		}
+		return enumValue, nil
+
+	case *List:
+		asSlice, ok := value.([]interface{})
+		if !ok {
+			// A single value is accepted as a list of one.
+			asSlice = []interface{}{value}
+		}
+		list := make([]interface{}, len(asSlice))
+		for i, item := range asSlice {
+			coerced, err := coerceValue(typ.Type, item)
+			if err != nil {
+				return nil, fmt.Errorf("%d: %s", i, err)
+			}
+			list[i] = coerced
+		}
+		return list, nil
+
+	case *InputObject:
+		asMap, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("not an object")
+		}
+		return parseArguments(typ.InputFields, withDefaults(asMap, typ.Defaults))
+
+	default:
+		return nil, fmt.Errorf("%s is not an input type", typ)
+	}
+}
@@ -1,7 +1,15 @@
 package graphql
 
-import "fmt"
+import (
+	"fmt"
+	"sync"
+)
 
+// await replaces the thunks in value with their results.
+//
+// Maps and slices are updated in place. The results of thunks can be shared
+// between goroutines through the reactive cache, so await only ever writes
+// the slots that held a thunk; a map or slice without thunks is only read.
 func await(value interface{}) (interface{}, error) {
 	switch value := value.(type) {
 	case *thunk:
@@ -9,20 +17,28 @@ func await(value interface{}) (interface{}, error) {
 
 	case map[string]interface{}:
 		for k, v := range value {
-			v, err := await(v)
+			awaited, err := await(v)
 			if err != nil {
 				return nil, nestPathError(k, err)
 			}
-			value[k] = v
+			if _, ok := v.(*thunk); ok {
+				if _, ok := awaited.(omittedField); ok {
+					delete(value, k)
+					continue
+				}
+				value[k] = awaited
+			}
 		}
 
 	case []interface{}:
 		for i, v := range value {
-			v, err := await(v)
+			awaited, err := await(v)
 			if err != nil {
 				return nil, nestPathError(fmt.Sprint(i), err)
 			}
-			value[i] = v
+			if _, ok := v.(*thunk); ok {
+				value[i] = awaited
+			}
 		}
 	}
 
@@ -33,6 +49,11 @@ type thunk struct {
 	value interface{}
 	err   error
 	done  chan struct{}
+
+	// lazy, if set, computes the thunk's result when it is first awaited,
+	// instead of in a goroutine.
+	lazy func() (interface{}, error)
+	once sync.Once
 }
 
 func fork(f func() (interface{}, error)) *thunk {
@@ -48,7 +69,19 @@ func fork(f func() (interface{}, error)) *thunk {
 	return t
 }
 
+// lazyThunk returns a thunk whose result is computed by f when it is first
+// awaited.
+func lazyThunk(f func() (interface{}, error)) *thunk {
+	return &thunk{lazy: f}
+}
+
 func (t *thunk) await() (interface{}, error) {
+	if t.lazy != nil {
+		t.once.Do(func() {
+			t.value, t.err = t.lazy()
+		})
+		return t.value, t.err
+	}
 	<-t.done
 	return t.value, t.err
 }
@@ -0,0 +1,206 @@
+package graphql
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+)
+
+// utf8BOM is the UTF-8 encoding of the byte order mark U+FEFF.
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// decodePostBody decodes a JSON POST body from r. The body is either a single
+// query or a batch of them in an array; batched reports which. Null or absent
+// variables are decoded as an empty map.
+//
+// Rather than buffering the body and then unmarshaling it, decodePostBody
+// walks the body's tokens and builds the variables as it reads them, so a
+// large body is never held in memory twice.
+//
+// A leading UTF-8 byte order mark, which some Windows clients send, is
+// skipped.
+func decodePostBody(r io.Reader) (operations []*httpPostBody, batched bool, err error) {
+	buffered := bufio.NewReader(r)
+	if prefix, err := buffered.Peek(len(utf8BOM)); err == nil && bytes.Equal(prefix, utf8BOM) {
+		buffered.Discard(len(utf8BOM))
+	}
+	dec := json.NewDecoder(buffered)
+
+	token, err := dec.Token()
+	if err != nil {
+		return nil, false, err
+	}
+	switch token {
+	case json.Delim('{'):
+		body, err := decodeOperation(dec)
+		if err != nil {
+			return nil, false, err
+		}
+		return []*httpPostBody{body}, false, nil
+
+	case json.Delim('['):
+		operations = []*httpPostBody{}
+		for dec.More() {
+			if err := expectDelim(dec, '{'); err != nil {
+				return nil, true, err
+			}
+			body, err := decodeOperation(dec)
+			if err != nil {
+				return nil, true, err
+			}
+			operations = append(operations, body)
+		}
+		if err := expectDelim(dec, ']'); err != nil {
+			return nil, true, err
+		}
+		return operations, true, nil
+
+	default:
+		return nil, false, fmt.Errorf("expected { or [, received %v", token)
+	}
+}
+
+// decodeOperation decodes a single query from dec, after its opening brace.
+func decodeOperation(dec *json.Decoder) (*httpPostBody, error) {
+	var body httpPostBody
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := token.(string)
+
+		value, err := decodeValue(dec)
+		if err != nil {
+			return nil, err
+		}
+
+		switch key {
+		case "query":
+			query, ok := value.(string)
+			if value != nil && !ok {
+				return nil, errors.New("query must be a string")
+			}
+			body.Query = query
+		case "variables":
+			variables, ok := value.(map[string]interface{})
+			if value != nil && !ok {
+				return nil, errors.New("variables must be an object")
+			}
+			body.Variables = variables
+		case "operationName":
+			operationName, ok := value.(string)
+			if value != nil && !ok {
+				return nil, errors.New("operationName must be a string")
+			}
+			body.OperationName = operationName
+		case "extensions":
+			extensions, ok := value.(map[string]interface{})
+			if value != nil && !ok {
+				return nil, errors.New("extensions must be an object")
+			}
+			body.Extensions = extensions
+		}
+	}
+
+	if err := expectDelim(dec, '}'); err != nil {
+		return nil, err
+	}
+	if body.Variables == nil {
+		body.Variables = make(map[string]interface{})
+	}
+	return &body, nil
+}
+
+// decodeGetParams decodes the query, variables, operation name, and
+// extensions of a GET request from its URL's query string. The variables and
+// extensions are URL-encoded JSON; absent variables are decoded as an empty
+// map.
+func decodeGetParams(values url.Values) (*httpPostBody, error) {
+	params := &httpPostBody{
+		Query:         values.Get("query"),
+		OperationName: values.Get("operationName"),
+		Variables:     make(map[string]interface{}),
+	}
+	if variables := values.Get("variables"); variables != "" {
+		var decoded interface{}
+		if err := json.Unmarshal([]byte(variables), &decoded); err != nil {
+			return nil, newValidationError("invalid variables: %s", err)
+		}
+		if decoded != nil {
+			object, ok := decoded.(map[string]interface{})
+			if !ok {
+				return nil, newValidationError("variables must be an object")
+			}
+			params.Variables = object
+		}
+	}
+	if extensions := values.Get("extensions"); extensions != "" {
+		if err := json.Unmarshal([]byte(extensions), &params.Extensions); err != nil {
+			return nil, newValidationError("invalid extensions: %s", err)
+		}
+	}
+	return params, nil
+}
+
+// expectDelim reads the next token from dec and checks that it is delim.
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("expected %s, received %v", delim, token)
+	}
+	return nil
+}
+
+// decodeValue reads the next JSON value from dec, producing the same values
+// as json.Unmarshal into an interface{}.
+func decodeValue(dec *json.Decoder) (interface{}, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch token {
+	case json.Delim('{'):
+		object := make(map[string]interface{})
+		for dec.More() {
+			token, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key, _ := token.(string)
+
+			if object[key], err = decodeValue(dec); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		return object, nil
+
+	case json.Delim('['):
+		list := []interface{}{}
+		for dec.More() {
+			value, err := decodeValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		return list, nil
+
+	default:
+		return token, nil
+	}
+}
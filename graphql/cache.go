@@ -0,0 +1,115 @@
+package graphql
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// A ResponseCache stores the serialized data of query responses for the HTTP
+// handler. Implementations must be safe for concurrent use.
+type ResponseCache interface {
+	// Get returns the response stored under key, if it has not expired.
+	Get(key string) ([]byte, bool)
+	// Set stores response under key for ttl.
+	Set(key string, response []byte, ttl time.Duration)
+}
+
+// responseCacheKey returns the key under which the response to query, as
+// parsed with its variables, is cached for the callers in scope. The key is
+// built from the query's selections rather than its text, so queries that
+// differ only in formatting, operation name, the order of their fields, or
+// in variables that give the same arguments share a response.
+func responseCacheKey(scope string, query *Query) (string, error) {
+	var normalized bytes.Buffer
+	if err := writeNormalized(&normalized, query.SelectionSet); err != nil {
+		return "", err
+	}
+	bytes, err := json.Marshal(struct {
+		Scope string `json:"scope"`
+		Kind  string `json:"kind"`
+		Query string `json:"query"`
+	}{scope, query.Kind, normalized.String()})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(bytes)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// writeNormalized writes selectionSet to buffer in a canonical form: its
+// selections sorted by alias, with their arguments as JSON, which sorts map
+// keys, followed by its fragments in sorted order.
+func writeNormalized(buffer *bytes.Buffer, selectionSet *SelectionSet) error {
+	if selectionSet == nil {
+		return nil
+	}
+
+	selections := append([]*Selection(nil), selectionSet.Selections...)
+	sort.SliceStable(selections, func(i, j int) bool {
+		return selections[i].Alias < selections[j].Alias
+	})
+	fragments := make([]string, 0, len(selectionSet.Fragments))
+	for _, fragment := range selectionSet.Fragments {
+		var normalized bytes.Buffer
+		fmt.Fprintf(&normalized, "...on %s", fragment.On)
+		if err := writeNormalized(&normalized, fragment.SelectionSet); err != nil {
+			return err
+		}
+		fragments = append(fragments, normalized.String())
+	}
+	sort.Strings(fragments)
+
+	buffer.WriteString("{")
+	for _, selection := range selections {
+		args, err := json.Marshal(selection.Args)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(buffer, "%s:%s(%s)", selection.Alias, selection.Name, args)
+		if err := writeNormalized(buffer, selection.SelectionSet); err != nil {
+			return err
+		}
+		buffer.WriteString(" ")
+	}
+	for _, fragment := range fragments {
+		buffer.WriteString(fragment)
+		buffer.WriteString(" ")
+	}
+	buffer.WriteString("}")
+	return nil
+}
+
+// executeCached sets output to the data of the cached response to the query
+// of input, if there is one. Otherwise, it sets output to the result of
+// execute, and caches it if the query succeeded in full.
+func (h *httpHandler) executeCached(input *ComputationInput, output *ComputationOutput, execute func() (interface{}, error)) {
+	key, err := responseCacheKey(h.cacheScope(input.Ctx), input.ParsedQuery)
+	if err != nil {
+		output.Error = err
+		return
+	}
+	if cached, ok := h.cache.Get(key); ok {
+		// Decode numbers as json.Number, so they are written back exactly
+		// as they were cached.
+		decoder := json.NewDecoder(bytes.NewReader(cached))
+		decoder.UseNumber()
+		var data interface{}
+		if err := decoder.Decode(&data); err == nil {
+			output.Current = data
+			return
+		}
+	}
+
+	output.Current, output.Error = execute()
+	if output.Error != nil || containsReader(output.Current) {
+		return
+	}
+	if data, err := json.Marshal(output.Current); err == nil {
+		h.cache.Set(key, data, h.cacheTTL)
+	}
+}
@@ -0,0 +1,134 @@
+package graphql
+
+// An Estimator estimates the cost of resolving a field, given its args as
+// parsed by the field's ParseArguments. If recurse is true, the cost of the
+// field's selections is added to cost; otherwise cost covers them as well.
+type Estimator func(args interface{}) (cost uint64, recurse bool, err error)
+
+// estimateComplexity estimates the cost of executing selectionSet against
+// typ, for Executor.MaxComplexity. Every field costs 1 unless it has an
+// Estimate, and the cost of the selections of a list field selected with a
+// numeric "first" argument is multiplied by it. A field selected under
+// several aliases is resolved once per alias, so it costs as much for each
+// alias. Estimation stops once the cost exceeds max.
+func estimateComplexity(typ Type, selectionSet *SelectionSet, max uint64) (uint64, error) {
+	switch t := typ.(type) {
+	case *NonNull:
+		return estimateComplexity(t.Type, selectionSet, max)
+	case *List:
+		return estimateComplexity(t.Type, selectionSet, max)
+	}
+	if selectionSet == nil {
+		return 0, nil
+	}
+
+	var total uint64
+	switch typ := typ.(type) {
+	case *Object:
+		for _, selection := range Flatten(selectionSet) {
+			if total > max {
+				break
+			}
+			field, ok := typ.Fields[selection.Name]
+			if !ok {
+				continue
+			}
+			cost, err := estimateField(field, selection, max)
+			if err != nil {
+				return 0, nestPathError(selection.Alias, err)
+			}
+			total = addCost(total, cost, max)
+		}
+	case *Interface:
+		// Like a union, the interface costs as much as its most expensive
+		// implementation.
+		for name, object := range typ.Types {
+			cost, err := estimateComplexity(object, applicableFragments(selectionSet, typ.Name, name), max)
+			if err != nil {
+				return 0, err
+			}
+			if cost > total {
+				total = cost
+			}
+		}
+	case *Union:
+		// Only one member's fragments apply, so the union costs as much as
+		// its most expensive member.
+		for name, object := range typ.Types {
+			member := &SelectionSet{}
+			for _, fragment := range selectionSet.Fragments {
+				if fragment.On == name {
+					member.Fragments = append(member.Fragments, fragment)
+				}
+			}
+			cost, err := estimateComplexity(object, member, max)
+			if err != nil {
+				return 0, err
+			}
+			if cost > total {
+				total = cost
+			}
+		}
+	}
+	return total, nil
+}
+
+// estimateField estimates the cost of resolving field for selection,
+// including its selections.
+func estimateField(field *Field, selection *Selection, max uint64) (uint64, error) {
+	cost, recurse := uint64(1), true
+	if field.Estimate != nil {
+		args, err := parseSelectionArgs(field, selection)
+		if err != nil {
+			return 0, err
+		}
+		if cost, recurse, err = field.Estimate(args); err != nil {
+			return 0, err
+		}
+	}
+	if !recurse {
+		return cost, nil
+	}
+
+	children, err := estimateComplexity(field.Type, selection.SelectionSet, max)
+	if err != nil {
+		return 0, err
+	}
+	if isListType(field.Type) {
+		if args, ok := selection.Args.(map[string]interface{}); ok {
+			if first, ok := args["first"].(float64); ok && first >= 0 {
+				children = mulCost(children, uint64(first), max)
+			}
+		}
+	}
+	return addCost(cost, children, max), nil
+}
+
+// isListType reports whether typ is a list, possibly non-null.
+func isListType(typ Type) bool {
+	if nonNull, ok := typ.(*NonNull); ok {
+		typ = nonNull.Type
+	}
+	_, ok := typ.(*List)
+	return ok
+}
+
+// addCost adds a and b, saturating at max+1 so that costs never overflow.
+func addCost(a, b, max uint64) uint64 {
+	if a > max || b > max || a+b > max {
+		return max + 1
+	}
+	return a + b
+}
+
+// mulCost multiplies a and b, saturating at max+1 so that costs never
+// overflow.
+func mulCost(a, b, max uint64) uint64 {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	if a > max || b > max || a > max/b {
+		return max + 1
+	}
+	return a * b
+}
@@ -0,0 +1,118 @@
+package graphql
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// A ResponseEncoder compresses HTTP responses with a content-coding such as
+// gzip or br.
+type ResponseEncoder struct {
+	// Encoding is the name of the content-coding, as used in the
+	// Accept-Encoding and Content-Encoding headers.
+	Encoding string
+	// NewWriter returns a writer that compresses its input into w.
+	NewWriter func(w io.Writer) io.WriteCloser
+}
+
+// GzipEncoder compresses responses with gzip.
+var GzipEncoder = ResponseEncoder{
+	Encoding: "gzip",
+	NewWriter: func(w io.Writer) io.WriteCloser {
+		return gzip.NewWriter(w)
+	},
+}
+
+// acceptedEncodings parses an Accept-Encoding header into the set of
+// content-codings the client accepts.
+func acceptedEncodings(header string) map[string]bool {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(params[0]))
+		if coding == "" {
+			continue
+		}
+
+		acceptable := true
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				q, err := strconv.ParseFloat(param[len("q="):], 64)
+				acceptable = err == nil && q > 0
+			}
+		}
+		accepted[coding] = acceptable
+	}
+	return accepted
+}
+
+// negotiateEncoder picks the first of encoders that r accepts. It returns nil
+// if the response should not be compressed.
+func negotiateEncoder(r *http.Request, encoders []ResponseEncoder) *ResponseEncoder {
+	if len(encoders) == 0 {
+		return nil
+	}
+
+	accepted := acceptedEncodings(r.Header.Get("Accept-Encoding"))
+	for i, encoder := range encoders {
+		acceptable, ok := accepted[encoder.Encoding]
+		if !ok {
+			acceptable = accepted["*"]
+		}
+		if acceptable {
+			return &encoders[i]
+		}
+	}
+	return nil
+}
+
+// thresholdWriter compresses a response with encoder, unless the response is
+// smaller than min bytes, in which case it is sent as is. It holds the first
+// min bytes of the response back until it knows which, and so also sends the
+// response's headers and status.
+type thresholdWriter struct {
+	w       http.ResponseWriter
+	status  int
+	encoder *ResponseEncoder
+	min     int
+
+	buffer []byte
+	// compressed is set once the response is known to be compressed.
+	compressed io.WriteCloser
+}
+
+func (t *thresholdWriter) Write(p []byte) (int, error) {
+	if t.compressed != nil {
+		return t.compressed.Write(p)
+	}
+
+	t.buffer = append(t.buffer, p...)
+	if len(t.buffer) < t.min {
+		return len(p), nil
+	}
+
+	t.w.Header().Set("Content-Encoding", t.encoder.Encoding)
+	t.w.WriteHeader(t.status)
+	t.compressed = t.encoder.NewWriter(t.w)
+	buffered := t.buffer
+	t.buffer = nil
+	if _, err := t.compressed.Write(buffered); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close finishes the response, sending it uncompressed if it is smaller than
+// min bytes.
+func (t *thresholdWriter) Close() error {
+	if t.compressed != nil {
+		return t.compressed.Close()
+	}
+	t.w.WriteHeader(t.status)
+	_, err := t.w.Write(t.buffer)
+	return err
+}
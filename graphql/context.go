@@ -0,0 +1,69 @@
+package graphql
+
+import (
+	"context"
+	"time"
+)
+
+type selectionSetKey struct{}
+
+// withSelectionSet returns a context that carries the selection set of the
+// field currently being resolved.
+func withSelectionSet(ctx context.Context, selectionSet *SelectionSet) context.Context {
+	return context.WithValue(ctx, selectionSetKey{}, selectionSet)
+}
+
+// SelectionSetFromContext returns the selection set of the field that is being
+// resolved with ctx. It lets helpers called from a resolver inspect what the
+// client selected without having the *SelectionSet threaded through to them.
+//
+// SelectionSetFromContext returns nil if ctx was not passed to a resolver by
+// the executor, or if the field being resolved has no subselection.
+func SelectionSetFromContext(ctx context.Context) *SelectionSet {
+	selectionSet, _ := ctx.Value(selectionSetKey{}).(*SelectionSet)
+	return selectionSet
+}
+
+type queryTimeKey struct{}
+
+// QueryTimeFromContext returns the time at which the executor started
+// executing the current query. Resolvers should use it instead of time.Now
+// so that every field in a query sees the same "now".
+//
+// QueryTimeFromContext returns the zero time if ctx was not created by the
+// executor.
+func QueryTimeFromContext(ctx context.Context) time.Time {
+	t, _ := ctx.Value(queryTimeKey{}).(time.Time)
+	return t
+}
+
+type pathKey struct{}
+
+// pathNode is a path through the query result, stored leaf-first.
+type pathNode struct {
+	parent *pathNode
+	key    string
+}
+
+// PathFromContext returns the path of the field that is being resolved with
+// ctx, starting at the root of the query, such as ["admin", "permissions"].
+// The path is made of the response keys of the field and its ancestors, and
+// the indices of list items, so a resolver can behave differently depending
+// on where in the query it is selected.
+//
+// An Expensive field's result is reused wherever the same selection of it is
+// resolved on the same source, so its resolver should not depend on the
+// path.
+//
+// PathFromContext returns nil if ctx was not passed to a resolver by the
+// executor.
+func PathFromContext(ctx context.Context) []string {
+	var path []string
+	for node, _ := ctx.Value(pathKey{}).(*pathNode); node != nil; node = node.parent {
+		path = append(path, node.key)
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
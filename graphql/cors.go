@@ -0,0 +1,108 @@
+package graphql
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSOptions configures how the HTTP handler answers cross-origin requests
+// from browsers. The zero value allows every origin, GET and POST requests,
+// and any request headers, which suits development.
+type CORSOptions struct {
+	// AllowedOrigins lists the origins, such as "https://example.com",
+	// allowed to send requests. Empty, or containing "*", allows any origin.
+	AllowedOrigins []string
+	// AllowedMethods lists the methods allowed in cross-origin requests.
+	// Empty allows GET and POST.
+	AllowedMethods []string
+	// AllowedHeaders lists the request headers allowed in cross-origin
+	// requests. Empty allows any the browser asks for.
+	AllowedHeaders []string
+	// AllowCredentials allows requests with cookies or HTTP authentication.
+	// It requires AllowedOrigins to list the allowed origins explicitly, so
+	// that arbitrary sites cannot send requests with a user's credentials.
+	AllowCredentials bool
+	// MaxAge, if non-zero, is how long browsers may cache the answer to a
+	// preflight request.
+	MaxAge time.Duration
+}
+
+// WithCORS answers cross-origin requests according to opts: OPTIONS
+// preflight requests are answered with a 204 No Content and the configured
+// Access-Control-Allow-* headers, and other requests from allowed origins get
+// the headers added to their responses.
+//
+// WithCORS panics if opts allow credentials from any origin, because a
+// browser would then send a user's cookies along with requests from every
+// site.
+func WithCORS(opts CORSOptions) HTTPOption {
+	if _, any := opts.allowsOrigin(""); any && opts.AllowCredentials {
+		panic("graphql: WithCORS requires explicit AllowedOrigins to allow credentials")
+	}
+	return func(h *httpHandler) {
+		h.cors = &opts
+	}
+}
+
+// allowsOrigin reports whether origin may send requests, and whether every
+// origin may.
+func (c *CORSOptions) allowsOrigin(origin string) (allowed bool, any bool) {
+	if len(c.AllowedOrigins) == 0 {
+		return true, true
+	}
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" {
+			return true, true
+		}
+		if strings.EqualFold(allowed, origin) {
+			return true, false
+		}
+	}
+	return false, false
+}
+
+// serveCORS adds the CORS headers for r to w, and reports whether r was a
+// preflight request, which it answers in full.
+func (c *CORSOptions) serveCORS(w http.ResponseWriter, r *http.Request) bool {
+	preflight := r.Method == "OPTIONS" && r.Header.Get("Access-Control-Request-Method") != ""
+	origin := r.Header.Get("Origin")
+
+	allowed, any := c.allowsOrigin(origin)
+	if origin != "" && allowed {
+		if any {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		} else {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Add("Vary", "Origin")
+		}
+		if c.AllowCredentials {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+	}
+	if !preflight {
+		return false
+	}
+
+	if origin != "" && allowed {
+		methods := c.AllowedMethods
+		if len(methods) == 0 {
+			methods = []string{"GET", "POST"}
+		}
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+
+		if len(c.AllowedHeaders) > 0 {
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(c.AllowedHeaders, ", "))
+		} else if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+			w.Header().Set("Access-Control-Allow-Headers", requested)
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+		}
+
+		if c.MaxAge > 0 {
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(c.MaxAge/time.Second)))
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
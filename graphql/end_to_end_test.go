@@ -2,12 +2,19 @@ package graphql_test
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io/ioutil"
 	"reflect"
+	"sort"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/kylelemons/godebug/pretty"
+	"github.com/samsarahq/thunder/batch"
 	"github.com/samsarahq/thunder/concurrencylimiter"
 	"github.com/samsarahq/thunder/graphql"
 	"github.com/samsarahq/thunder/graphql/schemabuilder"
@@ -79,10 +86,10 @@ func TestPathError(t *testing.T) {
 
 	e = graphql.Executor{}
 	_, err = e.Execute(context.Background(), builtSchema.Query, nil, q)
-	if err == nil || err.Error() != "safe safe" {
+	if err == nil || err.Error() != "safe: safe safe" {
 		t.Errorf("bad error: %v", err)
 	}
-	if _, ok := err.(graphql.SanitizedError); !ok {
+	if _, ok := graphql.ErrorCause(err).(graphql.SanitizedError); !ok {
 		t.Errorf("safe not safe")
 	}
 
@@ -446,3 +453,1015 @@ func TestConcurrencyLimiterDeadlock(t *testing.T) {
 	wg.Wait()
 	defer rerunner.Stop()
 }
+
+// TestSelectionSetFromContext tests that helpers called from a resolver can
+// read the resolver's selection set from its context.
+func TestSelectionSetFromContext(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+
+	// selected is a helper that does not receive the selection set directly.
+	selected := func(ctx context.Context) []string {
+		var names []string
+		for _, selection := range graphql.Flatten(graphql.SelectionSetFromContext(ctx)) {
+			names = append(names, selection.Name)
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	var names []string
+	query := schema.Query()
+	query.FieldFunc("user", func(ctx context.Context) *User {
+		names = selected(ctx)
+		return &User{Name: "Alice", Age: 5}
+	})
+	_ = schema.Mutation()
+
+	builtSchema := schema.MustBuild()
+
+	q := graphql.MustParse(`
+		{
+			user { name ... on User { age } }
+		}`, nil)
+	if err := graphql.PrepareQuery(builtSchema.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+
+	e := graphql.Executor{}
+	if _, err := e.Execute(context.Background(), builtSchema.Query, nil, q); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"age", "name"}, names)
+
+	assert.Nil(t, graphql.SelectionSetFromContext(context.Background()))
+}
+
+// TestPrepareQueries tests that PrepareQueries reports every query that does
+// not match the schema.
+func TestPrepareQueries(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+
+	query := schema.Query()
+	query.FieldFunc("user", func(args struct{ Id int64 }) *User {
+		return &User{Name: "Alice"}
+	})
+
+	mutation := schema.Mutation()
+	mutation.FieldFunc("rename", func(args struct{ Name string }) string {
+		return args.Name
+	})
+
+	builtSchema := schema.MustBuild()
+
+	queries, err := graphql.PrepareQueries(builtSchema, map[string]string{
+		"user":          `query User($id: int64!) { user(id: $id) { name } }`,
+		"rename":        `mutation Rename { rename(name: "Bob") }`,
+		"unknownField":  `{ user(id: 1) { email } }`,
+		"unknownArg":    `{ user(userId: 1) { name } }`,
+		"syntaxError":   `{ user(id: 1) { name }`,
+		"wrongRootType": `{ rename(name: "Bob") }`,
+	})
+
+	errs := err.(graphql.QueryErrors)
+	assert.Len(t, errs, 4)
+	assert.EqualError(t, errs["unknownField"], `unknown field "email"`)
+	assert.EqualError(t, errs["unknownArg"], `error parsing args for "user": unknown arg userId`)
+	assert.EqualError(t, errs["wrongRootType"], `unknown field "rename"`)
+	for name, err := range errs {
+		assert.Equal(t, graphql.ErrorCodeValidationFailed, graphql.FormatError(err).Extensions["code"], name)
+	}
+	assert.Contains(t, err.Error(), `unknownArg: error parsing args for "user": unknown arg userId; unknownField: unknown field "email"; wrongRootType`)
+
+	assert.Len(t, queries, 2)
+	assert.Equal(t, "User", queries["user"].Name)
+	assert.Equal(t, "mutation", queries["rename"].Kind)
+}
+
+// TestMultipleArgumentErrors tests that every invalid argument of a field is
+// reported at once.
+func TestMultipleArgumentErrors(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+
+	query := schema.Query()
+	query.FieldFunc("mirror", func(args struct {
+		Value int64
+		Other int64
+	}) int64 {
+		return -args.Value
+	})
+
+	builtSchema := schema.MustBuild()
+
+	q := graphql.MustParse(`{ mirror(value: "x", other: "y", extra: 1) }`, nil)
+	err := graphql.PrepareQuery(builtSchema.Query, q.SelectionSet)
+	assert.EqualError(t, err, `error parsing args for "mirror": other: not a number; value: not a number; unknown arg extra`)
+}
+
+// TestBatchedResolvers tests that the resolvers of a list's items that invoke
+// a batch.Func are batched into a single call, as soon as they all wait.
+func TestBatchedResolvers(t *testing.T) {
+	type Author struct {
+		Name string
+	}
+	type Post struct {
+		AuthorId int64
+	}
+
+	var mu sync.Mutex
+	var calls [][]interface{}
+	authors := &batch.Func{
+		Many: func(ctx context.Context, args []interface{}) ([]interface{}, error) {
+			mu.Lock()
+			calls = append(calls, args)
+			mu.Unlock()
+			results := make([]interface{}, len(args))
+			for i, arg := range args {
+				results[i] = &Author{Name: fmt.Sprint("author", arg)}
+			}
+			return results, nil
+		},
+		// Only batching on every resolver waiting makes the test fast.
+		WaitInterval: time.Minute,
+		MaxDuration:  time.Minute,
+	}
+
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("posts", func() []*Post {
+		posts := make([]*Post, 100)
+		for i := range posts {
+			posts[i] = &Post{AuthorId: int64(i)}
+		}
+		return posts
+	})
+	post := schema.Object("Post", Post{})
+	post.FieldFunc("author", func(ctx context.Context, p *Post) (*Author, error) {
+		author, err := authors.Invoke(ctx, p.AuthorId)
+		if err != nil {
+			return nil, err
+		}
+		return author.(*Author), nil
+	})
+	_ = schema.Mutation()
+	builtSchema := schema.MustBuild()
+
+	q := graphql.MustParse(`{ posts { author { name } } }`, nil)
+	if err := graphql.PrepareQuery(builtSchema.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	e := graphql.Executor{}
+	result, err := e.Execute(batch.WithBatching(context.Background()), builtSchema.Query, nil, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, time.Since(start) < time.Second)
+
+	if assert.Len(t, calls, 1) {
+		assert.Len(t, calls[0], 100)
+	}
+	posts := result.(map[string]interface{})["posts"].([]interface{})
+	assert.Len(t, posts, 100)
+	assert.Equal(t, map[string]interface{}{"author": map[string]interface{}{"name": "author42"}}, posts[42])
+}
+
+// TestQueryTimeFromContext tests that every resolver in a query sees the same
+// query time.
+func TestQueryTimeFromContext(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+
+	var mu sync.Mutex
+	var times []time.Time
+	query := schema.Query()
+	query.FieldFunc("now", func(ctx context.Context) int64 {
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		times = append(times, graphql.QueryTimeFromContext(ctx))
+		mu.Unlock()
+		return graphql.QueryTimeFromContext(ctx).UnixNano()
+	})
+	_ = schema.Mutation()
+
+	builtSchema := schema.MustBuild()
+
+	q := graphql.MustParse(`{ a: now b: now c: now }`, nil)
+	if err := graphql.PrepareQuery(builtSchema.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	e := graphql.Executor{}
+	result, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, times, 3)
+	for _, queryTime := range times {
+		assert.Equal(t, times[0], queryTime)
+	}
+	assert.False(t, times[0].Before(start))
+	assert.Equal(t, map[string]interface{}{
+		"a": times[0].UnixNano(),
+		"b": times[0].UnixNano(),
+		"c": times[0].UnixNano(),
+	}, result)
+
+	assert.True(t, graphql.QueryTimeFromContext(context.Background()).IsZero())
+}
+
+// TestPreparedQueryReuse tests that a prepared query is left unmodified, and
+// can be executed concurrently.
+func TestPreparedQueryReuse(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+
+	query := schema.Query()
+	query.FieldFunc("mirror", func(args struct{ Value int64 }) int64 {
+		return -args.Value
+	})
+
+	builtSchema := schema.MustBuild()
+
+	q := graphql.MustParse(`{ a: mirror(value: 1) ...F } fragment F on Query { b: mirror(value: 2) }`, nil)
+	for i := 0; i < 2; i++ {
+		if err := graphql.PrepareQuery(builtSchema.Query, q.SelectionSet); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assert.Equal(t, map[string]interface{}{"value": float64(1)}, q.SelectionSet.Selections[0].Args)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			e := graphql.Executor{}
+			result, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+			assert.NoError(t, err)
+			assert.Equal(t, map[string]interface{}{"a": int64(-1), "b": int64(-2)}, result)
+		}()
+	}
+	wg.Wait()
+}
+
+func TestObjectFromStruct(t *testing.T) {
+	type Address struct {
+		City string
+	}
+	type Person struct {
+		Name     string
+		Age      int32
+		Nickname *string
+		Tags     []string
+		Home     Address
+		Work     *Address `graphql:"office"`
+		Secret   string   `graphql:"-"`
+		internal string
+	}
+
+	object, err := graphql.ObjectFromStruct("Person", Person{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := graphql.MustParse(`{ name age nickname tags home { city } office { city } }`, nil)
+	if err := graphql.PrepareQuery(object, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+	if err := graphql.PrepareQuery(object, graphql.MustParse(`{ secret }`, nil).SelectionSet); err == nil {
+		t.Error("expected skipped field to be unknown")
+	}
+
+	e := graphql.Executor{}
+	result, err := e.Execute(context.Background(), object, &Person{
+		Name: "Alice",
+		Age:  30,
+		Tags: []string{"admin"},
+		Home: Address{City: "Boston"},
+	}, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, map[string]interface{}{
+		"name":     "Alice",
+		"age":      int32(30),
+		"nickname": (*string)(nil),
+		"tags":     []interface{}{"admin"},
+		"home":     map[string]interface{}{"city": "Boston"},
+		"office":   nil,
+	}, result)
+
+	if _, err := graphql.ObjectFromStruct("Bad", 1); err == nil {
+		t.Error("expected non-struct example to fail")
+	}
+}
+
+func TestParseSDL(t *testing.T) {
+	schema, err := graphql.ParseSDL(`
+		enum Role { ADMIN MEMBER }
+
+		input Filter {
+			role: Role!
+		}
+
+		type User {
+			name: String!
+			role: Role!
+			friends(limit: Int = 1): [User!]!
+		}
+
+		type Query {
+			users(filter: Filter): [User!]!
+		}
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	users := []interface{}{
+		map[string]interface{}{"name": "alice", "role": "ADMIN"},
+		map[string]interface{}{"name": "bob", "role": "MEMBER"},
+	}
+	if err := schema.BindResolver("Query", "users", func(ctx context.Context, source, args interface{}, selectionSet *graphql.SelectionSet) (interface{}, error) {
+		filter, _ := args.(map[string]interface{})["filter"].(map[string]interface{})
+		var matched []interface{}
+		for _, user := range users {
+			if filter == nil || user.(map[string]interface{})["role"] == filter["role"] {
+				matched = append(matched, user)
+			}
+		}
+		return matched, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := schema.BindResolver("User", "friends", func(ctx context.Context, source, args interface{}, selectionSet *graphql.SelectionSet) (interface{}, error) {
+		limit := int(args.(map[string]interface{})["limit"].(float64))
+		return users[:limit], nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := schema.BindResolver("User", "age", nil); err == nil {
+		t.Error("expected binding an unknown field to fail")
+	}
+
+	q := graphql.MustParse(`{ users(filter: {role: ADMIN}) { name role friends { name } } }`, nil)
+	if err := graphql.PrepareQuery(schema.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+	e := graphql.Executor{}
+	result, err := e.Execute(context.Background(), schema.Query, nil, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, map[string]interface{}{
+		"users": []interface{}{
+			map[string]interface{}{
+				"name":    "alice",
+				"role":    "ADMIN",
+				"friends": []interface{}{map[string]interface{}{"name": "alice"}},
+			},
+		},
+	}, result)
+
+	for _, sdl := range []string{
+		`type Query { user: User }`,
+		`interface Node { id: ID! } type User implements Node { name: String } type Query { node: Node }`,
+		`type User implements Node { id: ID! } type Query { user: User }`,
+		`input Filter { name: String } type Query { filter: Filter }`,
+		`type Mutation { ping: String }`,
+	} {
+		if _, err := graphql.ParseSDL(sdl); err == nil {
+			t.Errorf("expected %q to fail", sdl)
+		}
+	}
+}
+
+func TestParseSDLInterfaces(t *testing.T) {
+	schema, err := graphql.ParseSDL(`
+		type Query {
+			nodes: [Node!]!
+		}
+
+		interface Node {
+			id: ID!
+		}
+
+		type User implements Node {
+			id: ID!
+			name: String!
+		}
+
+		type Robot implements Node {
+			id: ID!
+			serial: Int!
+		}
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := schema.BindResolver("Query", "nodes", func(ctx context.Context, source, args interface{}, selectionSet *graphql.SelectionSet) (interface{}, error) {
+		return []interface{}{
+			map[string]interface{}{"__typename": "User", "id": "1", "name": "alice"},
+			map[string]interface{}{"__typename": "Robot", "id": "2", "serial": 7},
+		}, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	q := graphql.MustParse(`{ nodes { __typename id ... on User { name } ... on Robot { serial } } }`, nil)
+	if err := graphql.PrepareQuery(schema.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+	e := graphql.Executor{}
+	result, err := e.Execute(context.Background(), schema.Query, nil, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, map[string]interface{}{
+		"nodes": []interface{}{
+			map[string]interface{}{"__typename": "User", "id": "1", "name": "alice"},
+			map[string]interface{}{"__typename": "Robot", "id": "2", "serial": 7},
+		},
+	}, result)
+
+	if !strings.Contains(graphql.PrintSchema(schema), "type User implements Node {") {
+		t.Errorf("expected the printed schema to declare the interface of User")
+	}
+}
+
+func TestNewSchema(t *testing.T) {
+	resolve := func(ctx context.Context, source, args interface{}, selectionSet *graphql.SelectionSet) (interface{}, error) {
+		return "pong", nil
+	}
+	query := &graphql.Object{Name: "Query", Fields: map[string]*graphql.Field{
+		"ping": {Type: &graphql.Scalar{Type: "string"}, Resolve: resolve},
+	}}
+	mutation := &graphql.Object{Name: "Mutation", Fields: map[string]*graphql.Field{}}
+
+	schema, err := graphql.NewSchema(query, mutation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schema.Query != query || schema.Mutation != mutation {
+		t.Error("expected the schema to have the given roots")
+	}
+	if _, err := graphql.NewSchema(query, nil); err != nil {
+		t.Errorf("expected the mutation type to be optional, got %v", err)
+	}
+
+	built, err := schemabuilder.NewSchema().Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := built.Validate(); err != nil {
+		t.Errorf("expected a built schema to be valid, got %v", err)
+	}
+
+	for _, c := range []struct {
+		query, mutation graphql.Type
+		expected        string
+	}{
+		{nil, nil, "query type is required"},
+		{&graphql.Scalar{Type: "string"}, nil, "query type string must be an object"},
+		{query, &graphql.List{Type: query}, "mutation type [Query] must be an object"},
+		{&graphql.Object{Name: "Query", Fields: map[string]*graphql.Field{
+			"ping": {Type: &graphql.Scalar{Type: "string"}},
+		}}, nil, "field Query.ping has no resolver"},
+		{&graphql.Object{Name: "Query", Fields: map[string]*graphql.Field{
+			"ping": {Resolve: resolve},
+		}}, nil, "field Query.ping has no type"},
+		{&graphql.Object{Name: "Query", Fields: map[string]*graphql.Field{
+			"ping": {Type: &graphql.InputObject{Name: "Filter"}, Resolve: resolve},
+		}}, nil, "field Query.ping has type Filter, which is not an output type"},
+	} {
+		if _, err := graphql.NewSchema(c.query, c.mutation); err == nil || err.Error() != c.expected {
+			t.Errorf("expected %q, got %v", c.expected, err)
+		}
+	}
+}
+
+func TestInputObjectDefaults(t *testing.T) {
+	order := &graphql.Enum{Type: "Order", Values: []string{"ASC", "DESC"}}
+	filter := &graphql.InputObject{
+		Name: "Filter",
+		InputFields: map[string]graphql.Type{
+			"name":  &graphql.Scalar{Type: "string"},
+			"order": &graphql.NonNull{Type: order},
+		},
+		Defaults: map[string]interface{}{"order": "ASC"},
+	}
+	query := &graphql.Object{Name: "Query", Fields: map[string]*graphql.Field{
+		"search": {
+			Type: &graphql.Scalar{Type: "string"},
+			Args: map[string]graphql.Type{"filter": &graphql.NonNull{Type: filter}},
+			Resolve: func(ctx context.Context, source, args interface{}, selectionSet *graphql.SelectionSet) (interface{}, error) {
+				filter := args.(map[string]interface{})["filter"].(map[string]interface{})
+				return fmt.Sprintf("%v %v", filter["name"], filter["order"]), nil
+			},
+		},
+	}}
+	schema, err := graphql.NewSchema(query, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sdlSchema, err := graphql.ParseSDL(`
+		input Page { first: Int = 10 after: String }
+		type Query { items(page: Page!): String }
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sdlSchema.BindResolver("Query", "items", func(ctx context.Context, source, args interface{}, selectionSet *graphql.SelectionSet) (interface{}, error) {
+		page := args.(map[string]interface{})["page"].(map[string]interface{})
+		return fmt.Sprintf("%v %v", page["first"], page["after"]), nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		schema    *graphql.Schema
+		query     string
+		variables map[string]interface{}
+		expected  string
+	}{
+		{schema, `{ search(filter: {name: "alice"}) }`, nil, "alice ASC"},
+		{schema, `{ search(filter: {name: "alice", order: "DESC"}) }`, nil, "alice DESC"},
+		{schema, `query Q($filter: Filter!) { search(filter: $filter) }`, map[string]interface{}{"filter": map[string]interface{}{"name": "bob"}}, "bob ASC"},
+		{sdlSchema, `{ items(page: {after: "x"}) }`, nil, "10 x"},
+		{sdlSchema, `{ items(page: {first: 2}) }`, nil, "2 <nil>"},
+	} {
+		q, err := graphql.Parse(c.query, c.variables)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := graphql.PrepareQuery(c.schema.Query, q.SelectionSet); err != nil {
+			t.Fatal(err)
+		}
+		e := graphql.Executor{}
+		result, err := e.Execute(context.Background(), c.schema.Query, nil, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, value := range result.(map[string]interface{}) {
+			if value != c.expected {
+				t.Errorf("%s: expected %q, got %v", c.query, c.expected, value)
+			}
+		}
+	}
+
+	filter.Defaults = map[string]interface{}{"order": "SIDEWAYS"}
+	if _, err := graphql.NewSchema(query, nil); err == nil || err.Error() != "input object Filter has a bad default for field order: unknown enum value SIDEWAYS" {
+		t.Errorf("expected a bad default to fail, got %v", err)
+	}
+	if _, err := graphql.ParseSDL(`
+		input Page { order: Order = SIDEWAYS }
+		enum Order { ASC DESC }
+		type Query { items(page: Page): String }
+	`); err == nil || err.Error() != "input object Page has a bad default for field order: unknown enum value SIDEWAYS" {
+		t.Errorf("expected a bad default to fail, got %v", err)
+	}
+}
+
+func TestErrorCodes(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+
+	query := schema.Query()
+	query.FieldFunc("fail", func() (int64, error) {
+		return 0, errors.New("backend unavailable")
+	})
+
+	builtSchema := schema.MustBuild()
+
+	_, err := graphql.Parse(`{ fail `, nil)
+	assert.Equal(t, graphql.ErrorCodeValidationFailed, graphql.FormatError(err).Extensions["code"])
+
+	err = graphql.PrepareQuery(builtSchema.Query, graphql.MustParse(`{ fail(x: 1) }`, nil).SelectionSet)
+	assert.Equal(t, graphql.ErrorCodeValidationFailed, graphql.FormatError(err).Extensions["code"])
+
+	q := graphql.MustParse(`{ fail }`, nil)
+	if err := graphql.PrepareQuery(builtSchema.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+	e := graphql.Executor{}
+	_, err = e.Execute(context.Background(), builtSchema.Query, nil, q)
+	assert.Equal(t, &graphql.GraphQLError{
+		Message:    "backend unavailable",
+		Path:       []string{"fail"},
+		Extensions: map[string]interface{}{"code": graphql.ErrorCodeInternalServerError},
+	}, graphql.FormatError(err))
+
+	// ClientErrors and RetryableErrors are not the server's fault.
+	assert.Equal(t, graphql.ErrorCodeBadRequest, graphql.FormatError(graphql.NewClientError("bad input")).Extensions["code"])
+	assert.Equal(t, graphql.ErrorCodeRetryable, graphql.FormatError(graphql.NewRetryableError(time.Second, "slow down")).Extensions["code"])
+}
+
+func TestErrorPath(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+
+	query := schema.Query()
+	query.FieldFunc("users", func() []*User {
+		return []*User{{Name: "Alice"}, {Name: "Bob"}}
+	})
+
+	user := schema.Object("User", User{})
+	user.FieldFunc("fail", func(u *User) (string, error) {
+		if u.Name == "Bob" {
+			return "", errors.New("no access")
+		}
+		return u.Name, nil
+	})
+
+	builtSchema := schema.MustBuild()
+
+	q := graphql.MustParse(`{ people: users { fail } }`, nil)
+	if err := graphql.PrepareQuery(builtSchema.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+	e := graphql.Executor{}
+	_, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+
+	// Paths run from the root of the query to the failing field, and use
+	// aliases, like the response does.
+	assert.Equal(t, []string{"people", "1", "fail"}, graphql.FormatError(err).Path)
+	assert.EqualError(t, err, "people.1.fail: no access")
+}
+
+func TestErrNotFound(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+
+	query := schema.Query()
+	query.FieldFunc("user", func(args struct{ Id int64 }) (*User, error) {
+		return nil, graphql.ErrNotFound
+	})
+	query.FieldFunc("requiredUser", func(args struct{ Id int64 }) (User, error) {
+		return User{}, graphql.ErrNotFound
+	})
+
+	builtSchema := schema.MustBuild()
+
+	q := graphql.MustParse(`{ user(id: 1) { name } }`, nil)
+	if err := graphql.PrepareQuery(builtSchema.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+	e := graphql.Executor{}
+	result, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"user": nil}, result)
+
+	q = graphql.MustParse(`{ requiredUser(id: 1) { name } }`, nil)
+	if err := graphql.PrepareQuery(builtSchema.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+	_, err = e.Execute(context.Background(), builtSchema.Query, nil, q)
+	assert.Equal(t, &graphql.GraphQLError{
+		Message:    "not found",
+		Path:       []string{"requiredUser"},
+		Extensions: map[string]interface{}{"code": graphql.ErrorCodeNotFound},
+	}, graphql.FormatError(err))
+}
+
+// TestConcurrentSharedResults tests that results shared between concurrently
+// resolved fields through the reactive cache are not written concurrently.
+func TestConcurrentSharedResults(t *testing.T) {
+	type Item struct {
+		Id int64
+	}
+
+	schema := schemabuilder.NewSchema()
+
+	query := schema.Query()
+	query.FieldFunc("items", func() []Item {
+		items := make([]Item, 100)
+		for i := range items {
+			items[i] = Item{Id: int64(i)}
+		}
+		return items
+	})
+
+	item := schema.Object("Item", Item{})
+	item.FieldFunc("parity", func(ctx context.Context, i Item) Item {
+		return Item{Id: i.Id % 2}
+	})
+	item.FieldFunc("self", func(ctx context.Context, i Item) Item {
+		return i
+	})
+	item.FieldFunc("double", func(ctx context.Context, i Item) int64 {
+		return i.Id * 2
+	})
+
+	builtSchema := schema.MustBuild()
+
+	q := graphql.MustParse(`{ items { parity { self { id double } } } }`, nil)
+	if err := graphql.PrepareQuery(builtSchema.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+
+	results := make(chan interface{})
+	rerunner := reactive.NewRerunner(context.Background(), func(ctx context.Context) (interface{}, error) {
+		e := graphql.Executor{}
+		result, err := e.Execute(ctx, builtSchema.Query, nil, q)
+		if err != nil {
+			t.Error(err)
+		}
+		results <- internal.AsJSON(result)
+		return nil, nil
+	}, 0)
+	defer rerunner.Stop()
+
+	items := (<-results).(map[string]interface{})["items"].([]interface{})
+	assert.Len(t, items, 100)
+	assert.Equal(t, internal.ParseJSON(`{"parity": {"self": {"id": 1, "double": 2}}}`), items[3])
+}
+
+func TestPeakConcurrency(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+
+	query := schema.Query()
+	query.FieldFunc("users", func() []*User {
+		return []*User{{Name: "Alice"}, {Name: "Bob"}, {Name: "Charlie"}}
+	})
+
+	user := schema.Object("User", User{})
+	user.FieldFunc("slow", func(ctx context.Context, u *User) string {
+		time.Sleep(50 * time.Millisecond)
+		return u.Name
+	})
+
+	builtSchema := schema.MustBuild()
+
+	for _, c := range []struct {
+		query string
+		peak  int64
+	}{
+		{`{ users { name } }`, 0},
+		{`{ users { slow } }`, 3},
+	} {
+		q := graphql.MustParse(c.query, nil)
+		if err := graphql.PrepareQuery(builtSchema.Query, q.SelectionSet); err != nil {
+			t.Fatal(err)
+		}
+
+		e := graphql.Executor{}
+		if _, err := e.Execute(context.Background(), builtSchema.Query, nil, q); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, c.peak, e.PeakConcurrency(), c.query)
+	}
+}
+
+func TestFieldCounts(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+
+	query := schema.Query()
+	query.FieldFunc("ok", func() string {
+		return "ok"
+	})
+	// fail is expensive, so it is resolved after ok.
+	query.FieldFunc("fail", func(ctx context.Context) (string, error) {
+		return "", errors.New("fail")
+	})
+
+	builtSchema := schema.MustBuild()
+
+	q := graphql.MustParse(`{ ok fail }`, nil)
+	if err := graphql.PrepareQuery(builtSchema.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+
+	e := graphql.Executor{}
+	if _, err := e.Execute(context.Background(), builtSchema.Query, nil, q); err == nil {
+		t.Fatal("expected error")
+	}
+	resolved, errored := e.FieldCounts()
+	assert.Equal(t, int64(1), resolved)
+	assert.Equal(t, int64(1), errored)
+}
+
+func TestCheapFieldsFirst(t *testing.T) {
+	var mu sync.Mutex
+	var order []string
+	record := func(name string) {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, name)
+	}
+
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("expensive", func(ctx context.Context) string {
+		record("expensive")
+		return ""
+	})
+	query.FieldFunc("cheap", func() string {
+		record("cheap")
+		return ""
+	})
+	builtSchema := schema.MustBuild()
+
+	q := graphql.MustParse(`{ expensive cheap }`, nil)
+	if err := graphql.PrepareQuery(builtSchema.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+
+	e := graphql.Executor{}
+	if _, err := e.Execute(context.Background(), builtSchema.Query, nil, q); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"cheap", "expensive"}, order)
+}
+
+func TestConcurrencyLimitCheapFields(t *testing.T) {
+	var mu sync.Mutex
+	var events []string
+	record := func(event string) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+	}
+
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("users", func() []*User {
+		return []*User{{Name: "Alice"}, {Name: "Bob"}, {Name: "Charlie"}}
+	})
+	user := schema.Object("User", User{})
+	user.FieldFunc("slow", func(ctx context.Context, u *User) string {
+		time.Sleep(20 * time.Millisecond)
+		record("slow")
+		return u.Name
+	})
+	user.FieldFunc("cheap", func(u *User) string {
+		record("cheap")
+		return u.Name
+	})
+	builtSchema := schema.MustBuild()
+
+	q := graphql.MustParse(`{ users { slow cheap } }`, nil)
+	if err := graphql.PrepareQuery(builtSchema.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+
+	// With a single token, the expensive fields run one at a time, but the
+	// cheap fields must not wait for them.
+	ctx := concurrencylimiter.With(context.Background(), 1)
+	e := graphql.Executor{}
+	if _, err := e.Execute(ctx, builtSchema.Query, nil, q); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"cheap", "cheap", "cheap", "slow", "slow", "slow"}, events)
+	assert.Equal(t, int64(1), e.PeakConcurrency())
+}
+
+func TestErrOmitField(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("users", func() []*User {
+		return []*User{{Name: "Alice", Age: 30}, {Name: "Bob"}}
+	})
+	user := schema.Object("User", User{})
+	user.FieldFunc("knownAge", func(u *User) (*int64, error) {
+		if u.Age == 0 {
+			return nil, graphql.ErrOmitField
+		}
+		age := int64(u.Age)
+		return &age, nil
+	})
+	user.FieldFunc("expensiveAge", func(ctx context.Context, u *User) (int64, error) {
+		if u.Age == 0 {
+			return 0, graphql.ErrOmitField
+		}
+		return int64(u.Age), nil
+	})
+	builtSchema := schema.MustBuild()
+
+	q := graphql.MustParse(`{ users { name knownAge expensiveAge } }`, nil)
+	if err := graphql.PrepareQuery(builtSchema.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+
+	e := graphql.Executor{}
+	result, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bytes, err := json.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.JSONEq(t, `{"users": [
+		{"name": "Alice", "knownAge": 30, "expensiveAge": 30},
+		{"name": "Bob"}
+	]}`, string(bytes))
+}
+
+func TestPrintSchema(t *testing.T) {
+	schema, err := graphql.ParseSDL(`
+		"""
+		A person who uses the service.
+		"""
+		type User {
+			name: String!
+			login: String @deprecated(reason: "use name")
+			"""
+			The role of the user.
+			"""
+			role: Role!
+			friends(limit: Int = 1, after: ID @deprecated(reason: "use limit")): [User!]!
+			best: Result
+		}
+
+		"""
+		What a user may do.
+		"""
+		enum Role {
+			ADMIN
+			"""
+			Any other user.
+			"""
+			MEMBER
+			GUEST @deprecated
+		}
+
+		scalar Time @specifiedBy(url: "https://tools.ietf.org/html/rfc3339")
+
+		union Result = User | Group
+
+		type Group {
+			members: [User!]!
+			createdAt: Time
+		}
+
+		input Filter {
+			role: Role!
+		}
+
+		type Query {
+			users(filter: Filter): [User!]!
+		}
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	golden, err := ioutil.ReadFile("testdata/TestPrintSchema.graphql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := pretty.Compare(graphql.PrintSchema(schema), string(golden)); diff != "" {
+		t.Errorf("expected schema to match testdata/TestPrintSchema.graphql, but received %s", diff)
+	}
+
+	// The printed schema parses back into the same schema.
+	reparsed, err := graphql.ParseSDL(string(golden))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if printed := graphql.PrintSchema(reparsed); printed != string(golden) {
+		t.Errorf("expected reparsed schema to print the same, but received %s", printed)
+	}
+}
+
+func TestSchemaHash(t *testing.T) {
+	const sdl = `
+		type User {
+			name: String!
+			friends(limit: Int): [User!]!
+		}
+
+		type Query {
+			users: [User!]!
+		}
+	`
+	schema, err := graphql.ParseSDL(sdl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	same, err := graphql.ParseSDL(sdl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schema.Hash() != same.Hash() {
+		t.Errorf("expected identical schemas to hash the same, but received %s and %s", schema.Hash(), same.Hash())
+	}
+
+	added, err := graphql.ParseSDL(`
+		type User {
+			name: String!
+			email: String
+			friends(limit: Int): [User!]!
+		}
+
+		type Query {
+			users: [User!]!
+		}
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schema.Hash() == added.Hash() {
+		t.Error("expected adding a field to change the hash")
+	}
+}
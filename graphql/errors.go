@@ -0,0 +1,168 @@
+package graphql
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Error codes reported in the "code" extension of errors.
+const (
+	// ErrorCodeValidationFailed means that the request or query is invalid, and
+	// should not be retried.
+	ErrorCodeValidationFailed = "GRAPHQL_VALIDATION_FAILED"
+	// ErrorCodeInternalServerError means that executing the query failed.
+	ErrorCodeInternalServerError = "INTERNAL_SERVER_ERROR"
+	// ErrorCodeBadRequest means that the client made a mistake, such as
+	// passing an invalid argument. It is the code of ClientErrors that don't
+	// have a more specific one.
+	ErrorCodeBadRequest = "BAD_REQUEST"
+	// ErrorCodeRetryable means that the query failed for a transient reason,
+	// such as a rate limit, and may be retried after the delay reported in
+	// the "retryAfterMs" extension.
+	ErrorCodeRetryable = "RETRYABLE"
+	// ErrorCodeNotFound means that a required value does not exist.
+	ErrorCodeNotFound = "NOT_FOUND"
+	// ErrorCodeResponseTooLarge means that the response to the query exceeded
+	// the server's size limit.
+	ErrorCodeResponseTooLarge = "RESPONSE_TOO_LARGE"
+	// ErrorCodePersistedQueryNotFound means that the server does not know the
+	// hash of an Automatic Persisted Query, and the client should send the
+	// query's text along with it.
+	ErrorCodePersistedQueryNotFound = "PERSISTED_QUERY_NOT_FOUND"
+	// ErrorCodeTimeout means that a field's resolver did not finish within
+	// its Timeout, and may succeed if retried.
+	ErrorCodeTimeout = "TIMEOUT"
+)
+
+// Reasons that queries are rejected, as returned by RejectionReason.
+const (
+	// RejectedDepth means that the query nests deeper than WithMaxDepth.
+	RejectedDepth = "depth"
+	// RejectedWidth means that the query has more selections than
+	// WithMaxSelections or WithMaxExpensiveSelections.
+	RejectedWidth = "width"
+	// RejectedCost means that the query's estimated cost exceeds
+	// Executor.MaxComplexity.
+	RejectedCost = "cost"
+	// RejectedSize means that the request body exceeds WithMaxBodySize.
+	RejectedSize = "size"
+	// RejectedVariables means that the request has more variables than
+	// WithMaxVariables.
+	RejectedVariables = "variables"
+	// RejectedUnknownField means that the query selects a field that does
+	// not exist.
+	RejectedUnknownField = "unknown-field"
+)
+
+// RejectionReason returns why a query was rejected before execution, as one
+// of the Rejected reasons, along with the value the query was measured at,
+// such as its depth, number of selections, cost, or size in bytes, and the
+// limit that value exceeded, if any. Queries are only measured until they
+// exceed the limit, so value may be less than the query's full depth,
+// selections, or cost. It is meant for logging rejections with WithOnError,
+// to tune limits. ok is false for other errors.
+func RejectionReason(err error) (reason string, value int64, limit int64, ok bool) {
+	if e, isClientError := ErrorCause(err).(ClientError); isClientError && e.reason != "" {
+		return e.reason, e.value, e.limit, true
+	}
+	return "", 0, 0, false
+}
+
+// newRejectionError returns a validation error for a query rejected for
+// reason, having been measured at value, exceeding limit.
+func newRejectionError(reason string, value, limit int64, format string, a ...interface{}) error {
+	return ClientError{
+		message: fmt.Sprintf(format, a...),
+		code:    ErrorCodeValidationFailed,
+		reason:  reason,
+		value:   value,
+		limit:   limit,
+	}
+}
+
+// ErrNotFound can be returned by a resolver when the value it looks up does
+// not exist. A nullable field resolves to null, and a non-null field fails
+// with the code ErrorCodeNotFound.
+var ErrNotFound error = ClientError{message: "not found", code: ErrorCodeNotFound}
+
+// ErrOmitField can be returned by a resolver to leave its field out of the
+// response entirely, rather than resolving it to null.
+var ErrOmitField = errors.New("omit field")
+
+// omittedField is the result of a field whose resolver returned ErrOmitField.
+type omittedField struct{}
+
+// ExtendedError is an error that carries additional, machine-readable
+// information for clients. The extensions are reported alongside the error's
+// message in the "extensions" entry of a GraphQL error.
+type ExtendedError interface {
+	error
+	Extensions() map[string]interface{}
+}
+
+// GraphQLError is the representation of an error in a GraphQL response.
+//
+// Path lists the fields leading to the error, starting at the root of the
+// query.
+type GraphQLError struct {
+	Message    string                 `json:"message"`
+	Path       []string               `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// FormatError converts an error returned by Parse, PrepareQuery, or the
+// Executor into a GraphQLError. Errors that don't report a "code" extension
+// are given the code ErrorCodeInternalServerError.
+func FormatError(err error) *GraphQLError {
+	formatted := &GraphQLError{
+		Message:    ErrorCause(err).Error(),
+		Extensions: make(map[string]interface{}),
+	}
+	if pe, ok := err.(*pathError); ok {
+		formatted.Path = pe.Path()
+	}
+	if extended, ok := ErrorCause(err).(ExtendedError); ok {
+		for k, v := range extended.Extensions() {
+			formatted.Extensions[k] = v
+		}
+	}
+	if _, ok := formatted.Extensions["code"]; !ok {
+		formatted.Extensions["code"] = ErrorCodeInternalServerError
+	}
+	return formatted
+}
+
+// RetryableError is a SanitizedError for transient failures, such as rate
+// limits, that tells clients how long to wait before retrying.
+type RetryableError struct {
+	message string
+	after   time.Duration
+}
+
+// NewRetryableError returns a RetryableError that suggests retrying after the
+// given delay.
+func NewRetryableError(after time.Duration, format string, a ...interface{}) error {
+	return RetryableError{message: fmt.Sprintf(format, a...), after: after}
+}
+
+func (e RetryableError) Error() string {
+	return e.message
+}
+
+func (e RetryableError) SanitizedError() string {
+	return e.message
+}
+
+// RetryAfter returns the suggested delay before retrying.
+func (e RetryableError) RetryAfter() time.Duration {
+	return e.after
+}
+
+func (e RetryableError) Extensions() map[string]interface{} {
+	return map[string]interface{}{
+		"code":         ErrorCodeRetryable,
+		"retryable":    true,
+		"retryAfterMs": int64(e.after / time.Millisecond),
+	}
+}
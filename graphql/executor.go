@@ -5,11 +5,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"reflect"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/samsarahq/thunder/batch"
 	"github.com/samsarahq/thunder/concurrencylimiter"
 	"github.com/samsarahq/thunder/reactive"
 )
@@ -20,11 +25,6 @@ type pathError struct {
 }
 
 func nestPathError(key string, err error) error {
-	// Don't nest SanitzedError's, as they are intended for human consumption.
-	if se, ok := err.(SanitizedError); ok {
-		return se
-	}
-
 	if pe, ok := err.(*pathError); ok {
 		return &pathError{
 			inner: pe.inner,
@@ -45,6 +45,17 @@ func ErrorCause(err error) error {
 	return err
 }
 
+// Path returns the path of the error, starting at the root of the query.
+// nestPathError appends keys as the error propagates up from the failing
+// field, so pe.path is leaf-first and is reversed here, once.
+func (pe *pathError) Path() []string {
+	path := make([]string, 0, len(pe.path))
+	for i := len(pe.path) - 1; i >= 0; i-- {
+		path = append(path, pe.path[i])
+	}
+	return path
+}
+
 func (pe *pathError) Error() string {
 	var buffer bytes.Buffer
 	for i := len(pe.path) - 1; i >= 0; i-- {
@@ -77,22 +88,63 @@ func unwrap(v interface{}) interface{} {
 }
 
 // PrepareQuery checks that the given selectionSet matches the schema typ, and
-// parses the args in selectionSet
-func PrepareQuery(typ Type, selectionSet *SelectionSet) error {
+// that the args in selectionSet parse. The options set limits that the query
+// must stay within.
+//
+// PrepareQuery does not modify selectionSet; args are parsed again for every
+// execution, so a prepared query can safely be shared between executions.
+func PrepareQuery(typ Type, selectionSet *SelectionSet, opts ...PrepareOption) error {
+	var options prepareOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if err := checkLimits(typ, selectionSet, &options); err != nil {
+		return err
+	}
+	return newQueryPreparer(true).prepare(typ, selectionSet)
+}
+
+// queryPreparer implements PrepareQuery. If parseArgs is false, it only checks
+// the names of arguments.
+type queryPreparer struct {
+	parseArgs bool
+	// prepared tracks the selection sets that have been checked against a
+	// type, so that fragments spread repeatedly are only checked once.
+	prepared map[preparedSelectionSet]bool
+}
+
+type preparedSelectionSet struct {
+	typ          Type
+	selectionSet *SelectionSet
+}
+
+func newQueryPreparer(parseArgs bool) *queryPreparer {
+	return &queryPreparer{
+		parseArgs: parseArgs,
+		prepared:  make(map[preparedSelectionSet]bool),
+	}
+}
+
+func (p *queryPreparer) prepare(typ Type, selectionSet *SelectionSet) error {
+	if selectionSet != nil {
+		key := preparedSelectionSet{typ: typ, selectionSet: selectionSet}
+		if p.prepared[key] {
+			return nil
+		}
+		p.prepared[key] = true
+	}
+
 	switch typ := typ.(type) {
 	case *Scalar:
-		if selectionSet != nil {
-			return NewClientError("scalar field must have no selections")
-		}
-		return nil
+		return checkNoSelections("scalar", selectionSet)
 	case *Enum:
-		if selectionSet != nil {
-			return NewClientError("enum field must have no selections")
-		}
-		return nil
+		return checkNoSelections("enum", selectionSet)
 	case *Union:
 		if selectionSet == nil {
-			return NewClientError("object field must have selections")
+			return newValidationError("object field must have selections")
+		}
+		if err := checkFragmentConflicts(typ, selectionSet); err != nil {
+			return err
 		}
 
 		for _, fragment := range selectionSet.Fragments {
@@ -100,7 +152,7 @@ func PrepareQuery(typ Type, selectionSet *SelectionSet) error {
 				if fragment.On != typString {
 					continue
 				}
-				if err := PrepareQuery(graphqlTyp, fragment.SelectionSet); err != nil {
+				if err := p.prepare(graphqlTyp, fragment.SelectionSet); err != nil {
 					return err
 				}
 			}
@@ -108,68 +160,294 @@ func PrepareQuery(typ Type, selectionSet *SelectionSet) error {
 		for _, selection := range selectionSet.Selections {
 			if selection.Name == "__typename" {
 				if !isNilArgs(selection.Args) {
-					return NewClientError(`error parsing args for "__typename": no args expected`)
+					return newValidationError(`error parsing args for "__typename": no args expected`)
 				}
 				if selection.SelectionSet != nil {
-					return NewClientError(`scalar field "__typename" must have no selection`)
+					return newValidationError(`scalar field "__typename" must have no selection`)
 				}
 				continue
 			}
-			return NewClientError(`unknown field "%s"`, selection.Name)
+			return newRejectionError(RejectedUnknownField, 0, 0, `unknown field "%s"`, selection.Name)
 		}
 		return nil
-	case *Object:
+	case *Interface:
 		if selectionSet == nil {
-			return NewClientError("object field must have selections")
+			return newValidationError("object field must have selections")
 		}
-		for _, selection := range selectionSet.Selections {
-			if selection.Name == "__typename" {
-				if !isNilArgs(selection.Args) {
-					return NewClientError(`error parsing args for "__typename": no args expected`)
-				}
-				if selection.SelectionSet != nil {
-					return NewClientError(`scalar field "__typename" must have no selection`)
+		if err := checkFragmentConflicts(typ, selectionSet); err != nil {
+			return err
+		}
+		if err := p.prepareSelections(typ.Fields, selectionSet.Selections); err != nil {
+			return err
+		}
+		for _, fragment := range selectionSet.Fragments {
+			if fragment.On == typ.Name {
+				if err := p.prepare(typ, fragment.SelectionSet); err != nil {
+					return err
 				}
 				continue
 			}
-
-			field, ok := typ.Fields[selection.Name]
+			object, ok := typ.Types[fragment.On]
 			if !ok {
-				return NewClientError(`unknown field "%s"`, selection.Name)
+				return newValidationError(`fragment on "%s" cannot apply to interface %s`, fragment.On, typ.Name)
 			}
-
-			// Only parse args once for a given selection.
-			if !selection.parsed {
-				parsed, err := field.ParseArguments(selection.Args)
-				if err != nil {
-					return NewClientError(`error parsing args for "%s": %s`, selection.Name, err)
-				}
-				selection.Args = parsed
-				selection.parsed = true
-			}
-
-			if err := PrepareQuery(field.Type, selection.SelectionSet); err != nil {
+			if err := p.prepare(object, fragment.SelectionSet); err != nil {
 				return err
 			}
 		}
+		return nil
+
+	case *Object:
+		if selectionSet == nil {
+			return newValidationError("object field must have selections")
+		}
+		if err := checkFragmentConflicts(typ, selectionSet); err != nil {
+			return err
+		}
+		if err := p.prepareSelections(typ.Fields, selectionSet.Selections); err != nil {
+			return err
+		}
 		for _, fragment := range selectionSet.Fragments {
-			if err := PrepareQuery(typ, fragment.SelectionSet); err != nil {
+			if err := p.prepare(typ, fragment.SelectionSet); err != nil {
 				return err
 			}
 		}
 		return nil
 
 	case *List:
-		return PrepareQuery(typ.Type, selectionSet)
+		return p.prepare(typ.Type, selectionSet)
 
 	case *NonNull:
-		return PrepareQuery(typ.Type, selectionSet)
+		return p.prepare(typ.Type, selectionSet)
 
 	default:
 		panic("unknown type kind")
 	}
 }
 
+// checkFragmentConflicts checks that the selections of the same alias in
+// selectionSet, a selection set on typ, can be merged, as Parse does.
+// Selections in fragments on distinct object types don't conflict, as only
+// one of the fragments applies to any value, but Parse can't tell which
+// types those are and leaves them to checkFragmentConflicts.
+func checkFragmentConflicts(typ Type, selectionSet *SelectionSet) error {
+	// scoped is a selection along with the type it applies to: an object
+	// picked by a fragment, or typ itself.
+	type scoped struct {
+		selection *Selection
+		scope     Type
+	}
+	type visit struct {
+		selectionSet *SelectionSet
+		scope        Type
+	}
+	selections := make(map[string][]scoped)
+	visited := make(map[visit]bool)
+
+	var check func(selectionSet *SelectionSet, scope Type) error
+	check = func(selectionSet *SelectionSet, scope Type) error {
+		// A fragment spread more than once only needs to be checked once.
+		if visited[visit{selectionSet, scope}] {
+			return nil
+		}
+		visited[visit{selectionSet, scope}] = true
+
+		for _, selection := range selectionSet.Selections {
+			first := true
+			for _, other := range selections[selection.Alias] {
+				_, isObject := scope.(*Object)
+				_, otherIsObject := other.scope.(*Object)
+				if isObject && otherIsObject && scope != other.scope {
+					continue
+				}
+				if other.selection.Name != selection.Name {
+					return newValidationError("same alias with different name")
+				}
+				if !reflect.DeepEqual(other.selection.Args, selection.Args) {
+					return newValidationError("same alias with different args")
+				}
+				// Later selections in this scope are checked against other
+				// just the same.
+				if other.scope == scope {
+					first = false
+				}
+			}
+			if first {
+				selections[selection.Alias] = append(selections[selection.Alias], scoped{selection: selection, scope: scope})
+			}
+		}
+
+		for _, fragment := range selectionSet.Fragments {
+			if err := check(fragment.SelectionSet, fragmentScope(scope, fragment.On)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return check(selectionSet, typ)
+}
+
+// fragmentScope returns the type that the selections of a fragment on the
+// type named on apply to, within scope. The executor runs every fragment in
+// the selections of an object, whatever its type condition, while a union or
+// interface runs a fragment on one of its objects only for that object.
+func fragmentScope(scope Type, on string) Type {
+	switch scope := scope.(type) {
+	case *Union:
+		if object, ok := scope.Types[on]; ok {
+			return object
+		}
+	case *Interface:
+		if object, ok := scope.Types[on]; ok {
+			return object
+		}
+	}
+	return scope
+}
+
+// prepareSelections checks selections against fields, the fields of an
+// object or interface.
+func (p *queryPreparer) prepareSelections(fields map[string]*Field, selections []*Selection) error {
+	for _, selection := range selections {
+		if selection.Name == "__typename" {
+			if !isNilArgs(selection.Args) {
+				return newValidationError(`error parsing args for "__typename": no args expected`)
+			}
+			if selection.SelectionSet != nil {
+				return newValidationError(`scalar field "__typename" must have no selection`)
+			}
+			continue
+		}
+
+		field, ok := fields[selection.Name]
+		if !ok {
+			return newRejectionError(RejectedUnknownField, 0, 0, `unknown field "%s"`, selection.Name)
+		}
+
+		if !p.parseArgs {
+			if err := checkArgNames(field, selection); err != nil {
+				return err
+			}
+		} else if _, err := parseSelectionArgs(field, selection); err != nil {
+			return err
+		}
+
+		if err := p.prepare(field.Type, selection.SelectionSet); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkNoSelections checks that a field of a scalar or enum type, described
+// by kind, has no selections. Selecting "__typename" gets its own error, as
+// clients sometimes expect it to be valid on any field.
+func checkNoSelections(kind string, selectionSet *SelectionSet) error {
+	if selectionSet == nil {
+		return nil
+	}
+	for _, selection := range selectionSet.Selections {
+		if selection.Name == "__typename" {
+			return newValidationError(`%s field cannot select "__typename": it can only be selected on objects and unions`, kind)
+		}
+	}
+	return newValidationError("%s field must have no selections", kind)
+}
+
+// parseSelectionArgs parses the args passed to field by selection.
+func parseSelectionArgs(field *Field, selection *Selection) (interface{}, error) {
+	var parsed interface{}
+	var err error
+	if field.ParseArguments != nil {
+		parsed, err = field.ParseArguments(selection.Args)
+	} else {
+		parsed, err = parseArguments(field.Args, selection.Args)
+	}
+	if err != nil {
+		return nil, newValidationError(`error parsing args for "%s": %s`, selection.Name, err)
+	}
+	return parsed, nil
+}
+
+// checkArgNames checks that every argument passed to selection is declared by
+// field. Fields that don't declare their arguments are not checked.
+func checkArgNames(field *Field, selection *Selection) error {
+	if field.Args == nil {
+		return nil
+	}
+	args, _ := selection.Args.(map[string]interface{})
+	for name := range args {
+		if _, ok := field.Args[name]; !ok {
+			return newValidationError(`error parsing args for "%s": unknown arg %s`, selection.Name, name)
+		}
+	}
+	return nil
+}
+
+// QueryErrors maps the names of queries to the errors found in them.
+type QueryErrors map[string]error
+
+func (e QueryErrors) Error() string {
+	names := make([]string, 0, len(e))
+	for name := range e {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buffer bytes.Buffer
+	for i, name := range names {
+		if i > 0 {
+			buffer.WriteString("; ")
+		}
+		buffer.WriteString(name)
+		buffer.WriteString(": ")
+		buffer.WriteString(e[name].Error())
+	}
+	return buffer.String()
+}
+
+// PrepareQueries parses a set of named queries, such as a persisted query
+// manifest, and checks them against schema. It returns the parsed queries,
+// and a QueryErrors listing every query that failed to parse or validate.
+//
+// The queries are checked without variables, so argument values are not
+// parsed; they are checked when a query is prepared for execution. Because
+// Parse binds variables, queries that declare variables must be parsed again
+// with their variables before they are executed.
+func PrepareQueries(schema *Schema, queries map[string]string) (map[string]*Query, error) {
+	parsed := make(map[string]*Query, len(queries))
+	errs := make(QueryErrors)
+	for name, source := range queries {
+		query, err := Parse(source, nil)
+		if err != nil {
+			errs[name] = err
+			continue
+		}
+
+		typ := schema.Query
+		switch query.Kind {
+		case "mutation":
+			typ = schema.Mutation
+		case "subscription":
+			if schema.Subscription == nil {
+				errs[name] = newValidationError("schema does not support subscriptions")
+				continue
+			}
+			typ = schema.Subscription
+		}
+		if err := newQueryPreparer(false).prepare(typ, query.SelectionSet); err != nil {
+			errs[name] = err
+			continue
+		}
+		parsed[name] = query
+	}
+
+	if len(errs) > 0 {
+		return parsed, errs
+	}
+	return parsed, nil
+}
+
 type panicError struct {
 	message string
 }
@@ -187,7 +465,236 @@ func safeResolve(ctx context.Context, field *Field, source, args interface{}, se
 			result, err = nil, fmt.Errorf("graphql: panic: %v\n%s", panicErr, buf)
 		}
 	}()
-	return field.Resolve(ctx, source, args, selectionSet)
+	return field.Resolve(withSelectionSet(ctx, selectionSet), source, args, selectionSet)
+}
+
+type parsedArgsKey struct{}
+
+type parsedArgsCacheKey struct {
+	field     *Field
+	selection *Selection
+}
+
+// parsedArgs memoizes the args parsed during a single execution. Keeping them
+// out of the query lets one prepared query be executed concurrently.
+type parsedArgs struct {
+	mu   sync.Mutex
+	args map[parsedArgsCacheKey]interface{}
+}
+
+// argsForSelection returns the parsed args passed to field by selection,
+// parsing them at most once per execution.
+func argsForSelection(ctx context.Context, field *Field, selection *Selection) (interface{}, error) {
+	if selection.mergedFrom != nil {
+		selection = selection.mergedFrom
+	}
+
+	cache, _ := ctx.Value(parsedArgsKey{}).(*parsedArgs)
+	if cache == nil {
+		return parseSelectionArgs(field, selection)
+	}
+
+	key := parsedArgsCacheKey{field: field, selection: selection}
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if args, ok := cache.args[key]; ok {
+		return args, nil
+	}
+	args, err := parseSelectionArgs(field, selection)
+	if err != nil {
+		return nil, err
+	}
+	cache.args[key] = args
+	return args, nil
+}
+
+// resolveWithTimeout resolves field for selection. If timeout is non-zero, the
+// resolver is given a context with that deadline and resolveWithTimeout
+// returns a ClientError at the field's path once it passes, even if the
+// resolver has not returned yet.
+func resolveWithTimeout(ctx context.Context, field *Field, timeout time.Duration, source interface{}, selection *Selection) (interface{}, error) {
+	args, err := argsForSelection(ctx, field, selection)
+	if err != nil {
+		return nil, err
+	}
+
+	if timeout == 0 {
+		return safeResolve(ctx, field, source, args, selection.SelectionSet)
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type result struct {
+		value interface{}
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		value, err := safeResolve(timeoutCtx, field, source, args, selection.SelectionSet)
+		done <- result{value: value, err: err}
+	}()
+
+	select {
+	case r := <-done:
+		if r.err == nil || timeoutCtx.Err() == nil {
+			return r.value, r.err
+		}
+	case <-timeoutCtx.Done():
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return nil, ClientError{message: fmt.Sprintf("timed out after %v", timeout), code: ErrorCodeTimeout}
+}
+
+// fieldTimeout returns how long the resolver of field may run, or 0 if it is
+// not bounded.
+func (e *Executor) fieldTimeout(field *Field) time.Duration {
+	if field.Timeout == 0 && field.Expensive {
+		return e.ExpensiveFieldTimeout
+	}
+	return field.Timeout
+}
+
+// resolve resolves field of typ for selection, reporting it to
+// OnSlowResolver if it takes longer than SlowResolverThreshold, and to
+// OnFieldResolved.
+func (e *Executor) resolve(ctx context.Context, typ *Object, field *Field, source interface{}, selection *Selection) (value interface{}, err error) {
+	start := time.Now()
+	defer func() {
+		d := time.Since(start)
+		if e.exceedsResolverBudget(d) {
+			value, err = nil, e.errResolverBudget()
+		}
+
+		errored := err != nil && err != ErrOmitField && !isNullNotFound(field, err)
+		if errored {
+			atomic.AddInt64(&e.errored, 1)
+		} else {
+			atomic.AddInt64(&e.resolved, 1)
+		}
+		if e.OnFieldResolved != nil {
+			e.OnFieldResolved(typ.Name, selection.Name, d, errored)
+		}
+		if e.tracer != nil {
+			e.tracer.record(ctx, typ.Name, selection.Name, field, start, d)
+		}
+	}()
+
+	value, err = resolveWithTimeout(ctx, field, e.fieldTimeout(field), source, selection)
+	if e.SlowResolverThreshold != 0 && e.OnSlowResolver != nil {
+		if d := time.Since(start); d > e.SlowResolverThreshold {
+			e.OnSlowResolver(PathFromContext(ctx), d)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if value, err = collectList(ctx, field.Type, selection, value); err != nil {
+		return nil, err
+	}
+	if err := checkShape(field.Type, value); err != nil {
+		return nil, NewSafeError("%s.%s: %s", typ.Name, selection.Name, err)
+	}
+	return value, nil
+}
+
+// checkShape checks that value, as returned by the resolver of a field of
+// type typ, is a slice exactly when typ is a list. Scalars are not checked,
+// as their values may be slices.
+func checkShape(typ Type, value interface{}) error {
+	if nonNull, ok := typ.(*NonNull); ok {
+		typ = nonNull.Type
+	}
+	kind := reflect.ValueOf(value).Kind()
+	isSlice := kind == reflect.Slice || kind == reflect.Array
+
+	switch typ.(type) {
+	case *List:
+		if value != nil && !isSlice {
+			return errors.New("resolver returned a non-list value for list field")
+		}
+	case *Object, *Union, *Interface:
+		if isSlice {
+			return errors.New("resolver returned a slice for non-list field")
+		}
+	}
+	return nil
+}
+
+// errNullNonNull is the error of a value of the non-null type typ that
+// resolved to null.
+func errNullNonNull(typ *NonNull) error {
+	return fmt.Errorf("null value for non-null type %v", typ)
+}
+
+// exceedsResolverBudget adds d to the total time spent in resolvers, and
+// reports whether the total exceeds MaxTotalResolverTime. Once it does, the
+// rest of the query is canceled.
+func (e *Executor) exceedsResolverBudget(d time.Duration) bool {
+	total := atomic.AddInt64(&e.resolverTime, int64(d))
+	if e.MaxTotalResolverTime <= 0 || total <= int64(e.MaxTotalResolverTime) {
+		return false
+	}
+	e.cancel()
+	return true
+}
+
+func (e *Executor) errResolverBudget() error {
+	return NewSafeError("query exceeded maximum total resolver time of %v", e.MaxTotalResolverTime)
+}
+
+// isNullNotFound returns true if err is ErrNotFound returned for a nullable
+// field, which resolves to null instead of failing.
+func isNullNotFound(field *Field, err error) bool {
+	if err != ErrNotFound {
+		return false
+	}
+	_, nonNull := field.Type.(*NonNull)
+	return !nonNull
+}
+
+// trackConcurrency records that a resolver started running in its own
+// goroutine, and returns a function to call when it finishes.
+func (e *Executor) trackConcurrency() func() {
+	active := atomic.AddInt64(&e.active, 1)
+	for {
+		peak := atomic.LoadInt64(&e.peak)
+		if active <= peak || atomic.CompareAndSwapInt64(&e.peak, peak, active) {
+			break
+		}
+	}
+	return func() {
+		atomic.AddInt64(&e.active, -1)
+	}
+}
+
+// PeakConcurrency returns the largest number of resolvers that ran
+// concurrently in their own goroutines during the last call to Execute.
+func (e *Executor) PeakConcurrency() int64 {
+	return atomic.LoadInt64(&e.peak)
+}
+
+// FieldCounts returns the number of resolvers that succeeded and that failed
+// during the last call to Execute.
+func (e *Executor) FieldCounts() (resolved, errored int64) {
+	return atomic.LoadInt64(&e.resolved), atomic.LoadInt64(&e.errored)
+}
+
+// writeMetadata reports statistics about the last call to Execute in
+// metadata, as peakConcurrency, fieldsResolved, fieldsErrored, and errorRate.
+func (e *Executor) writeMetadata(metadata map[string]interface{}) {
+	resolved, errored := e.FieldCounts()
+	metadata["peakConcurrency"] = e.PeakConcurrency()
+	metadata["fieldsResolved"] = resolved
+	metadata["fieldsErrored"] = errored
+	if total := resolved + errored; total > 0 {
+		metadata["errorRate"] = float64(errored) / float64(total)
+	} else {
+		metadata["errorRate"] = float64(0)
+	}
 }
 
 type resolveAndExecuteCacheKey struct {
@@ -196,12 +703,17 @@ type resolveAndExecuteCacheKey struct {
 	selection *Selection
 }
 
-func (e *Executor) resolveAndExecute(ctx context.Context, field *Field, source interface{}, selection *Selection) (interface{}, error) {
+func (e *Executor) resolveAndExecute(ctx context.Context, typ *Object, field *Field, source interface{}, selection *Selection) (interface{}, error) {
 	if field.Expensive {
+		// Mark the goroutine as working before forking it, so batches don't
+		// run before its resolver can join them.
+		ctx, working := batch.Working(ctx)
+
 		// TODO: Skip goroutine for cached value
-		ctx, release := concurrencylimiter.Acquire(ctx)
-		return fork(func() (interface{}, error) {
+		return e.tokens.fork(ctx, func(ctx context.Context, release concurrencylimiter.ReleaseFunc) (interface{}, error) {
+			defer working()
 			defer release()
+			defer e.trackConcurrency()()
 
 			value := reflect.ValueOf(source)
 			// cache the body of resolve and excecute so that if the source doesn't change, we
@@ -217,7 +729,13 @@ func (e *Executor) resolveAndExecute(ctx context.Context, field *Field, source i
 
 			// TODO: Consider cacheing resolve and execute independently
 			resolvedValue, err := reactive.Cache(ctx, key, func(ctx context.Context) (interface{}, error) {
-				value, err := safeResolve(ctx, field, source, selection.Args, selection.SelectionSet)
+				value, err := e.resolve(ctx, typ, field, source, selection)
+				if err == ErrOmitField {
+					return omittedField{}, nil
+				}
+				if isNullNotFound(field, err) {
+					return nil, nil
+				}
 				if err != nil {
 					return nil, err
 				}
@@ -233,18 +751,183 @@ func (e *Executor) resolveAndExecute(ctx context.Context, field *Field, source i
 				if err != nil {
 					return nil, err
 				}
+
+				// Only the fields forked by execute make progress from here.
+				working()
 				return await(value)
 			})
-
-			return resolvedValue, err
+			if err != nil {
+				return catchError(field.Type, nil, err)
+			}
+			return resolvedValue, nil
 		}), nil
 	}
 
-	value, err := safeResolve(ctx, field, source, selection.Args, selection.SelectionSet)
+	value, err := e.resolve(ctx, typ, field, source, selection)
+	if err == ErrOmitField {
+		return omittedField{}, nil
+	}
+	if isNullNotFound(field, err) {
+		return nil, nil
+	}
 	if err != nil {
-		return nil, err
+		return catchError(field.Type, nil, err)
+	}
+	value, err = e.execute(ctx, field.Type, value, selection.SelectionSet)
+	return catchError(field.Type, value, err)
+}
+
+// tokenQueue holds the expensive fields of an execution that are waiting for
+// a concurrency limiter token. Rather than every waiting field holding a
+// goroutine blocked on the limiter, a single goroutine acquires tokens for
+// the queued fields in order, and forks each field once it has its token.
+// Meanwhile, the fields after them, which don't need a token, keep
+// resolving.
+type tokenQueue struct {
+	mu      sync.Mutex
+	pending []queuedField
+	waiting bool
+}
+
+type queuedField struct {
+	ctx   context.Context
+	run   func(ctx context.Context, release concurrencylimiter.ReleaseFunc) (interface{}, error)
+	thunk *thunk
+}
+
+// fork returns a thunk for the result of run, called in its own goroutine
+// with a concurrency limiter token acquired from ctx. If no token is
+// available, run is queued until one is.
+func (q *tokenQueue) fork(ctx context.Context, run func(ctx context.Context, release concurrencylimiter.ReleaseFunc) (interface{}, error)) *thunk {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	// Fields only take a token directly if none are queued ahead of them.
+	if len(q.pending) == 0 {
+		if ctx, release, ok := concurrencylimiter.TryAcquire(ctx); ok {
+			return fork(func() (interface{}, error) {
+				return run(ctx, release)
+			})
+		}
+	}
+
+	t := &thunk{done: make(chan struct{})}
+	q.pending = append(q.pending, queuedField{ctx: ctx, run: run, thunk: t})
+	if !q.waiting {
+		q.waiting = true
+		go q.acquire()
+	}
+	return t
+}
+
+// acquire waits for a token for each queued field in turn, and forks the
+// field once it has one, until the queue is empty.
+func (q *tokenQueue) acquire() {
+	for {
+		q.mu.Lock()
+		if len(q.pending) == 0 {
+			q.waiting = false
+			q.mu.Unlock()
+			return
+		}
+		field := q.pending[0]
+		q.pending = q.pending[1:]
+		q.mu.Unlock()
+
+		ctx, release := concurrencylimiter.Acquire(field.ctx)
+		go func() {
+			field.thunk.value, field.thunk.err = field.run(ctx, release)
+			close(field.thunk.done)
+		}()
+	}
+}
+
+// fieldError is the value of a nullable field or list item that failed. It
+// is reported by FieldErrors, and resolves to null.
+type fieldError struct {
+	err error
+}
+
+// catchError handles the result of executing a value of type typ. If typ is
+// nullable, a failure resolves the value to a fieldError rather than failing
+// the enclosing object, as do failures of non-null values nested within it
+// that are only known once it is awaited.
+func catchError(typ Type, value interface{}, err error) (interface{}, error) {
+	if _, nonNull := typ.(*NonNull); nonNull {
+		return value, err
+	}
+	if err != nil {
+		return fieldError{err: err}, nil
+	}
+	switch value.(type) {
+	case map[string]interface{}, []interface{}, *thunk:
+		return lazyThunk(func() (interface{}, error) {
+			awaited, err := await(value)
+			if err != nil {
+				return fieldError{err: err}, nil
+			}
+			return awaited, nil
+		}), nil
+	}
+	return value, nil
+}
+
+// collectFieldErrors replaces the fieldErrors in value, found at path, with
+// nulls, and appends their errors to errs. Maps and slices are copied rather
+// than modified, as they may be shared through the reactive cache; changed
+// reports whether anything was replaced.
+func collectFieldErrors(value interface{}, path []string, errs *[]error) (collected interface{}, changed bool) {
+	switch value := value.(type) {
+	case fieldError:
+		err := value.err
+		for i := len(path) - 1; i >= 0; i-- {
+			err = nestPathError(path[i], err)
+		}
+		*errs = append(*errs, err)
+		return nil, true
+
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for k := range value {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		var copied map[string]interface{}
+		for _, k := range keys {
+			v, changed := collectFieldErrors(value[k], append(path[:len(path):len(path)], k), errs)
+			if !changed {
+				continue
+			}
+			if copied == nil {
+				copied = make(map[string]interface{}, len(value))
+				for k, v := range value {
+					copied[k] = v
+				}
+			}
+			copied[k] = v
+		}
+		if copied != nil {
+			return copied, true
+		}
+
+	case []interface{}:
+		var copied []interface{}
+		for i := range value {
+			v, changed := collectFieldErrors(value[i], append(path[:len(path):len(path)], fmt.Sprint(i)), errs)
+			if !changed {
+				continue
+			}
+			if copied == nil {
+				copied = append([]interface{}{}, value...)
+			}
+			copied[i] = v
+		}
+		if copied != nil {
+			return copied, true
+		}
 	}
-	return e.execute(ctx, field.Type, value, selection.SelectionSet)
+	return value, false
 }
 
 func (e *Executor) executeUnion(ctx context.Context, typ *Union, source interface{}, selectionSet *SelectionSet) (interface{}, error) {
@@ -252,6 +935,18 @@ func (e *Executor) executeUnion(ctx context.Context, typ *Union, source interfac
 	if value.Kind() == reflect.Ptr && value.IsNil() {
 		return nil, nil
 	}
+	if typ.ResolveType != nil {
+		return e.executeResolvedUnion(ctx, typ, source, selectionSet)
+	}
+	if source == nil {
+		return nil, nil
+	}
+	if name, ok := memberOf(typ, source); ok {
+		return e.executeUnionMember(ctx, typ, name, source, selectionSet)
+	}
+	if reflect.Indirect(value).Kind() != reflect.Struct {
+		return nil, errNotUnionMember(typ, source)
+	}
 
 	fields := make(map[string]interface{})
 	for _, selection := range selectionSet.Selections {
@@ -271,23 +966,20 @@ func (e *Executor) executeUnion(ctx context.Context, typ *Union, source interfac
 		}
 
 		inner = inner.FieldByName(typString)
+		if !inner.IsValid() {
+			return nil, errNotUnionMember(typ, source)
+		}
 		if inner.IsNil() {
 			continue
 		}
 		possibleTypes = append(possibleTypes, graphqlTyp.String())
 
-		for _, fragment := range selectionSet.Fragments {
-			if fragment.On != typString {
-				continue
-			}
-			resolved, err := e.executeObject(ctx, graphqlTyp, inner.Interface(), fragment.SelectionSet)
-			if err != nil {
-				return nil, nestPathError(typString, err)
-			}
-
-			for k, v := range resolved.(map[string]interface{}) {
-				fields[k] = v
-			}
+		resolved, err := e.executeObject(ctx, graphqlTyp, inner.Interface(), fragmentsOn(selectionSet, typString))
+		if err != nil {
+			return nil, nestPathError(typString, err)
+		}
+		for k, v := range resolved.(map[string]interface{}) {
+			fields[k] = v
 		}
 	}
 
@@ -297,6 +989,117 @@ func (e *Executor) executeUnion(ctx context.Context, typ *Union, source interfac
 	return fields, nil
 }
 
+// executeResolvedUnion executes a union query for a union whose member type
+// is picked by ResolveType.
+func (e *Executor) executeResolvedUnion(ctx context.Context, typ *Union, source interface{}, selectionSet *SelectionSet) (interface{}, error) {
+	if source == nil {
+		return nil, nil
+	}
+
+	name, err := typ.ResolveType(source)
+	if err != nil {
+		return nil, err
+	}
+	return e.executeUnionMember(ctx, typ, name, source, selectionSet)
+}
+
+// errNotUnionMember is the error of a value of a union field that matches
+// none of its members.
+func errNotUnionMember(typ *Union, source interface{}) error {
+	return NewSafeError("value of type %T is not a member of union %s", source, typ.Name)
+}
+
+// memberOf returns the name of the member of typ that source is a value of,
+// as registered in Members.
+func memberOf(typ *Union, source interface{}) (string, bool) {
+	goType := reflect.TypeOf(source)
+	if name, ok := typ.Members[goType]; ok {
+		return name, true
+	}
+	if goType.Kind() == reflect.Ptr {
+		name, ok := typ.Members[goType.Elem()]
+		return name, ok
+	}
+	return "", false
+}
+
+// executeUnionMember executes a union query on source, a value of the member
+// of typ named name, keeping only the fragments on that member.
+func (e *Executor) executeUnionMember(ctx context.Context, typ *Union, name string, source interface{}, selectionSet *SelectionSet) (interface{}, error) {
+	object, ok := typ.Types[name]
+	if !ok {
+		return nil, fmt.Errorf("%s is not a member of union %s", name, typ.Name)
+	}
+
+	fields := make(map[string]interface{})
+	for _, selection := range selectionSet.Selections {
+		if selection.Name == "__typename" {
+			fields[selection.Alias] = name
+		}
+	}
+	resolved, err := e.executeObject(ctx, object, source, fragmentsOn(selectionSet, name))
+	if err != nil {
+		return nil, nestPathError(name, err)
+	}
+	for k, v := range resolved.(map[string]interface{}) {
+		fields[k] = v
+	}
+	return fields, nil
+}
+
+// fragmentsOn returns the selections of the fragments in a union's
+// selectionSet that apply to its member typ. The fragments are executed
+// together, so that fields selected by several of them are merged, while
+// fields of the same name in fragments on other members are left out.
+func fragmentsOn(selectionSet *SelectionSet, typ string) *SelectionSet {
+	applicable := &SelectionSet{}
+	for _, fragment := range selectionSet.Fragments {
+		if fragment.On == typ {
+			applicable.Fragments = append(applicable.Fragments, fragment)
+		}
+	}
+	return applicable
+}
+
+// executeInterface executes an interface query as a query on the concrete
+// object picked by ResolveType, keeping only the fragments that apply to it.
+func (e *Executor) executeInterface(ctx context.Context, typ *Interface, source interface{}, selectionSet *SelectionSet) (interface{}, error) {
+	value := reflect.ValueOf(source)
+	if source == nil || (value.Kind() == reflect.Ptr && value.IsNil()) {
+		return nil, nil
+	}
+
+	object, err := typ.ResolveType(source)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := typ.Types[object.Name]; !ok {
+		return nil, fmt.Errorf("%s does not implement interface %s", object.Name, typ.Name)
+	}
+	for name := range typ.Fields {
+		if _, ok := object.Fields[name]; !ok {
+			return nil, fmt.Errorf("%s does not implement field %s of interface %s", object.Name, name, typ.Name)
+		}
+	}
+	return e.executeObject(ctx, object, source, applicableFragments(selectionSet, typ.Name, object.Name))
+}
+
+// applicableFragments returns selectionSet without the fragments, at any
+// depth, on types other than iface and object.
+func applicableFragments(selectionSet *SelectionSet, iface, object string) *SelectionSet {
+	filtered := &SelectionSet{Selections: selectionSet.Selections}
+	for _, fragment := range selectionSet.Fragments {
+		if fragment.On != iface && fragment.On != object {
+			continue
+		}
+		filtered.Fragments = append(filtered.Fragments, &Fragment{
+			On:           fragment.On,
+			SelectionSet: applicableFragments(fragment.SelectionSet, iface, object),
+		})
+	}
+	return filtered
+}
+
 // executeObject executes an object query
 func (e *Executor) executeObject(ctx context.Context, typ *Object, source interface{}, selectionSet *SelectionSet) (interface{}, error) {
 	value := reflect.ValueOf(source)
@@ -304,7 +1107,9 @@ func (e *Executor) executeObject(ctx context.Context, typ *Object, source interf
 		return nil, nil
 	}
 
-	selections := Flatten(selectionSet)
+	// Resolve cheap fields before expensive ones, so that cheap data is
+	// ready as early as possible.
+	selections := cheapFirst(typ, Flatten(selectionSet))
 
 	fields := make(map[string]interface{})
 
@@ -316,15 +1121,18 @@ func (e *Executor) executeObject(ctx context.Context, typ *Object, source interf
 		}
 
 		field := typ.Fields[selection.Name]
-		resolved, err := e.resolveAndExecute(ctx, field, source, selection)
+		resolved, err := e.resolveAndExecute(e.withPath(ctx, selection.Alias), typ, field, source, selection)
 		if err != nil {
 			return nil, nestPathError(selection.Alias, err)
 		}
+		if _, ok := resolved.(omittedField); ok {
+			continue
+		}
 		fields[selection.Alias] = resolved
 	}
 
 	if typ.Key != nil {
-		value, err := e.resolveAndExecute(ctx, &Field{Type: &Scalar{Type: "string"}, Resolve: typ.Key}, source, &Selection{})
+		value, err := e.resolveAndExecute(ctx, typ, &Field{Type: &NonNull{Type: &Scalar{Type: "string"}}, Resolve: typ.Key}, source, &Selection{Name: "__key"})
 		if err != nil {
 			return nil, nestPathError("__key", err)
 		}
@@ -334,6 +1142,28 @@ func (e *Executor) executeObject(ctx context.Context, typ *Object, source interf
 	return fields, nil
 }
 
+// cheapFirst reorders selections so that the selections of non-Expensive
+// fields come first, otherwise preserving their order.
+func cheapFirst(typ *Object, selections []*Selection) []*Selection {
+	expensive := func(selection *Selection) bool {
+		field, ok := typ.Fields[selection.Name]
+		return ok && field.Expensive
+	}
+
+	ordered := make([]*Selection, 0, len(selections))
+	for _, selection := range selections {
+		if !expensive(selection) {
+			ordered = append(ordered, selection)
+		}
+	}
+	for _, selection := range selections {
+		if expensive(selection) {
+			ordered = append(ordered, selection)
+		}
+	}
+	return ordered
+}
+
 var emptyList = []interface{}{}
 
 // executeList executes a set query
@@ -349,7 +1179,8 @@ func (e *Executor) executeList(ctx context.Context, typ *List, source interface{
 	// resolve every element in the slice
 	for i := 0; i < slice.Len(); i++ {
 		value := slice.Index(i)
-		resolved, err := e.execute(ctx, typ.Type, value.Interface(), selectionSet)
+		resolved, err := e.execute(e.withPath(ctx, fmt.Sprint(i)), typ.Type, value.Interface(), selectionSet)
+		resolved, err = catchError(typ.Type, resolved, err)
 		if err != nil {
 			return nil, nestPathError(fmt.Sprint(i), err)
 		}
@@ -359,55 +1190,346 @@ func (e *Executor) executeList(ctx context.Context, typ *List, source interface{
 	return items, nil
 }
 
-// execute executes a query by dispatches according to typ
-func (e *Executor) execute(ctx context.Context, typ Type, source interface{}, selectionSet *SelectionSet) (interface{}, error) {
-	if err := ctx.Err(); err != nil {
-		return nil, err
-	}
+// executeLeaf serializes the value of a scalar or enum.
+func executeLeaf(typ Type, source interface{}) (interface{}, error) {
 	switch typ := typ.(type) {
 	case *Scalar:
 		if typ.Unwrapper != nil {
 			return typ.Unwrapper(source)
 		}
+		if serialize := lookupScalarCodec(typ.Type).serialize; serialize != nil && source != nil {
+			value, err := serialize(source)
+			if err != nil {
+				return nil, err
+			}
+			return value, nil
+		}
+		if reader, ok := source.(io.Reader); ok {
+			// Readers are streamed into the response as strings.
+			return reader, nil
+		}
+		if marshaler, ok := source.(Marshaler); ok {
+			if value := reflect.ValueOf(source); value.Kind() == reflect.Ptr && value.IsNil() {
+				return nil, nil
+			}
+			return marshaler.MarshalGraphQL()
+		}
 		return unwrap(source), nil
 	case *Enum:
 		val := unwrap(source)
 		if mapVal, ok := typ.ReverseMap[val]; ok {
 			return mapVal, nil
 		}
-		return nil, errors.New("enum is not valid")
+		return nil, NewSafeError("value %v is not a valid member of enum %s", val, typ.Type)
+	default:
+		panic(typ)
+	}
+}
+
+// execute executes a query by dispatches according to typ
+func (e *Executor) execute(ctx context.Context, typ Type, source interface{}, selectionSet *SelectionSet) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	switch typ := typ.(type) {
+	case *Scalar, *Enum:
+		value, err := executeLeaf(typ, source)
+		if err != nil || e.PostProcess == nil {
+			return value, err
+		}
+		return e.PostProcess(typ, value)
 	case *Union:
 		return e.executeUnion(ctx, typ, source, selectionSet)
+	case *Interface:
+		return e.executeInterface(ctx, typ, source, selectionSet)
 	case *Object:
 		return e.executeObject(ctx, typ, source, selectionSet)
 	case *List:
 		return e.executeList(ctx, typ, source, selectionSet)
 	case *NonNull:
-		return e.execute(ctx, typ.Type, source, selectionSet)
+		// A null fails the enclosing value up to its nearest nullable
+		// ancestor, which catchError resolves to null. Values such as nil
+		// pointers are only null if they execute to null.
+		if source == nil {
+			return nil, errNullNonNull(typ)
+		}
+		value, err := e.execute(ctx, typ.Type, source, selectionSet)
+		if err == nil && value == nil {
+			return nil, errNullNonNull(typ)
+		}
+		return value, err
 	default:
 		panic(typ)
 	}
 }
 
 type Executor struct {
+	// active and peak count the resolvers running concurrently in their own
+	// goroutines, and resolved and errored count the resolvers that succeeded
+	// and failed. They are accessed atomically, and come first to keep them
+	// 64-bit aligned.
+	active   int64
+	peak     int64
+	resolved int64
+	errored  int64
+	// resolverTime is the total time spent in resolvers, in nanoseconds,
+	// accessed atomically.
+	resolverTime int64
+	// cancel cancels the query being executed.
+	cancel context.CancelFunc
+	// tokens queues the expensive fields of the query being executed that
+	// are waiting for a concurrency limiter token.
+	tokens *tokenQueue
+	// errors holds the errors of the last query executed.
+	errors []error
+
+	// SlowResolverThreshold, if non-zero, is the duration after which a
+	// resolver is reported to OnSlowResolver.
+	SlowResolverThreshold time.Duration
+	// OnSlowResolver is called with the path and duration of every resolver
+	// that takes longer than SlowResolverThreshold. Expensive fields are
+	// resolved concurrently, so OnSlowResolver must be safe to call from
+	// multiple goroutines.
+	OnSlowResolver func(path []string, d time.Duration)
+	// OnFieldResolved, if set, is called after every resolver with the name
+	// of the object and field it resolved, how long it took, and whether it
+	// failed, for metrics by type and field. An object's Key is reported as
+	// the field __key. Like OnSlowResolver, OnFieldResolved must be safe to
+	// call from multiple goroutines.
+	OnFieldResolved func(typeName, fieldName string, d time.Duration, errored bool)
+	// MaxTotalResolverTime, if non-zero, bounds the time spent in all
+	// resolvers of a query combined, so that a query of many concurrent
+	// resolvers can't do too much work even if it finishes quickly. A query
+	// that exceeds it is canceled and fails with a SafeError.
+	MaxTotalResolverTime time.Duration
+	// MaxComplexity, if non-zero, bounds the estimated cost of a query, as
+	// computed from its fields' Estimates before any resolver runs. A query
+	// that exceeds it fails with a ClientError.
+	MaxComplexity uint64
+	// ExpensiveFieldTimeout, if non-zero, bounds how long the resolvers of
+	// Expensive fields without their own Timeout may run, like Field.Timeout.
+	ExpensiveFieldTimeout time.Duration
+	// Tracing, if set, records the timing of the query and of every resolver,
+	// returned by Trace.
+	Tracing bool
+	// PostProcess, if set, is called with every scalar and enum value after
+	// it is serialized, and returns the value to put in the response
+	// instead, to apply formatting rules such as rounding floats across the
+	// whole schema. An error fails the field. Like OnSlowResolver, it must be
+	// safe to call from multiple goroutines.
+	PostProcess func(typ Type, value interface{}) (interface{}, error)
+
+	tracer *tracer
+	trace  *Trace
+
 	mu sync.Mutex
 }
 
+// withPath returns a context for resolving key below the current path.
+func (e *Executor) withPath(ctx context.Context, key string) context.Context {
+	parent, _ := ctx.Value(pathKey{}).(*pathNode)
+	return context.WithValue(ctx, pathKey{}, &pathNode{parent: parent, key: key})
+}
+
 // Execute executes a query by dispatches according to typ
+//
+// A nullable field that fails resolves to null, and the rest of the query
+// still executes; a non-null field that fails makes its closest nullable
+// parent null instead, or fails the query if there is none. If any field
+// failed, Execute returns the partial result along with the first error, and
+// Errors returns all of them.
 func (e *Executor) Execute(ctx context.Context, typ Type, source interface{}, query *Query) (interface{}, error) {
-	e.mu.Lock()
-	value, err := e.execute(ctx, typ, source, query.SelectionSet)
-	e.mu.Unlock()
+	ctx = e.start(ctx)
+	defer e.cancel()
+	if err := e.checkComplexity(typ, query); err != nil {
+		e.errors = []error{err}
+		return nil, err
+	}
+
+	value, err := e.executeRoot(ctx, typ, source, query.SelectionSet)
 
 	// Await the promise if things look good so far.
 	if err == nil {
 		value, err = await(value)
 	}
 
+	// Nullable fields that failed resolve to null, and the rest of the data
+	// is still returned.
+	var errs []error
+	if err == nil {
+		value, _ = collectFieldErrors(value, nil, &errs)
+	} else {
+		value, errs = nil, []error{err}
+	}
+
+	// Report an exceeded budget rather than the cancellation it caused.
+	if e.exceededResolverBudget() {
+		value, errs = nil, []error{e.errResolverBudget()}
+	}
+
+	return value, e.finish(query, errs)
+}
+
+// ExecuteStreaming executes a query like Execute, but rather than returning
+// the result as one map, it calls emit with the value of every top-level
+// field as soon as that field, including its selections, completes. path is
+// the field's alias. Calls to emit do not overlap, and happen before
+// ExecuteStreaming returns.
+//
+// A top-level field that fails resolves to null like in Execute, and
+// ExecuteStreaming returns the first error while Errors returns all of them.
+// If a non-null top-level field fails, no more fields are emitted and the
+// rest of the query is canceled; fields emitted before that stand.
+func (e *Executor) ExecuteStreaming(ctx context.Context, typ Type, source interface{}, query *Query, emit func(path []string, value interface{})) error {
+	ctx = e.start(ctx)
+	defer e.cancel()
+	if err := e.checkComplexity(typ, query); err != nil {
+		e.errors = []error{err}
+		return err
+	}
+
+	value, err := e.executeRoot(ctx, typ, source, query.SelectionSet)
+	if err != nil {
+		return e.finish(query, []error{err})
+	}
+	fields, ok := value.(map[string]interface{})
+	if !ok {
+		return e.finish(query, []error{fmt.Errorf("cannot stream a query returning %T", value)})
+	}
+
+	var mu sync.Mutex
+	var failure error
+	fieldErrs := make(map[string][]error, len(fields))
+
+	var wg sync.WaitGroup
+	for key, value := range fields {
+		wg.Add(1)
+		go func(key string, value interface{}) {
+			defer wg.Done()
+
+			awaited, err := await(value)
+			var errs []error
+			if err == nil {
+				awaited, _ = collectFieldErrors(awaited, []string{key}, &errs)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if failure != nil {
+				return
+			}
+			if err != nil {
+				failure = nestPathError(key, err)
+				e.cancel()
+				return
+			}
+			fieldErrs[key] = errs
+			if _, ok := awaited.(omittedField); !ok {
+				emit([]string{key}, awaited)
+			}
+		}(key, value)
+	}
+	wg.Wait()
+
+	// Report errors ordered by path, like Execute.
+	var errs []error
+	if failure != nil {
+		errs = []error{failure}
+	} else {
+		keys := make([]string, 0, len(fieldErrs))
+		for key := range fieldErrs {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			errs = append(errs, fieldErrs[key]...)
+		}
+	}
+	if e.exceededResolverBudget() {
+		errs = []error{e.errResolverBudget()}
+	}
+	return e.finish(query, errs)
+}
+
+// executeRoot executes selectionSet on source, forking the expensive fields.
+// Once it returns, only the forked fields make progress, so the batches they
+// all wait for can run.
+func (e *Executor) executeRoot(ctx context.Context, typ Type, source interface{}, selectionSet *SelectionSet) (interface{}, error) {
+	ctx, working := batch.Working(ctx)
+	defer working()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.execute(ctx, typ, source, selectionSet)
+}
+
+// start resets the executor's state for a new execution, and returns the
+// context to execute it in. The caller must call e.cancel when done.
+func (e *Executor) start(ctx context.Context) context.Context {
+	now := time.Now()
+	ctx = context.WithValue(ctx, queryTimeKey{}, now)
+	e.errors = nil
+	e.tracer, e.trace = nil, nil
+	if e.Tracing {
+		e.tracer = &tracer{start: now}
+	}
+	atomic.StoreInt64(&e.peak, 0)
+	atomic.StoreInt64(&e.resolved, 0)
+	atomic.StoreInt64(&e.errored, 0)
+	atomic.StoreInt64(&e.resolverTime, 0)
+	e.tokens = &tokenQueue{}
+	ctx, e.cancel = context.WithCancel(ctx)
+	return context.WithValue(ctx, parsedArgsKey{}, &parsedArgs{args: make(map[parsedArgsCacheKey]interface{})})
+}
+
+// checkComplexity rejects query if its estimated cost exceeds MaxComplexity.
+func (e *Executor) checkComplexity(typ Type, query *Query) error {
+	if e.MaxComplexity == 0 {
+		return nil
+	}
+	cost, err := estimateComplexity(typ, query.SelectionSet, e.MaxComplexity)
+	if err == nil && cost > e.MaxComplexity {
+		err = newRejectionError(RejectedCost, int64(cost), int64(e.MaxComplexity), "query exceeds maximum complexity of %d", e.MaxComplexity)
+	}
+	return err
+}
+
+// exceededResolverBudget reports whether the execution spent more than
+// MaxTotalResolverTime in resolvers.
+func (e *Executor) exceededResolverBudget() bool {
+	return e.MaxTotalResolverTime > 0 && atomic.LoadInt64(&e.resolverTime) > int64(e.MaxTotalResolverTime)
+}
+
+// finish records errs, the errors of the execution of query, for Errors, and
+// returns the first of them.
+func (e *Executor) finish(query *Query, errs []error) error {
+	if e.tracer != nil {
+		e.trace = e.tracer.finish()
+	}
+
 	// Maybe error wrap if we have an error and a name to attach.
-	if err != nil && query.Name != "" {
-		err = nestPathError(query.Name, err)
+	if query.Name != "" {
+		for i, err := range errs {
+			errs[i] = nestPathError(query.Name, err)
+		}
+	}
+
+	e.errors = errs
+	if len(errs) > 0 {
+		return errs[0]
 	}
+	return nil
+}
+
+// Trace returns the trace of the last call to Execute, if Tracing was set.
+func (e *Executor) Trace() *Trace {
+	return e.trace
+}
 
-	return value, err
+// Errors returns the errors of the last call to Execute. If the query
+// failed, Errors returns the error that failed it. Otherwise, it returns an
+// error for every nullable field that failed and resolved to null, ordered by
+// path.
+func (e *Executor) Errors() []error {
+	return e.errors
 }
@@ -2,12 +2,21 @@ package graphql
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
+	"fmt"
+	"math"
 	"reflect"
+	"runtime"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/davecgh/go-spew/spew"
+	"github.com/samsarahq/thunder/concurrencylimiter"
 	"github.com/samsarahq/thunder/internal"
 )
 
@@ -181,14 +190,18 @@ func TestRepeatedFragment(t *testing.T) {
 	if err := PrepareQuery(query, q.SelectionSet); err != nil {
 		t.Error(err)
 	}
+	if ctr != 1 {
+		t.Errorf("Expected args for fragment to be parsed once when preparing, but they were parsed %d times.", ctr)
+	}
+
 	e := Executor{}
 	_, err := e.Execute(context.Background(), query, nil, q)
 	if err != nil {
 		t.Error(err)
 	}
 
-	if ctr != 1 {
-		t.Errorf("Expected args for fragment to be parsed once, but they were parsed %d times.", ctr)
+	if ctr != 2 {
+		t.Errorf("Expected args for fragment to be parsed once when executing, but they were parsed %d times.", ctr-1)
 	}
 }
 
@@ -289,3 +302,1003 @@ func TestPanic(t *testing.T) {
 }
 
 // TODO: Verify caching and concurrency
+
+func TestFieldTimeout(t *testing.T) {
+	query := makeQuery(nil)
+	query.Fields["slow"] = &Field{
+		Resolve: func(ctx context.Context, source, args interface{}, selectionSet *SelectionSet) (interface{}, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+		Type:           &Scalar{Type: "string"},
+		ParseArguments: func(json interface{}) (interface{}, error) { return nil, nil },
+		Timeout:        10 * time.Millisecond,
+	}
+	query.Fields["stuck"] = &Field{
+		Resolve: func(ctx context.Context, source, args interface{}, selectionSet *SelectionSet) (interface{}, error) {
+			time.Sleep(time.Second)
+			return "stuck", nil
+		},
+		Type:           &Scalar{Type: "string"},
+		ParseArguments: func(json interface{}) (interface{}, error) { return nil, nil },
+		Timeout:        10 * time.Millisecond,
+	}
+	query.Fields["fast"] = &Field{
+		Resolve: func(ctx context.Context, source, args interface{}, selectionSet *SelectionSet) (interface{}, error) {
+			return "fast", nil
+		},
+		Type:           &Scalar{Type: "string"},
+		ParseArguments: func(json interface{}) (interface{}, error) { return nil, nil },
+		Timeout:        time.Second,
+	}
+
+	for _, name := range []string{"slow", "stuck"} {
+		q := MustParse(`{ static `+name+` }`, nil)
+		if err := PrepareQuery(query, q.SelectionSet); err != nil {
+			t.Fatal(err)
+		}
+
+		start := time.Now()
+		e := Executor{}
+		result, err := e.Execute(context.Background(), query, nil, q)
+		if err == nil || err.Error() != name+": timed out after 10ms" {
+			t.Errorf("expected timeout error, got %v", err)
+		}
+		if _, ok := ErrorCause(err).(ClientError); !ok {
+			t.Errorf("expected timeout error to be a ClientError")
+		}
+		if code := FormatError(err).Extensions["code"]; code != ErrorCodeTimeout {
+			t.Errorf("expected timeout error to have code %s, got %v", ErrorCodeTimeout, code)
+		}
+		if !reflect.DeepEqual(result, map[string]interface{}{"static": "static", name: nil}) {
+			t.Errorf("expected %s to be null and siblings kept, got %v", name, result)
+		}
+		if time.Since(start) > 500*time.Millisecond {
+			t.Errorf("expected %s to time out promptly", name)
+		}
+	}
+
+	q := MustParse(`{ fast }`, nil)
+	if err := PrepareQuery(query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+	e := Executor{}
+	result, err := e.Execute(context.Background(), query, nil, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result, map[string]interface{}{"fast": "fast"}) {
+		t.Errorf("bad value %v", result)
+	}
+}
+
+func TestExpensiveFieldTimeout(t *testing.T) {
+	sleeper := func(d time.Duration) Resolver {
+		return func(ctx context.Context, source, args interface{}, selectionSet *SelectionSet) (interface{}, error) {
+			select {
+			case <-time.After(d):
+				return "done", nil
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+	}
+
+	query := makeQuery(nil)
+	query.Fields["expensive"] = &Field{
+		Resolve:        sleeper(time.Second),
+		Type:           &Scalar{Type: "string"},
+		ParseArguments: func(json interface{}) (interface{}, error) { return nil, nil },
+		Expensive:      true,
+	}
+	query.Fields["ownTimeout"] = &Field{
+		Resolve:        sleeper(30 * time.Millisecond),
+		Type:           &Scalar{Type: "string"},
+		ParseArguments: func(json interface{}) (interface{}, error) { return nil, nil },
+		Expensive:      true,
+		Timeout:        time.Second,
+	}
+	query.Fields["cheap"] = &Field{
+		Resolve:        sleeper(30 * time.Millisecond),
+		Type:           &Scalar{Type: "string"},
+		ParseArguments: func(json interface{}) (interface{}, error) { return nil, nil },
+	}
+
+	q := MustParse(`{ expensive ownTimeout cheap }`, nil)
+	if err := PrepareQuery(query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+
+	e := Executor{ExpensiveFieldTimeout: 10 * time.Millisecond}
+	result, err := e.Execute(context.Background(), query, nil, q)
+	if err == nil || err.Error() != "expensive: timed out after 10ms" {
+		t.Errorf("expected expensive field to time out, got %v", err)
+	}
+	if code := FormatError(err).Extensions["code"]; code != ErrorCodeTimeout {
+		t.Errorf("expected timeout error to have code %s, got %v", ErrorCodeTimeout, code)
+	}
+	if !reflect.DeepEqual(result, map[string]interface{}{"expensive": nil, "ownTimeout": "done", "cheap": "done"}) {
+		t.Errorf("bad value %v", result)
+	}
+}
+
+func TestSlowResolver(t *testing.T) {
+	query := makeQuery(nil)
+	a := query.Fields["a"].Type.(*Object)
+	a.Fields["slow"] = &Field{
+		Resolve: func(ctx context.Context, source, args interface{}, selectionSet *SelectionSet) (interface{}, error) {
+			if source.(int) == 2 {
+				time.Sleep(20 * time.Millisecond)
+			}
+			return "slow", nil
+		},
+		Type:           &Scalar{Type: "string"},
+		ParseArguments: func(json interface{}) (interface{}, error) { return nil, nil },
+	}
+
+	q := MustParse(`{ static as { value slow } }`, nil)
+	if err := PrepareQuery(query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+
+	var paths [][]string
+	e := Executor{
+		SlowResolverThreshold: 10 * time.Millisecond,
+		OnSlowResolver: func(path []string, d time.Duration) {
+			if d < 20*time.Millisecond {
+				t.Errorf("expected reported duration to be at least 20ms, got %v", d)
+			}
+			paths = append(paths, path)
+		},
+	}
+	if _, err := e.Execute(context.Background(), query, nil, q); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(paths, [][]string{{"as", "2", "slow"}}) {
+		t.Errorf("expected slow resolver to be reported, got %v", paths)
+	}
+}
+
+func TestScalarParseValue(t *testing.T) {
+	// cursor is a base64-encoded integer offset.
+	cursor := &Scalar{
+		Type: "Cursor",
+		ParseValue: func(value interface{}) (interface{}, error) {
+			asString, ok := value.(string)
+			if !ok {
+				return nil, errors.New("not a string")
+			}
+			decoded, err := base64.StdEncoding.DecodeString(asString)
+			if err != nil {
+				return nil, err
+			}
+			return strconv.Atoi(string(decoded))
+		},
+		Unwrapper: func(value interface{}) (interface{}, error) {
+			return base64.StdEncoding.EncodeToString([]byte(strconv.Itoa(value.(int)))), nil
+		},
+	}
+
+	query := &Object{
+		Name:   "Query",
+		Fields: make(map[string]*Field),
+	}
+	query.Fields["next"] = &Field{
+		Resolve: func(ctx context.Context, source, args interface{}, selectionSet *SelectionSet) (interface{}, error) {
+			return args.(map[string]interface{})["after"].(int) + 1, nil
+		},
+		Type: &NonNull{Type: cursor},
+		Args: map[string]Type{"after": &NonNull{Type: cursor}},
+	}
+
+	q := MustParse(`{ next(after: "MQ==") }`, nil)
+	if err := PrepareQuery(query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+	e := Executor{}
+	result, err := e.Execute(context.Background(), query, nil, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result, map[string]interface{}{"next": "Mg=="}) {
+		t.Errorf("bad value %v", result)
+	}
+
+	for source, expected := range map[string]string{
+		`{ next(after: "!") }`: `error parsing args for "next": after: illegal base64 data at input byte 0`,
+		`{ next }`:             `error parsing args for "next": after: required value is missing`,
+		`{ next(before: "") }`: `error parsing args for "next": after: required value is missing; unknown arg before`,
+	} {
+		q := MustParse(source, nil)
+		if err := PrepareQuery(query, q.SelectionSet); err == nil || err.Error() != expected {
+			t.Errorf("expected %q, got %v", expected, err)
+		}
+	}
+}
+
+func TestMaxSelections(t *testing.T) {
+	query := makeQuery(nil)
+
+	// Every fragment selects the previous one twice, so the query expands to
+	// more than 2^40 selections.
+	var buffer strings.Builder
+	buffer.WriteString("{ a { ...f40 } }\n")
+	buffer.WriteString("fragment f0 on A { value }\n")
+	for i := 1; i <= 40; i++ {
+		fmt.Fprintf(&buffer, "fragment f%d on A { x: nested { ...f%d } y: nested { ...f%d } ...f%d ...f%d }\n", i, i-1, i-1, i-1, i-1)
+	}
+
+	start := time.Now()
+	q := MustParse(buffer.String(), nil)
+	err := PrepareQuery(query, q.SelectionSet, WithMaxSelections(1000))
+	if err == nil || err.Error() != "query exceeds maximum of 1000 selections" {
+		t.Errorf("expected max selections error, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("expected query to be rejected quickly, took %v", time.Since(start))
+	}
+
+	q = MustParse(`{ a { value nested { value } } as { value } }`, nil)
+	if err := PrepareQuery(query, q.SelectionSet, WithMaxSelections(6)); err != nil {
+		t.Error(err)
+	}
+	if err := PrepareQuery(query, q.SelectionSet, WithMaxSelections(5)); err == nil {
+		t.Error("expected max selections error")
+	}
+
+	cyclic := &SelectionSet{}
+	cyclic.Fragments = []*Fragment{{On: "Query", SelectionSet: cyclic}}
+	if err := PrepareQuery(query, cyclic, WithMaxSelections(10)); err == nil || err.Error() != "fragment contains itself" {
+		t.Errorf("expected cycle error, got %v", err)
+	}
+}
+
+func TestTypenameOnLeaf(t *testing.T) {
+	query := makeQuery(nil)
+
+	for _, c := range []struct {
+		query string
+		err   string
+	}{
+		{`{ a { __typename } }`, ""},
+		{`{ static { __typename } }`, `scalar field cannot select "__typename": it can only be selected on objects and unions`},
+		{`{ static { value } }`, "scalar field must have no selections"},
+	} {
+		q := MustParse(c.query, nil)
+		err := PrepareQuery(query, q.SelectionSet)
+		if c.err == "" && err != nil {
+			t.Errorf("%s: %v", c.query, err)
+		}
+		if c.err != "" && (err == nil || err.Error() != c.err) {
+			t.Errorf("%s: expected %q, got %v", c.query, c.err, err)
+		}
+	}
+}
+
+func TestMaxExpensiveSelections(t *testing.T) {
+	query := makeQuery(nil)
+	query.Fields["a"].Type.(*Object).Fields["nested"].Expensive = true
+
+	for _, c := range []struct {
+		query string
+		count int
+	}{
+		{`{ a { value nested { value } } as { nested { value } } static }`, 2},
+		{`{ a { x: nested { value } y: nested { nested { value } } } }`, 3},
+		{`{ a { ...f } as { ...f } } fragment f on A { nested { value } }`, 2},
+	} {
+		q := MustParse(c.query, nil)
+		if err := PrepareQuery(query, q.SelectionSet, WithMaxExpensiveSelections(c.count)); err != nil {
+			t.Errorf("%s: %v", c.query, err)
+		}
+
+		err := PrepareQuery(query, q.SelectionSet, WithMaxExpensiveSelections(c.count-1))
+		if err == nil || err.Error() != fmt.Sprintf("query exceeds maximum of %d selections of expensive fields", c.count-1) {
+			t.Errorf("%s: expected max expensive selections error, got %v", c.query, err)
+		}
+	}
+}
+
+func TestShapeMismatch(t *testing.T) {
+	query := makeQuery(nil)
+	query.Fields["a"].Resolve = func(ctx context.Context, source, args interface{}, selectionSet *SelectionSet) (interface{}, error) {
+		return []int{0}, nil
+	}
+	query.Fields["as"].Resolve = func(ctx context.Context, source, args interface{}, selectionSet *SelectionSet) (interface{}, error) {
+		return 0, nil
+	}
+
+	for _, c := range []struct {
+		query string
+		err   string
+	}{
+		{`{ a { value } }`, "Query.a: resolver returned a slice for non-list field"},
+		{`{ as { value } }`, "Query.as: resolver returned a non-list value for list field"},
+	} {
+		q := MustParse(c.query, nil)
+		if err := PrepareQuery(query, q.SelectionSet); err != nil {
+			t.Fatal(err)
+		}
+		e := Executor{}
+		_, err := e.Execute(context.Background(), query, nil, q)
+		if _, ok := ErrorCause(err).(SafeError); !ok || ErrorCause(err).Error() != c.err {
+			t.Errorf("%s: expected SafeError %q, got %v", c.query, c.err, err)
+		}
+	}
+}
+
+func TestMaxTotalResolverTime(t *testing.T) {
+	query := makeQuery(nil)
+	a := query.Fields["a"].Type.(*Object)
+	a.Fields["slow"] = &Field{
+		Resolve: func(ctx context.Context, source, args interface{}, selectionSet *SelectionSet) (interface{}, error) {
+			time.Sleep(20 * time.Millisecond)
+			return "slow", nil
+		},
+		Type:           &Scalar{Type: "string"},
+		ParseArguments: func(json interface{}) (interface{}, error) { return nil, nil },
+		Expensive:      true,
+	}
+
+	// The four slow resolvers run concurrently, taking about 20ms of wall
+	// time but 80ms of resolver time.
+	q := MustParse(`{ as { slow } }`, nil)
+	if err := PrepareQuery(query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+
+	generous := Executor{MaxTotalResolverTime: time.Second}
+	if _, err := generous.Execute(context.Background(), query, nil, q); err != nil {
+		t.Fatal(err)
+	}
+
+	strict := Executor{MaxTotalResolverTime: 50 * time.Millisecond}
+	_, err := strict.Execute(context.Background(), query, nil, q)
+	if _, ok := err.(SafeError); !ok || err.Error() != "query exceeded maximum total resolver time of 50ms" {
+		t.Errorf("expected query to exceed its resolver time budget, got %v", err)
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	query := makeQuery(nil)
+
+	for _, c := range []struct {
+		query string
+		depth int
+	}{
+		{`{ static }`, 1},
+		{`{ a { value } }`, 2},
+		{`{ a { __typename nested { value } } as { value } }`, 3},
+		{`{ a { ...f } } fragment f on A { nested { ...g } } fragment g on A { nested { value } }`, 4},
+		{`{ ...q } fragment q on Query { as { nested { nested { nested { value } } } } }`, 5},
+	} {
+		q := MustParse(c.query, nil)
+		if err := PrepareQuery(query, q.SelectionSet, WithMaxDepth(c.depth)); err != nil {
+			t.Errorf("%s: %v", c.query, err)
+		}
+
+		err := PrepareQuery(query, q.SelectionSet, WithMaxDepth(c.depth-1))
+		if c.depth > 1 && (err == nil || err.Error() != fmt.Sprintf("query exceeds maximum depth of %d", c.depth-1)) {
+			t.Errorf("%s: expected max depth error, got %v", c.query, err)
+		}
+	}
+
+	cyclic := &SelectionSet{}
+	cyclic.Fragments = []*Fragment{{On: "Query", SelectionSet: cyclic}}
+	if err := PrepareQuery(query, cyclic, WithMaxDepth(10)); err == nil || err.Error() != "fragment contains itself" {
+		t.Errorf("expected cycle error, got %v", err)
+	}
+}
+
+func TestLazyList(t *testing.T) {
+	var produced int64
+	stopped := make(chan struct{})
+
+	query := makeQuery(nil)
+	query.Fields["numbers"] = &Field{
+		Resolve: func(ctx context.Context, source, args interface{}, selectionSet *SelectionSet) (interface{}, error) {
+			numbers := make(chan int)
+			go func() {
+				defer close(stopped)
+				for i := 0; ; i++ {
+					atomic.AddInt64(&produced, 1)
+					select {
+					case numbers <- i:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+			return numbers, nil
+		},
+		Type:           &List{Type: &Scalar{Type: "int"}},
+		ParseArguments: func(json interface{}) (interface{}, error) { return nil, nil },
+	}
+	query.Fields["letters"] = &Field{
+		Resolve: func(ctx context.Context, source, args interface{}, selectionSet *SelectionSet) (interface{}, error) {
+			letters := []string{"a", "b", "c"}
+			return ListIterator(func() (interface{}, bool, error) {
+				if len(letters) == 0 {
+					return nil, false, nil
+				}
+				letter := letters[0]
+				letters = letters[1:]
+				return letter, true, nil
+			}), nil
+		},
+		Type:           &List{Type: &Scalar{Type: "string"}},
+		ParseArguments: func(json interface{}) (interface{}, error) { return nil, nil },
+	}
+
+	q := MustParse(`{ numbers(first: 3) letters }`, nil)
+	if err := PrepareQuery(query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+	e := Executor{}
+	result, err := e.Execute(context.Background(), query, nil, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result, map[string]interface{}{
+		"numbers": []interface{}{0, 1, 2},
+		"letters": []interface{}{"a", "b", "c"},
+	}) {
+		t.Errorf("unexpected result %v", result)
+	}
+
+	// The producer is blocked sending the fourth number until the query's
+	// context is canceled.
+	<-stopped
+	if n := atomic.LoadInt64(&produced); n > 4 {
+		t.Errorf("expected producer to stop after 4 numbers, produced %d", n)
+	}
+}
+
+func TestMaxComplexity(t *testing.T) {
+	query := makeQuery(nil)
+	a := query.Fields["a"].Type.(*Object)
+	a.Fields["expensive"] = &Field{
+		Resolve: func(ctx context.Context, source, args interface{}, selectionSet *SelectionSet) (interface{}, error) {
+			return source, nil
+		},
+		Type:           a,
+		ParseArguments: func(json interface{}) (interface{}, error) { return nil, nil },
+		// The estimate covers the field's selections.
+		Estimate: func(args interface{}) (uint64, bool, error) { return 10, false, nil },
+	}
+	query.Fields["costly"] = &Field{
+		Resolve: func(ctx context.Context, source, args interface{}, selectionSet *SelectionSet) (interface{}, error) {
+			return 0, nil
+		},
+		Type:           &Scalar{Type: "int"},
+		ParseArguments: func(json interface{}) (interface{}, error) { return nil, nil },
+		Estimate:       func(args interface{}) (uint64, bool, error) { return 5, true, nil },
+	}
+
+	// Every alias of a field costs as much as the field.
+	aliases := make([]string, 1000)
+	for i := range aliases {
+		aliases[i] = fmt.Sprintf("c%d: costly", i)
+	}
+
+	for _, c := range []struct {
+		query string
+		cost  uint64
+	}{
+		{`{ static }`, 1},
+		{`{ a { value nested { value } } }`, 4},
+		{`{ a { ...f } } fragment f on A { value }`, 2},
+		{`{ as(first: 50) { value nested { value } } }`, 151},
+		{`{ a { expensive { value expensive { value } } } }`, 11},
+		{`{ costly costly }`, 5},
+		{"{ " + strings.Join(aliases, " ") + " }", 5000},
+	} {
+		q := MustParse(c.query, nil)
+		if err := PrepareQuery(query, q.SelectionSet); err != nil {
+			t.Fatal(err)
+		}
+
+		within := Executor{MaxComplexity: c.cost}
+		if _, err := within.Execute(context.Background(), query, nil, q); err != nil {
+			t.Errorf("%s: %v", c.query, err)
+		}
+
+		over := Executor{MaxComplexity: c.cost - 1}
+		_, err := over.Execute(context.Background(), query, nil, q)
+		if c.cost > 1 && (err == nil || err.Error() != fmt.Sprintf("query exceeds maximum complexity of %d", c.cost-1)) {
+			t.Errorf("%s: expected max complexity error, got %v", c.query, err)
+		}
+	}
+
+	query.Fields["static"].Estimate = func(args interface{}) (uint64, bool, error) {
+		return 0, false, errors.New("cannot estimate")
+	}
+	q := MustParse(`{ static }`, nil)
+	e := Executor{MaxComplexity: 100}
+	if _, err := e.Execute(context.Background(), query, nil, q); err == nil || err.Error() != "static: cannot estimate" {
+		t.Errorf("expected estimator error, got %v", err)
+	}
+}
+
+func TestPartialData(t *testing.T) {
+	query := makeQuery(nil)
+	a := query.Fields["a"].Type.(*Object)
+	a.Fields["required"] = &Field{
+		Resolve: func(ctx context.Context, source, args interface{}, selectionSet *SelectionSet) (interface{}, error) {
+			if source.(int) == 2 {
+				return nil, errors.New("required error")
+			}
+			return source, nil
+		},
+		Type:           &NonNull{Type: &Scalar{Type: "int"}},
+		ParseArguments: func(json interface{}) (interface{}, error) { return nil, nil },
+	}
+
+	// The failed nullable field resolves to null, and the failed non-null
+	// field nulls out the nearest nullable parent, its list item.
+	q := MustParse(`{ static error as { value required } }`, nil)
+	if err := PrepareQuery(query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+
+	e := Executor{}
+	value, err := e.Execute(context.Background(), query, nil, q)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if !reflect.DeepEqual(internal.AsJSON(value), internal.ParseJSON(`
+		{"static": "static", "error": null, "as": [
+			{"__key": 0, "value": 0, "required": 0},
+			{"__key": 1, "value": 1, "required": 1},
+			null,
+			{"__key": 3, "value": 3, "required": 3}
+		]}`)) {
+		t.Error("bad value", spew.Sdump(internal.AsJSON(value)))
+	}
+
+	var errs []string
+	for _, err := range e.Errors() {
+		errs = append(errs, err.Error())
+	}
+	if !reflect.DeepEqual(errs, []string{"as.2.required: required error", "error: test error"}) {
+		t.Errorf("bad errors: %v", errs)
+	}
+}
+
+func TestNonNullPropagation(t *testing.T) {
+	query := makeQuery(nil)
+	a := query.Fields["a"].Type.(*Object)
+	a.Fields["required"] = &Field{
+		Resolve: func(ctx context.Context, source, args interface{}, selectionSet *SelectionSet) (interface{}, error) {
+			if source.(int) == 1 {
+				return nil, nil
+			}
+			return source, nil
+		},
+		Type:           &NonNull{Type: &Scalar{Type: "int"}},
+		ParseArguments: func(json interface{}) (interface{}, error) { return nil, nil },
+	}
+	query.Fields["items"] = &Field{
+		Resolve: func(ctx context.Context, source, args interface{}, selectionSet *SelectionSet) (interface{}, error) {
+			return []interface{}{1, nil, 3}, nil
+		},
+		Type:           &List{Type: &NonNull{Type: &Scalar{Type: "int"}}},
+		ParseArguments: func(json interface{}) (interface{}, error) { return nil, nil },
+	}
+	query.Fields["requiredItems"] = &Field{
+		Resolve: func(ctx context.Context, source, args interface{}, selectionSet *SelectionSet) (interface{}, error) {
+			return []interface{}{1, nil, 3}, nil
+		},
+		Type:           &NonNull{Type: &List{Type: &NonNull{Type: &Scalar{Type: "int"}}}},
+		ParseArguments: func(json interface{}) (interface{}, error) { return nil, nil },
+	}
+
+	// The null non-null field nulls out its list item, and the null non-null
+	// list element nulls out the nullable list.
+	q := MustParse(`{ as { value required } items }`, nil)
+	if err := PrepareQuery(query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+
+	e := Executor{}
+	value, err := e.Execute(context.Background(), query, nil, q)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if !reflect.DeepEqual(internal.AsJSON(value), internal.ParseJSON(`
+		{"as": [
+			{"__key": 0, "value": 0, "required": 0},
+			null,
+			{"__key": 2, "value": 2, "required": 2},
+			{"__key": 3, "value": 3, "required": 3}
+		], "items": null}`)) {
+		t.Error("bad value", spew.Sdump(internal.AsJSON(value)))
+	}
+
+	var errs []string
+	for _, err := range e.Errors() {
+		errs = append(errs, err.Error())
+	}
+	if !reflect.DeepEqual(errs, []string{
+		"as.1.required: null value for non-null type int!",
+		"items.1: null value for non-null type int!",
+	}) {
+		t.Errorf("bad errors: %v", errs)
+	}
+
+	// Without a nullable ancestor, the null fails the whole query.
+	q = MustParse(`{ requiredItems }`, nil)
+	if err := PrepareQuery(query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+	value, err = e.Execute(context.Background(), query, nil, q)
+	if err == nil || err.Error() != "requiredItems.1: null value for non-null type int!" {
+		t.Errorf("expected the query to fail, got %v", err)
+	}
+	if value != nil {
+		t.Error("expected no data", spew.Sdump(value))
+	}
+}
+
+func TestUnmappedEnum(t *testing.T) {
+	query := makeQuery(nil)
+	query.Fields["status"] = &Field{
+		Resolve: func(ctx context.Context, source, args interface{}, selectionSet *SelectionSet) (interface{}, error) {
+			return 7, nil
+		},
+		Type: &Enum{
+			Type:       "Status",
+			Values:     []string{"active"},
+			ReverseMap: map[interface{}]string{1: "active"},
+		},
+		ParseArguments: func(json interface{}) (interface{}, error) { return nil, nil },
+	}
+
+	q := MustParse(`{ status }`, nil)
+	if err := PrepareQuery(query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+
+	e := Executor{}
+	_, err := e.Execute(context.Background(), query, nil, q)
+	if _, ok := ErrorCause(err).(SafeError); !ok || err.Error() != "status: value 7 is not a valid member of enum Status" {
+		t.Errorf("expected SafeError for unmapped enum value, got %v", err)
+	}
+}
+
+func TestPathFromContext(t *testing.T) {
+	query := makeQuery(nil)
+	a := query.Fields["a"].Type.(*Object)
+	a.Fields["path"] = &Field{
+		Resolve: func(ctx context.Context, source, args interface{}, selectionSet *SelectionSet) (interface{}, error) {
+			return strings.Join(PathFromContext(ctx), "."), nil
+		},
+		Type:           &Scalar{Type: "string"},
+		ParseArguments: func(json interface{}) (interface{}, error) { return nil, nil },
+	}
+
+	q := MustParse(`{ admin: a { nested { path } } as { path } }`, nil)
+	if err := PrepareQuery(query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+
+	e := Executor{}
+	result, err := e.Execute(context.Background(), query, nil, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(internal.AsJSON(result), internal.ParseJSON(`
+		{"admin": {"__key": 0, "nested": {"__key": 1, "path": "admin.nested.path"}}, "as": [
+			{"__key": 0, "path": "as.0.path"},
+			{"__key": 1, "path": "as.1.path"},
+			{"__key": 2, "path": "as.2.path"},
+			{"__key": 3, "path": "as.3.path"}
+		]}`)) {
+		t.Error("bad value", spew.Sdump(internal.AsJSON(result)))
+	}
+
+	if path := PathFromContext(context.Background()); path != nil {
+		t.Errorf("expected no path outside the executor, got %v", path)
+	}
+}
+
+type user struct{ name string }
+type robot struct{ serial int }
+
+func TestInterface(t *testing.T) {
+	stringField := func(resolve func(source interface{}) interface{}) *Field {
+		return &Field{
+			Resolve: func(ctx context.Context, source, args interface{}, selectionSet *SelectionSet) (interface{}, error) {
+				return resolve(source), nil
+			},
+			Type:           &Scalar{Type: "string"},
+			ParseArguments: func(json interface{}) (interface{}, error) { return nil, nil },
+		}
+	}
+
+	userType := &Object{
+		Name: "User",
+		Fields: map[string]*Field{
+			"id":   stringField(func(source interface{}) interface{} { return "user-" + source.(*user).name }),
+			"name": stringField(func(source interface{}) interface{} { return source.(*user).name }),
+		},
+	}
+	robotType := &Object{
+		Name: "Robot",
+		Fields: map[string]*Field{
+			"id":     stringField(func(source interface{}) interface{} { return fmt.Sprintf("robot-%d", source.(*robot).serial) }),
+			"serial": stringField(func(source interface{}) interface{} { return fmt.Sprint(source.(*robot).serial) }),
+		},
+	}
+	node := &Interface{
+		Name: "Node",
+		Fields: map[string]*Field{
+			"id": {Type: &Scalar{Type: "string"}, ParseArguments: func(json interface{}) (interface{}, error) { return nil, nil }},
+		},
+		Types: map[string]*Object{"User": userType, "Robot": robotType},
+		ResolveType: func(source interface{}) (*Object, error) {
+			switch source.(type) {
+			case *user:
+				return userType, nil
+			case *robot:
+				return robotType, nil
+			}
+			return nil, fmt.Errorf("unknown node %T", source)
+		},
+	}
+
+	query := makeQuery(nil)
+	query.Fields["nodes"] = &Field{
+		Resolve: func(ctx context.Context, source, args interface{}, selectionSet *SelectionSet) (interface{}, error) {
+			return []interface{}{&user{name: "alice"}, &robot{serial: 7}, &user{name: "bob"}}, nil
+		},
+		Type:           &List{Type: node},
+		ParseArguments: func(json interface{}) (interface{}, error) { return nil, nil },
+	}
+
+	q := MustParse(`{
+		nodes {
+			__typename
+			id
+			... on User { name }
+			... on Robot { serial }
+			... on Node { ... on Robot { id } }
+		}
+	}`, nil)
+	if err := PrepareQuery(query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+
+	e := Executor{}
+	result, err := e.Execute(context.Background(), query, nil, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(internal.AsJSON(result), internal.ParseJSON(`
+		{"nodes": [
+			{"__typename": "User", "id": "user-alice", "name": "alice"},
+			{"__typename": "Robot", "id": "robot-7", "serial": "7"},
+			{"__typename": "User", "id": "user-bob", "name": "bob"}
+		]}`)) {
+		t.Error("bad value", spew.Sdump(internal.AsJSON(result)))
+	}
+
+	for _, bad := range []string{
+		`{ nodes { name } }`,
+		`{ nodes { ... on Query { as { a } } } }`,
+	} {
+		if err := PrepareQuery(query, MustParse(bad, nil).SelectionSet); err == nil {
+			t.Errorf("expected %s to fail to prepare", bad)
+		}
+	}
+}
+
+func TestExecuteStreaming(t *testing.T) {
+	release := make(chan struct{})
+	query := makeQuery(nil)
+	query.Fields["fast"] = &Field{
+		Resolve: func(ctx context.Context, source, args interface{}, selectionSet *SelectionSet) (interface{}, error) {
+			return "fast", nil
+		},
+		Type:           &Scalar{Type: "string"},
+		ParseArguments: func(json interface{}) (interface{}, error) { return nil, nil },
+	}
+	query.Fields["slow"] = &Field{
+		Resolve: func(ctx context.Context, source, args interface{}, selectionSet *SelectionSet) (interface{}, error) {
+			select {
+			case <-release:
+				return "slow", nil
+			case <-time.After(time.Second):
+				return nil, errors.New("slow was not released")
+			}
+		},
+		Type:           &Scalar{Type: "string"},
+		ParseArguments: func(json interface{}) (interface{}, error) { return nil, nil },
+		Expensive:      true,
+	}
+	query.Fields["broken"] = &Field{
+		Resolve: func(ctx context.Context, source, args interface{}, selectionSet *SelectionSet) (interface{}, error) {
+			return nil, errors.New("broken")
+		},
+		Type:           &Scalar{Type: "string"},
+		ParseArguments: func(json interface{}) (interface{}, error) { return nil, nil },
+	}
+
+	q := MustParse(`{ fast slow broken }`, nil)
+	if err := PrepareQuery(query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+
+	// slow only resolves once fast is emitted, so the query finishes only if
+	// fields are emitted as they complete.
+	emitted := make(map[string]interface{})
+	e := Executor{}
+	err := e.ExecuteStreaming(context.Background(), query, nil, q, func(path []string, value interface{}) {
+		emitted[strings.Join(path, ".")] = value
+		if path[0] == "fast" {
+			close(release)
+		}
+	})
+	if err == nil || err.Error() != "broken: broken" {
+		t.Errorf("expected broken to fail, got %v", err)
+	}
+	if !reflect.DeepEqual(emitted, map[string]interface{}{"fast": "fast", "slow": "slow", "broken": nil}) {
+		t.Error("bad values", spew.Sdump(emitted))
+	}
+	if errs := e.Errors(); len(errs) != 1 || errs[0].Error() != "broken: broken" {
+		t.Errorf("expected broken to fail, got %v", errs)
+	}
+}
+
+func TestRegisterScalar(t *testing.T) {
+	RegisterScalar("TestDateTime",
+		func(value interface{}) (interface{}, error) {
+			when, ok := value.(time.Time)
+			if !ok {
+				return nil, fmt.Errorf("%T is not a time", value)
+			}
+			return when.Format(time.RFC3339), nil
+		},
+		func(value interface{}) (interface{}, error) {
+			s, ok := value.(string)
+			if !ok {
+				return nil, errors.New("not a string")
+			}
+			return time.Parse(time.RFC3339, s)
+		},
+	)
+	dateTime := &Scalar{Type: "TestDateTime"}
+
+	query := makeQuery(nil)
+	query.Fields["nextDay"] = &Field{
+		Resolve: func(ctx context.Context, source, args interface{}, selectionSet *SelectionSet) (interface{}, error) {
+			return args.(map[string]interface{})["after"].(time.Time).Add(24 * time.Hour), nil
+		},
+		Type: dateTime,
+		Args: map[string]Type{"after": &NonNull{Type: dateTime}},
+	}
+	query.Fields["broken"] = &Field{
+		Resolve: func(ctx context.Context, source, args interface{}, selectionSet *SelectionSet) (interface{}, error) {
+			return "yesterday", nil
+		},
+		Type: dateTime,
+	}
+
+	q := MustParse(`{ nextDay(after: "2020-02-28T12:00:00Z") }`, nil)
+	if err := PrepareQuery(query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+	e := Executor{}
+	result, err := e.Execute(context.Background(), query, nil, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result, map[string]interface{}{"nextDay": "2020-02-29T12:00:00Z"}) {
+		t.Error("bad value", spew.Sdump(result))
+	}
+
+	q = MustParse(`{ nextDay(after: "tomorrow") }`, nil)
+	if err := PrepareQuery(query, q.SelectionSet); err == nil || !strings.Contains(err.Error(), `error parsing args for "nextDay": after:`) {
+		t.Errorf("expected unparseable time to fail, got %v", err)
+	}
+
+	q = MustParse(`{ broken }`, nil)
+	if err := PrepareQuery(query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Execute(context.Background(), query, nil, q); err == nil || err.Error() != "broken: string is not a time" {
+		t.Errorf("expected serialization to fail with its path, got %v", err)
+	}
+}
+
+func TestPostProcess(t *testing.T) {
+	float := &Scalar{Type: "float64"}
+
+	query := makeQuery(nil)
+	query.Fields["pi"] = &Field{
+		Resolve: func(ctx context.Context, source, args interface{}, selectionSet *SelectionSet) (interface{}, error) {
+			return 3.14159, nil
+		},
+		Type:           float,
+		ParseArguments: func(json interface{}) (interface{}, error) { return nil, nil },
+	}
+	query.Fields["ratios"] = &Field{
+		Resolve: func(ctx context.Context, source, args interface{}, selectionSet *SelectionSet) (interface{}, error) {
+			return []float64{0.125, 2.0 / 3}, nil
+		},
+		Type:           &List{Type: float},
+		ParseArguments: func(json interface{}) (interface{}, error) { return nil, nil },
+	}
+
+	q := MustParse(`{ pi ratios }`, nil)
+	if err := PrepareQuery(query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+
+	e := Executor{
+		PostProcess: func(typ Type, value interface{}) (interface{}, error) {
+			if f, ok := value.(float64); ok {
+				return math.Round(f*100) / 100, nil
+			}
+			return value, nil
+		},
+	}
+	result, err := e.Execute(context.Background(), query, nil, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result, map[string]interface{}{
+		"pi":     3.14,
+		"ratios": []interface{}{0.13, 0.67},
+	}) {
+		t.Error("bad value", spew.Sdump(result))
+	}
+
+	e = Executor{
+		PostProcess: func(typ Type, value interface{}) (interface{}, error) {
+			return nil, errors.New("rejected")
+		},
+	}
+	if _, err := e.Execute(context.Background(), query, nil, q); err == nil || err.Error() != "pi: rejected" {
+		t.Errorf("expected post-processing to fail the field, got %v", err)
+	}
+}
+
+func TestTokenQueue(t *testing.T) {
+	ctx := concurrencylimiter.With(context.Background(), 1)
+	_, release := concurrencylimiter.Acquire(ctx)
+
+	var mu sync.Mutex
+	var order []int
+	before := runtime.NumGoroutine()
+	q := &tokenQueue{}
+	thunks := make([]*thunk, 100)
+	for i := range thunks {
+		i := i
+		thunks[i] = q.fork(ctx, func(ctx context.Context, release concurrencylimiter.ReleaseFunc) (interface{}, error) {
+			defer release()
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, i)
+			return i, nil
+		})
+	}
+
+	// While the token is taken, only one goroutine waits for it.
+	if n := runtime.NumGoroutine() - before; n > 1 {
+		t.Errorf("expected one goroutine to wait for a token, but %d did", n)
+	}
+
+	release()
+	expected := make([]int, len(thunks))
+	for i, thunk := range thunks {
+		expected[i] = i
+		if value, err := thunk.await(); err != nil || value != i {
+			t.Errorf("expected field %d to resolve to %d, got %v, %v", i, i, value, err)
+		}
+	}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("expected queued fields to run in order, got %v", order)
+	}
+}
@@ -1,68 +1,677 @@
 package graphql
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/samsarahq/thunder/batch"
 	"github.com/samsarahq/thunder/reactive"
 )
 
 func HTTPHandler(schema *Schema, middlewares ...MiddlewareFunc) http.Handler {
-	return &httpHandler{
-		schema:      schema,
-		middlewares: middlewares,
+	return HTTPHandlerWithOptions(schema, WithHTTPMiddlewares(middlewares...))
+}
+
+// HTTPHandlerWithOptions returns a handler that serves GraphQL queries and
+// mutations sent as JSON POST requests, alone or in batches, and queries sent
+// as GET requests.
+func HTTPHandlerWithOptions(schema *Schema, opts ...HTTPOption) http.Handler {
+	h := &httpHandler{
+		schema: schema,
+	}
+	for _, opt := range opts {
+		opt(h)
 	}
+	return h
 }
 
 type httpHandler struct {
-	schema      *Schema
-	middlewares []MiddlewareFunc
+	schema         *Schema
+	middlewares    []MiddlewareFunc
+	encoders       []ResponseEncoder
+	minCompress    int
+	prepareOptions []PrepareOption
+	onError        func(ctx context.Context, err error, query *string)
+	cache          ResponseCache
+	cacheTTL       time.Duration
+	cacheScope     func(ctx context.Context) string
+	maxBodySize    int64
+	readTimeout    time.Duration
+	errorFilter    func([]*GraphQLError) []*GraphQLError
+	maxResponse    int
+	extensions     func(ctx context.Context, metadata map[string]interface{}) map[string]interface{}
+	readiness      *Readiness
+	fieldMetrics   func(typeName, fieldName string, d time.Duration, errored bool)
+	resolverTime   time.Duration
+	maxComplexity  uint64
+	expensiveLimit time.Duration
+	maxBatchSize   int
+	rerunInterval  time.Duration
+	maxVariables   int
+	partialStatus  int
+	maxErrorRate   float64
+	tracing        bool
+	postProcess    func(typ Type, value interface{}) (interface{}, error)
+	persisted      PersistedQueryCache
+	slowQuery      time.Duration
+	logSlowQuery   func(op, query string, variables map[string]interface{}, d time.Duration)
+	cors           *CORSOptions
+}
+
+type HTTPOption func(*httpHandler)
+
+// WithHTTPMiddlewares runs middlewares around every query the handler
+// executes.
+func WithHTTPMiddlewares(middlewares ...MiddlewareFunc) HTTPOption {
+	return func(h *httpHandler) {
+		h.middlewares = append(h.middlewares, middlewares...)
+	}
+}
+
+// WithPrepareOptions sets the limits that queries must stay within, as
+// enforced by PrepareQuery.
+func WithPrepareOptions(opts ...PrepareOption) HTTPOption {
+	return func(h *httpHandler) {
+		h.prepareOptions = append(h.prepareOptions, opts...)
+	}
+}
+
+// WithResponseEncoders compresses responses with the first of encoders that
+// the client accepts, in order of preference. For example, to prefer a
+// Brotli implementation over gzip:
+//
+//     WithResponseEncoders(brotliEncoder, graphql.GzipEncoder)
+func WithResponseEncoders(encoders ...ResponseEncoder) HTTPOption {
+	return func(h *httpHandler) {
+		h.encoders = append(h.encoders, encoders...)
+	}
+}
+
+// WithMinCompressSize leaves responses smaller than n bytes uncompressed, as
+// compressing them costs more than it saves. It only applies with
+// WithResponseEncoders.
+func WithMinCompressSize(n int) HTTPOption {
+	return func(h *httpHandler) {
+		h.minCompress = n
+	}
+}
+
+// WithOnError calls onError with every error the handler encounters while
+// parsing, validating, executing, or serializing a query. err is the
+// unsanitized error, for internal logging; query is nil if the request did
+// not contain a query.
+func WithOnError(onError func(ctx context.Context, err error, query *string)) HTTPOption {
+	return func(h *httpHandler) {
+		h.onError = onError
+	}
+}
+
+// WithResponseCache serves repeated queries from cache for ttl after their
+// first successful execution. Responses are cached by the query's selections
+// and arguments, normalized so that formatting and field order don't
+// matter, and by scope, which returns the identity of the caller, such as a
+// user ID, from the context that middlewares pass on. Only schemas whose
+// responses are the same for every caller should use a scope that returns
+// "". The cache is consulted after middlewares run, so authentication and
+// logging apply to cached responses too. Mutations and failed queries are
+// never cached.
+//
+// WithResponseCache panics if scope is nil, as a cache shared by every
+// caller would serve one user's data to another.
+func WithResponseCache(cache ResponseCache, ttl time.Duration, scope func(ctx context.Context) string) HTTPOption {
+	if scope == nil {
+		panic("graphql: WithResponseCache requires a scope")
+	}
+	return func(h *httpHandler) {
+		h.cache = cache
+		h.cacheTTL = ttl
+		h.cacheScope = scope
+	}
+}
+
+// WithMaxBodySize rejects requests whose body is larger than n bytes. The
+// limit is enforced while the body is read, so an oversized body is never
+// read into memory in full.
+func WithMaxBodySize(n int64) HTTPOption {
+	return func(h *httpHandler) {
+		h.maxBodySize = n
+	}
+}
+
+// WithStatusForPartialErrors responds to queries that returned data but had
+// fields fail with status, such as http.StatusMultiStatus, rather than 200
+// OK, so that clients and proxies can tell degraded responses apart. A batch
+// of queries is responded to with status if any of its queries had fields
+// fail.
+func WithStatusForPartialErrors(status int) HTTPOption {
+	return func(h *httpHandler) {
+		h.partialStatus = status
+	}
+}
+
+// WithMaxErrorRate fails queries in which more than rate of the fields, a
+// fraction between 0 and 1, failed, as reported in the errorRate metadata.
+// Such queries are responded to without data, with an error that reports
+// how many fields failed, instead of with their partial data.
+func WithMaxErrorRate(rate float64) HTTPOption {
+	return func(h *httpHandler) {
+		h.maxErrorRate = rate
+	}
+}
+
+// checkErrorRate returns an error if more than WithMaxErrorRate allows of
+// the fields e resolved failed.
+func (h *httpHandler) checkErrorRate(e *Executor) error {
+	if h.maxErrorRate <= 0 {
+		return nil
+	}
+	resolved, errored := e.FieldCounts()
+	if total := resolved + errored; total > 0 && float64(errored)/float64(total) > h.maxErrorRate {
+		return NewSafeError("%d of %d fields failed, exceeding the maximum error rate of %v", errored, total, h.maxErrorRate)
+	}
+	return nil
+}
+
+// WithMaxVariables rejects requests with more than n variables before their
+// query is parsed. By default, the number of variables is unlimited.
+func WithMaxVariables(n int) HTTPOption {
+	return func(h *httpHandler) {
+		h.maxVariables = n
+	}
+}
+
+// checkVariables checks that variables are no more than WithMaxVariables
+// allows.
+func (h *httpHandler) checkVariables(variables map[string]interface{}) error {
+	if h.maxVariables > 0 && len(variables) > h.maxVariables {
+		return newRejectionError(RejectedVariables, int64(len(variables)), int64(h.maxVariables), "request has %d variables, exceeding the maximum of %d", len(variables), h.maxVariables)
+	}
+	return nil
+}
+
+// prepareRewritten checks the query of input against typ like query, the
+// query the handler received and prepared, if a middleware rewrote it, as
+// SetVariable does. Selections that were skipped when query was prepared
+// may be included in the rewritten query, and must be validated before it
+// runs.
+func (h *httpHandler) prepareRewritten(typ Type, query *Query, input *ComputationInput) error {
+	if input.ParsedQuery == query {
+		return nil
+	}
+	if err := h.checkVariables(input.Variables); err != nil {
+		return err
+	}
+	return h.prepareOperation(typ, input.ParsedQuery)
+}
+
+// prepareOperation prepares query against typ with the handler's prepare
+// options. A subscription must also select a single root field.
+func (h *httpHandler) prepareOperation(typ Type, query *Query) error {
+	if query.Kind == "subscription" {
+		if err := checkSingleRootField(query.SelectionSet); err != nil {
+			return err
+		}
+	}
+	return PrepareQuery(typ, query.SelectionSet, h.prepareOptions...)
+}
+
+// WithReadTimeout rejects requests whose body has not been received in full
+// within d of the handler starting to read it. The client receives an error
+// and its connection is closed, so a client that stalls mid-body cannot tie
+// up a handler indefinitely.
+func WithReadTimeout(d time.Duration) HTTPOption {
+	return func(h *httpHandler) {
+		h.readTimeout = d
+	}
+}
+
+// WithErrorFilter passes the errors of every response through filter just
+// before the response is written, so that errors can be rewritten, dropped,
+// or merged. If filter returns no errors, the response reports none.
+func WithErrorFilter(filter func([]*GraphQLError) []*GraphQLError) HTTPOption {
+	return func(h *httpHandler) {
+		h.errorFilter = filter
+	}
+}
+
+// WithMaxResponseBytes fails queries whose serialized response is larger
+// than n bytes, before compression, with an error with the code
+// ErrorCodeResponseTooLarge. A response that streams readers is cut short
+// once it exceeds n bytes, and the error is only reported to WithOnError.
+func WithMaxResponseBytes(n int) HTTPOption {
+	return func(h *httpHandler) {
+		h.maxResponse = n
+	}
+}
+
+// WithResponseExtensions adds the map returned by extensions to every
+// response to an executed query as its top-level "extensions", for
+// information such as tracing or cost. extensions is called with the
+// metadata that middlewares reported in ComputationOutput.Metadata; if it
+// returns an empty map, the response has no extensions.
+func WithResponseExtensions(extensions func(ctx context.Context, metadata map[string]interface{}) map[string]interface{}) HTTPOption {
+	return func(h *httpHandler) {
+		h.extensions = extensions
+	}
+}
+
+// WithTracing records the timing of every query the handler executes and of
+// its resolvers, as Executor.Tracing does, and adds it to the response's
+// extensions as "tracing" in the Apollo Tracing format. Recording every
+// resolver has a cost, so tracing is meant for debugging performance.
+func WithTracing() HTTPOption {
+	return func(h *httpHandler) {
+		h.tracing = true
+	}
+}
+
+// WithFieldMetrics reports every resolver the handler runs to metrics, as
+// Executor.OnFieldResolved does, for latency and error counts by type and
+// field.
+func WithFieldMetrics(metrics func(typeName, fieldName string, d time.Duration, errored bool)) HTTPOption {
+	return func(h *httpHandler) {
+		h.fieldMetrics = metrics
+	}
+}
+
+// WithMaxTotalResolverTime bounds the time spent in all resolvers of a query
+// combined, as Executor.MaxTotalResolverTime does.
+func WithMaxTotalResolverTime(d time.Duration) HTTPOption {
+	return func(h *httpHandler) {
+		h.resolverTime = d
+	}
+}
+
+// WithMaxComplexity bounds the estimated cost of queries, as
+// Executor.MaxComplexity does.
+func WithMaxComplexity(max uint64) HTTPOption {
+	return func(h *httpHandler) {
+		h.maxComplexity = max
+	}
+}
+
+// WithExpensiveFieldTimeout bounds how long the resolvers of expensive fields
+// without their own Timeout may run, as Executor.ExpensiveFieldTimeout does.
+func WithExpensiveFieldTimeout(d time.Duration) HTTPOption {
+	return func(h *httpHandler) {
+		h.expensiveLimit = d
+	}
+}
+
+// WithPostProcess shapes every scalar and enum value the handler returns, as
+// Executor.PostProcess does.
+func WithPostProcess(postProcess func(typ Type, value interface{}) (interface{}, error)) HTTPOption {
+	return func(h *httpHandler) {
+		h.postProcess = postProcess
+	}
+}
+
+// WithSlowQueryLog calls log with the operation name, text, variables, and
+// duration of every query that takes longer than threshold to serve, from
+// parsing it to writing its response. The values of the variables are
+// redacted so that logs don't hold user data, but their names and shape are
+// kept.
+func WithSlowQueryLog(threshold time.Duration, log func(op, query string, variables map[string]interface{}, d time.Duration)) HTTPOption {
+	return func(h *httpHandler) {
+		h.slowQuery = threshold
+		h.logSlowQuery = log
+	}
+}
+
+// WithPersistedQueries enables Apollo's Automatic Persisted Queries: a client
+// can send only the SHA-256 hash of a query in the "persistedQuery" request
+// extension, and the handler runs the query stored under it. An unknown hash
+// fails with the message "PersistedQueryNotFound", and the client then sends
+// the query along with its hash, which the handler checks and stores in
+// cache. A nil cache keeps the DefaultPersistedQueryCacheSize most recently
+// used queries in memory.
+func WithPersistedQueries(cache PersistedQueryCache) HTTPOption {
+	return func(h *httpHandler) {
+		if cache == nil {
+			cache = NewLRUPersistedQueryCache(DefaultPersistedQueryCacheSize)
+		}
+		h.persisted = cache
+	}
+}
+
+// DefaultMaxBatchSize is the default maximum number of queries in a batch.
+const DefaultMaxBatchSize = 10
+
+// WithMaxBatchSize rejects batches of more than n queries. A client batches
+// queries by sending a JSON array of them in a single POST body, and
+// receives an array of their responses in the same order. Batches are
+// limited to DefaultMaxBatchSize queries by default.
+func WithMaxBatchSize(n int) HTTPOption {
+	return func(h *httpHandler) {
+		h.maxBatchSize = n
+	}
+}
+
+// maxBatchSizeOrDefault returns the maximum number of queries in a batch.
+func (h *httpHandler) maxBatchSizeOrDefault() int {
+	if h.maxBatchSize > 0 {
+		return h.maxBatchSize
+	}
+	return DefaultMaxBatchSize
+}
+
+// WithReadiness gates the handler on readiness. While readiness is not
+// ready, new requests are rejected with a 503 Service Unavailable, and
+// requests already admitted are served to completion.
+func WithReadiness(readiness *Readiness) HTTPOption {
+	return func(h *httpHandler) {
+		h.readiness = readiness
+	}
+}
+
+// A Readiness lets a server drain HTTP handlers before it shuts down, such as
+// during a rolling deploy: call SetReady(false), then wait for InFlight to
+// reach zero. The zero value is ready.
+type Readiness struct {
+	// inFlight is accessed atomically, and comes first to keep it 64-bit
+	// aligned.
+	inFlight int64
+	notReady int32
+}
+
+// SetReady sets whether handlers admit new requests.
+func (r *Readiness) SetReady(ready bool) {
+	var notReady int32
+	if !ready {
+		notReady = 1
+	}
+	atomic.StoreInt32(&r.notReady, notReady)
+}
+
+// Ready reports whether handlers admit new requests.
+func (r *Readiness) Ready() bool {
+	return atomic.LoadInt32(&r.notReady) == 0
+}
+
+// InFlight returns the number of admitted requests that are still being
+// served.
+func (r *Readiness) InFlight() int64 {
+	return atomic.LoadInt64(&r.inFlight)
+}
+
+// admit admits a request, if ready, and returns a function to call when it
+// has been served.
+func (r *Readiness) admit() (func(), bool) {
+	// Count the request before checking readiness, so that a request is
+	// either rejected or counted by the time SetReady(false) returns.
+	atomic.AddInt64(&r.inFlight, 1)
+	done := func() {
+		atomic.AddInt64(&r.inFlight, -1)
+	}
+	if !r.Ready() {
+		done()
+		return nil, false
+	}
+	return done, true
 }
 
 type httpPostBody struct {
-	Query     string                 `json:"query"`
-	Variables map[string]interface{} `json:"variables"`
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+	Extensions    map[string]interface{} `json:"extensions"`
 }
 
 type httpResponse struct {
-	Data   interface{} `json:"data"`
-	Errors []string    `json:"errors"`
+	Data       interface{}            `json:"data"`
+	Errors     []*GraphQLError        `json:"errors"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// retryAfterSeconds formats d as the number of seconds in a Retry-After
+// header, rounding up so clients never retry too early.
+func retryAfterSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64((d+time.Second-1)/time.Second), 10)
 }
 
 func (h *httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	writeResponse := func(value interface{}, err error) {
-		response := httpResponse{}
+	if h.cors != nil && h.cors.serveCORS(w, r) {
+		return
+	}
+
+	if h.readiness != nil {
+		done, ok := h.readiness.admit()
+		if !ok {
+			http.Error(w, "server is not ready", http.StatusServiceUnavailable)
+			return
+		}
+		defer done()
+	}
+
+	var operations []*httpPostBody
+	var batched bool
+	switch r.Method {
+	case "GET":
+		params, err := decodeGetParams(r.URL.Query())
 		if err != nil {
-			response.Errors = []string{err.Error()}
-		} else {
-			response.Data = value
+			h.writeError(w, r, err)
+			return
+		}
+		operations = []*httpPostBody{params}
+
+	case "POST":
+		if r.Body == nil {
+			h.writeError(w, r, newValidationError("request must include a query"))
+			return
 		}
 
-		responseJSON, err := json.Marshal(response)
+		var body io.Reader = r.Body
+		if h.maxBodySize > 0 {
+			body = &limitedReader{r: body, remaining: h.maxBodySize}
+		}
+		var err error
+		operations, batched, err = h.readBody(body)
+		if err == errReadTimeout {
+			err = newValidationError("%s", err)
+			h.reportError(r.Context(), err, nil)
+			h.abortRequest(w, err)
+			return
+		}
+		if err == errBodyTooLarge {
+			// The body was only read up to one byte past the limit, so its
+			// size is only known if the client sent it.
+			size := h.maxBodySize + 1
+			if r.ContentLength > size {
+				size = r.ContentLength
+			}
+			h.writeError(w, r, newRejectionError(RejectedSize, size, h.maxBodySize, "request body too large: exceeds %d bytes", h.maxBodySize))
+			return
+		}
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			h.writeError(w, r, newValidationError("%s", err))
 			return
 		}
 
-		http.Error(w, string(responseJSON), http.StatusOK)
+	default:
+		h.writeError(w, r, newValidationError("request must be a GET or POST"))
+		return
 	}
 
-	if r.Method != "POST" {
-		writeResponse(nil, errors.New("request must be a POST"))
+	if !batched {
+		h.serveQuery(w, r, operations[0])
 		return
 	}
-
-	if r.Body == nil {
-		writeResponse(nil, errors.New("request must include a query"))
+	if len(operations) == 0 {
+		h.writeError(w, r, newValidationError("batch must include a query"))
+		return
+	}
+	if maxBatchSize := h.maxBatchSizeOrDefault(); len(operations) > maxBatchSize {
+		h.writeError(w, r, newValidationError("batch of %d queries exceeds maximum of %d", len(operations), maxBatchSize))
 		return
 	}
+	h.serveBatch(w, r, operations)
+}
 
-	var params httpPostBody
-	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+// serveBatch serves a batch of queries, one after another, and responds with
+// an array of their responses in order. Each query's response is buffered,
+// uncompressed, and the array is compressed as a whole.
+func (h *httpHandler) serveBatch(w http.ResponseWriter, r *http.Request, operations []*httpPostBody) {
+	plain := *r
+	plain.Header = make(http.Header, len(r.Header))
+	for k, v := range r.Header {
+		if k != "Accept-Encoding" {
+			plain.Header[k] = v
+		}
+	}
+
+	status := http.StatusOK
+	responses := []byte{'['}
+	for i, params := range operations {
+		recorder := &responseRecorder{header: make(http.Header)}
+		h.serveQuery(recorder, &plain, params)
+
+		if recorder.code == 0 {
+			// The request was canceled before the query responded; the
+			// client is gone, so there is nothing to write.
+			return
+		}
+		if h.partialStatus != 0 && recorder.code == h.partialStatus {
+			status = h.partialStatus
+		} else if recorder.code != http.StatusOK {
+			// The query failed to serialize; there is no response to add to
+			// the batch.
+			http.Error(w, strings.TrimSpace(recorder.body.String()), recorder.code)
+			return
+		}
+		if retryAfter := recorder.header.Get("Retry-After"); retryAfter != "" {
+			w.Header().Set("Retry-After", retryAfter)
+		}
+
+		if i > 0 {
+			responses = append(responses, ',')
+		}
+		responses = append(responses, bytes.TrimSuffix(recorder.body.Bytes(), []byte{'\n'})...)
+	}
+	responses = append(responses, ']', '\n')
+
+	h.writeBody(w, r, status, responses)
+}
+
+// responseRecorder is an http.ResponseWriter that buffers the response to a
+// single query of a batch.
+type responseRecorder struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *responseRecorder) WriteHeader(code int) {
+	if r.code == 0 {
+		r.code = code
+	}
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	r.WriteHeader(http.StatusOK)
+	return r.body.Write(p)
+}
+
+// serveQuery parses, validates, and executes a single query, and writes its
+// response to w.
+func (h *httpHandler) serveQuery(w http.ResponseWriter, r *http.Request, params *httpPostBody) {
+	op := params.OperationName
+	if h.logSlowQuery != nil {
+		start := time.Now()
+		defer func() {
+			if d := time.Since(start); d > h.slowQuery {
+				h.logSlowQuery(op, params.Query, redactVariables(params.Variables).(map[string]interface{}), d)
+			}
+		}()
+	}
+
+	var queryText *string
+	var extensions map[string]interface{}
+	reportError := func(err error) {
+		h.reportError(r.Context(), err, queryText)
+	}
+
+	// fieldErrors holds the errors of the fields that failed in a partially
+	// successful query, so they can be reported alongside its data.
+	var fieldErrors []error
+
+	var writeResponse func(value interface{}, err error)
+	writeResponse = func(value interface{}, err error) {
+		failed := err != nil
+		response := httpResponse{Data: value, Extensions: extensions}
+		if failed {
+			errs := []error{err}
+			if value != nil && len(fieldErrors) > 0 {
+				errs = fieldErrors
+			}
+			formatted := make([]*GraphQLError, 0, len(errs))
+			for _, err := range errs {
+				reportError(err)
+				formatted = append(formatted, FormatError(err))
+			}
+			response.Errors = h.filterErrors(formatted)
+			if retryable, ok := ErrorCause(err).(RetryableError); ok {
+				w.Header().Set("Retry-After", retryAfterSeconds(retryable.RetryAfter()))
+			}
+		}
+
+		status := http.StatusOK
+		if failed && value != nil && h.partialStatus != 0 {
+			status = h.partialStatus
+		}
+
+		if containsReader(response.Data) {
+			// Stream the response so that readers are never held in memory.
+			if err := h.writeBodyFunc(w, r, status, func(writer io.Writer) error {
+				if h.maxResponse > 0 {
+					writer = &limitedWriter{w: writer, remaining: h.maxResponse, err: h.errResponseTooLarge()}
+				}
+				if err := writeResponseJSON(writer, response); err != nil {
+					return err
+				}
+				_, err := io.WriteString(writer, "\n")
+				return err
+			}); err != nil {
+				reportError(err)
+			}
+			return
+		}
+
+		responseJSON, err := marshalResponse(response)
+		if err != nil {
+			reportError(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		responseJSON = append(responseJSON, '\n')
+		if response.Data != nil && h.maxResponse > 0 && len(responseJSON) > h.maxResponse {
+			writeResponse(nil, h.errResponseTooLarge())
+			return
+		}
+		h.writeBody(w, r, status, responseJSON)
+	}
+
+	queryText = &params.Query
+
+	if err := h.resolvePersistedQuery(params); err != nil {
+		writeResponse(nil, err)
+		return
+	}
+	if err := h.checkVariables(params.Variables); err != nil {
 		writeResponse(nil, err)
 		return
 	}
@@ -72,18 +681,40 @@ func (h *httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		writeResponse(nil, err)
 		return
 	}
+	op = query.Name
+	if params.OperationName != "" && params.OperationName != query.Name {
+		writeResponse(nil, newValidationError("unknown operation %q", params.OperationName))
+		return
+	}
+	// GET requests may be cached or replayed by browsers and proxies, so they
+	// must not have side effects.
+	if r.Method == "GET" && query.Kind == "mutation" {
+		writeResponse(nil, newValidationError("mutations must be sent in a POST request"))
+		return
+	}
+	if query.Kind == "subscription" {
+		writeResponse(nil, newValidationError("subscriptions must be sent over a websocket"))
+		return
+	}
 
 	schema := h.schema.Query
 	if query.Kind == "mutation" {
 		schema = h.schema.Mutation
 	}
-	if err := PrepareQuery(schema, query.SelectionSet); err != nil {
+	if err := PrepareQuery(schema, query.SelectionSet, h.prepareOptions...); err != nil {
 		writeResponse(nil, err)
 		return
 	}
 
 	var wg sync.WaitGroup
-	e := Executor{}
+	e := Executor{
+		OnFieldResolved:       h.fieldMetrics,
+		MaxTotalResolverTime:  h.resolverTime,
+		MaxComplexity:         h.maxComplexity,
+		ExpensiveFieldTimeout: h.expensiveLimit,
+		Tracing:               h.tracing,
+		PostProcess:           h.postProcess,
+	}
 
 	wg.Add(1)
 	runner := reactive.NewRerunner(r.Context(), func(ctx context.Context) (interface{}, error) {
@@ -95,7 +726,20 @@ func (h *httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		middlewares = append(middlewares, h.middlewares...)
 		middlewares = append(middlewares, func(input *ComputationInput, next MiddlewareNextFunc) *ComputationOutput {
 			output := next(input)
-			output.Current, output.Error = e.Execute(input.Ctx, schema, nil, input.ParsedQuery)
+			if err := h.prepareRewritten(schema, query, input); err != nil {
+				output.Error = err
+				return output
+			}
+			execute := func() (interface{}, error) {
+				current, err := e.Execute(input.Ctx, schema, nil, input.ParsedQuery)
+				e.writeMetadata(output.Metadata)
+				return current, err
+			}
+			if h.cache != nil && query.Kind != "mutation" {
+				h.executeCached(input, output, execute)
+			} else {
+				output.Current, output.Error = execute()
+			}
 			return output
 		})
 
@@ -106,13 +750,22 @@ func (h *httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			Variables:   params.Variables,
 		})
 		current, err := output.Current, output.Error
+		fieldErrors = e.Errors()
+		extensions = h.responseExtensions(ctx, &e, output.Metadata)
+
+		if err != nil && current != nil {
+			if rateErr := h.checkErrorRate(&e); rateErr != nil {
+				current, err = nil, rateErr
+			}
+		}
 
 		if err != nil {
 			if ErrorCause(err) == context.Canceled {
 				return nil, err
 			}
 
-			writeResponse(nil, err)
+			// A query whose failed fields were nulled out still has data.
+			writeResponse(current, err)
 			return nil, err
 		}
 
@@ -123,3 +776,231 @@ func (h *httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	wg.Wait()
 	runner.Stop()
 }
+
+// redactedValue replaces the values of variables in slow query logs.
+const redactedValue = "[REDACTED]"
+
+// redactVariables returns a copy of value, a variable's value as decoded from
+// JSON, with every string, number, and boolean replaced by redactedValue.
+func redactVariables(value interface{}) interface{} {
+	switch value := value.(type) {
+	case nil:
+		return nil
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(value))
+		for k, v := range value {
+			redacted[k] = redactVariables(v)
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(value))
+		for i, v := range value {
+			redacted[i] = redactVariables(v)
+		}
+		return redacted
+	default:
+		return redactedValue
+	}
+}
+
+// responseExtensions returns the extensions of the response to a query that
+// e executed, with the metadata reported by middlewares.
+func (h *httpHandler) responseExtensions(ctx context.Context, e *Executor, metadata map[string]interface{}) map[string]interface{} {
+	var extensions map[string]interface{}
+	if h.extensions != nil {
+		extensions = h.extensions(ctx, metadata)
+	}
+	trace := e.Trace()
+	if trace == nil {
+		return extensions
+	}
+
+	// Copy the extensions, which may be shared between responses.
+	withTrace := make(map[string]interface{}, len(extensions)+1)
+	for k, v := range extensions {
+		withTrace[k] = v
+	}
+	withTrace["tracing"] = trace
+	return withTrace
+}
+
+// reportError reports err to the handler's onError, if any.
+func (h *httpHandler) reportError(ctx context.Context, err error, query *string) {
+	if h.onError != nil {
+		h.onError(ctx, err, query)
+	}
+}
+
+// writeError responds to a request that failed before its query could be
+// read.
+func (h *httpHandler) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	h.reportError(r.Context(), err, nil)
+	responseJSON, err := json.Marshal(httpResponse{Errors: h.filterErrors([]*GraphQLError{FormatError(err)})})
+	if err != nil {
+		h.reportError(r.Context(), err, nil)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.writeBody(w, r, http.StatusOK, append(responseJSON, '\n'))
+}
+
+var errReadTimeout = errors.New("timed out reading request body")
+
+var errBodyTooLarge = errors.New("request body too large")
+
+// readBody decodes the request body, giving up with errReadTimeout if it is
+// not received within the handler's read timeout.
+func (h *httpHandler) readBody(body io.Reader) ([]*httpPostBody, bool, error) {
+	if h.readTimeout <= 0 {
+		return decodePostBody(body)
+	}
+
+	type result struct {
+		operations []*httpPostBody
+		batched    bool
+		err        error
+	}
+	done := make(chan result, 1)
+	go func() {
+		operations, batched, err := decodePostBody(body)
+		done <- result{operations: operations, batched: batched, err: err}
+	}()
+
+	timer := time.NewTimer(h.readTimeout)
+	defer timer.Stop()
+	select {
+	case result := <-done:
+		return result.operations, result.batched, result.err
+	case <-timer.C:
+		return nil, false, errReadTimeout
+	}
+}
+
+// abortRequest responds with err and then closes the client's connection,
+// which unblocks any read of the request body still in progress. If w does
+// not support hijacking, the response is written but the connection is left
+// to the server.
+func (h *httpHandler) abortRequest(w http.ResponseWriter, err error) {
+	responseJSON, err := json.Marshal(httpResponse{Errors: h.filterErrors([]*GraphQLError{FormatError(err)})})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	responseJSON = append(responseJSON, '\n')
+
+	// The response must carry its length: the connection is closed as soon as
+	// it is flushed, so a chunked response would never be terminated.
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Length", strconv.Itoa(len(responseJSON)))
+	w.Header().Set("Connection", "close")
+	w.WriteHeader(http.StatusOK)
+	w.Write(responseJSON)
+
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	if hijacker, ok := w.(http.Hijacker); ok {
+		if conn, _, err := hijacker.Hijack(); err == nil {
+			conn.Close()
+		}
+	}
+}
+
+// errResponseTooLarge returns the error for a response larger than the
+// handler's maximum.
+func (h *httpHandler) errResponseTooLarge() error {
+	return ClientError{
+		message: fmt.Sprintf("response too large: exceeds %d bytes", h.maxResponse),
+		code:    ErrorCodeResponseTooLarge,
+	}
+}
+
+// limitedWriter writes to w until remaining bytes have been written, and
+// then fails with err.
+type limitedWriter struct {
+	w         io.Writer
+	remaining int
+	err       error
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	if len(p) > l.remaining {
+		return 0, l.err
+	}
+	l.remaining -= len(p)
+	return l.w.Write(p)
+}
+
+// limitedReader reads from r until remaining bytes have been read, and then
+// fails with errBodyTooLarge if r has more.
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	if int64(n) > l.remaining {
+		n = int(l.remaining)
+		l.remaining = 0
+		return n, errBodyTooLarge
+	}
+	l.remaining -= int64(n)
+	return n, err
+}
+
+// filterErrors applies the handler's error filter to errors.
+func (h *httpHandler) filterErrors(errs []*GraphQLError) []*GraphQLError {
+	if h.errorFilter == nil {
+		return errs
+	}
+	if errs = h.errorFilter(errs); len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// writeBody writes a response with the given status, compressing it if the
+// client accepts one of the handler's encoders.
+func (h *httpHandler) writeBody(w http.ResponseWriter, r *http.Request, status int, body []byte) {
+	h.writeBodyFunc(w, r, status, func(writer io.Writer) error {
+		_, err := writer.Write(body)
+		return err
+	})
+}
+
+// writeBodyFunc writes a response with the given status whose body is written
+// by write, compressing it if the client accepts one of the handler's
+// encoders. Since the response's status has already been sent, an error from
+// write can only cut the response short.
+func (h *httpHandler) writeBodyFunc(w http.ResponseWriter, r *http.Request, status int, write func(io.Writer) error) error {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if len(h.encoders) > 0 {
+		w.Header().Add("Vary", "Accept-Encoding")
+	}
+
+	encoder := negotiateEncoder(r, h.encoders)
+	if encoder == nil {
+		w.WriteHeader(status)
+		return write(w)
+	}
+
+	var writer io.WriteCloser
+	if h.minCompress > 0 {
+		writer = &thresholdWriter{w: w, status: status, encoder: encoder, min: h.minCompress}
+	} else {
+		w.Header().Set("Content-Encoding", encoder.Encoding)
+		w.WriteHeader(status)
+		writer = encoder.NewWriter(w)
+	}
+	if err := write(writer); err != nil {
+		writer.Close()
+		return err
+	}
+	return writer.Close()
+}
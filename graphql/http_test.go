@@ -1,10 +1,27 @@
 package graphql_test
 
 import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
+	"testing/iotest"
+	"time"
 
 	"github.com/kylelemons/godebug/pretty"
 
@@ -13,24 +30,34 @@ import (
 )
 
 func testHTTPRequest(req *http.Request) *httptest.ResponseRecorder {
+	return testHTTPRequestWithOptions(req)
+}
+
+func testHTTPRequestWithOptions(req *http.Request, opts ...graphql.HTTPOption) *httptest.ResponseRecorder {
 	schema := schemabuilder.NewSchema()
 
 	query := schema.Query()
 	query.FieldFunc("mirror", func(args struct{ Value int64 }) int64 {
 		return args.Value * -1
 	})
+	query.FieldFunc("ratelimited", func() (int64, error) {
+		return 0, graphql.NewRetryableError(1500*time.Millisecond, "slow down")
+	})
+	query.FieldFunc("flaky", func() (*int64, error) {
+		return nil, errors.New("flaky failed")
+	})
 
 	builtSchema := schema.MustBuild()
 
 	rr := httptest.NewRecorder()
-	handler := graphql.HTTPHandler(builtSchema)
+	handler := graphql.HTTPHandlerWithOptions(builtSchema, opts...)
 
 	handler.ServeHTTP(rr, req)
 	return rr
 }
 
 func TestHTTPMustPost(t *testing.T) {
-	req, err := http.NewRequest("GET", "/graphql", nil)
+	req, err := http.NewRequest("PUT", "/graphql", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -41,11 +68,101 @@ func TestHTTPMustPost(t *testing.T) {
 		t.Errorf("expected 200, but received %d", rr.Code)
 	}
 
-	if diff := pretty.Compare(rr.Body.String(), "{\"data\":null,\"errors\":[\"request must be a POST\"]}\n"); diff != "" {
+	if diff := pretty.Compare(rr.Body.String(), "{\"data\":null,\"errors\":[{\"message\":\"request must be a GET or POST\",\"extensions\":{\"code\":\"GRAPHQL_VALIDATION_FAILED\"}}]}\n"); diff != "" {
 		t.Errorf("expected response to match, but received %s", diff)
 	}
 }
 
+func TestHTTPGet(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		params   url.Values
+		expected string
+	}{
+		{
+			name:     "query",
+			params:   url.Values{"query": {"query Q($value: int64!) { mirror(value: $value) }"}, "variables": {`{"value": 1}`}, "operationName": {"Q"}},
+			expected: "{\"data\":{\"mirror\":-1},\"errors\":null}\n",
+		},
+		{
+			name:     "mutation",
+			params:   url.Values{"query": {"mutation { mirror(value: 1) }"}},
+			expected: "{\"data\":null,\"errors\":[{\"message\":\"mutations must be sent in a POST request\",\"extensions\":{\"code\":\"GRAPHQL_VALIDATION_FAILED\"}}]}\n",
+		},
+		{
+			name:     "malformed variables",
+			params:   url.Values{"query": {"{ mirror(value: 1) }"}, "variables": {`{"value":`}},
+			expected: "{\"data\":null,\"errors\":[{\"message\":\"invalid variables: unexpected end of JSON input\",\"extensions\":{\"code\":\"GRAPHQL_VALIDATION_FAILED\"}}]}\n",
+		},
+		{
+			name:     "unknown operation",
+			params:   url.Values{"query": {"query Q { mirror(value: 1) }"}, "operationName": {"R"}},
+			expected: "{\"data\":null,\"errors\":[{\"message\":\"unknown operation \\\"R\\\"\",\"extensions\":{\"code\":\"GRAPHQL_VALIDATION_FAILED\"}}]}\n",
+		},
+	} {
+		req, err := http.NewRequest("GET", "/graphql?"+c.params.Encode(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := testHTTPRequest(req)
+		if diff := pretty.Compare(rr.Body.String(), c.expected); diff != "" {
+			t.Errorf("%s: expected response to match, but received %s", c.name, diff)
+		}
+	}
+}
+
+func TestHTTPBatch(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		body     string
+		opts     []graphql.HTTPOption
+		expected string
+	}{
+		{
+			name:     "batch",
+			body:     `[{"query": "{ mirror(value: 1) }"}, {"query": "{ unknown }"}, {"query": "{ mirror(value: 3) }"}]`,
+			expected: "[{\"data\":{\"mirror\":-1},\"errors\":null},{\"data\":null,\"errors\":[{\"message\":\"unknown field \\\"unknown\\\"\",\"extensions\":{\"code\":\"GRAPHQL_VALIDATION_FAILED\"}}]},{\"data\":{\"mirror\":-3},\"errors\":null}]\n",
+		},
+		{
+			name:     "empty batch",
+			body:     `[]`,
+			expected: "{\"data\":null,\"errors\":[{\"message\":\"batch must include a query\",\"extensions\":{\"code\":\"GRAPHQL_VALIDATION_FAILED\"}}]}\n",
+		},
+		{
+			name:     "oversized batch",
+			body:     `[{"query": "{ mirror(value: 1) }"}, {"query": "{ mirror(value: 2) }"}]`,
+			opts:     []graphql.HTTPOption{graphql.WithMaxBatchSize(1)},
+			expected: "{\"data\":null,\"errors\":[{\"message\":\"batch of 2 queries exceeds maximum of 1\",\"extensions\":{\"code\":\"GRAPHQL_VALIDATION_FAILED\"}}]}\n",
+		},
+	} {
+		req, err := http.NewRequest("POST", "/graphql", strings.NewReader(c.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := testHTTPRequestWithOptions(req, c.opts...)
+		if diff := pretty.Compare(rr.Body.String(), c.expected); diff != "" {
+			t.Errorf("%s: expected response to match, but received %s", c.name, diff)
+		}
+	}
+}
+
+func TestHTTPBatchCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	req, err := http.NewRequest("POST", "/graphql", strings.NewReader(`[{"query": "{ mirror(value: 1) }"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := testHTTPRequest(req.WithContext(ctx))
+	if rr.Code != http.StatusOK || rr.Body.Len() != 0 {
+		t.Errorf("expected an empty response, but received %d %q", rr.Code, rr.Body.String())
+	}
+}
+
 func TestHTTPParseQuery(t *testing.T) {
 	req, err := http.NewRequest("POST", "/graphql", nil)
 	if err != nil {
@@ -58,7 +175,7 @@ func TestHTTPParseQuery(t *testing.T) {
 		t.Errorf("expected 200, but received %d", rr.Code)
 	}
 
-	if diff := pretty.Compare(rr.Body.String(), "{\"data\":null,\"errors\":[\"request must include a query\"]}\n"); diff != "" {
+	if diff := pretty.Compare(rr.Body.String(), "{\"data\":null,\"errors\":[{\"message\":\"request must include a query\",\"extensions\":{\"code\":\"GRAPHQL_VALIDATION_FAILED\"}}]}\n"); diff != "" {
 		t.Errorf("expected response to match, but received %s", diff)
 	}
 }
@@ -75,7 +192,7 @@ func TestHTTPMustHaveQuery(t *testing.T) {
 		t.Errorf("expected 200, but received %d", rr.Code)
 	}
 
-	if diff := pretty.Compare(rr.Body.String(), "{\"data\":null,\"errors\":[\"must have a single query\"]}\n"); diff != "" {
+	if diff := pretty.Compare(rr.Body.String(), "{\"data\":null,\"errors\":[{\"message\":\"must have a single query\",\"extensions\":{\"code\":\"GRAPHQL_VALIDATION_FAILED\"}}]}\n"); diff != "" {
 		t.Errorf("expected response to match, but received %s", diff)
 	}
 }
@@ -96,3 +213,1076 @@ func TestHTTPSuccess(t *testing.T) {
 		t.Errorf("expected response to match, but received %s", diff)
 	}
 }
+
+func TestHTTPBody(t *testing.T) {
+	for _, c := range []struct {
+		body     string
+		response string
+	}{
+		{
+			`{"operationName": "Q", "variables": {"value": 3, "extra": {"list": [1, {"nested": null}], "flag": true}}, "query": "query Q($value: int64) { mirror(value: $value) }"}`,
+			"{\"data\":{\"mirror\":-3},\"errors\":null}\n",
+		},
+		{
+			`{"query": 1}`,
+			"{\"data\":null,\"errors\":[{\"message\":\"query must be a string\",\"extensions\":{\"code\":\"GRAPHQL_VALIDATION_FAILED\"}}]}\n",
+		},
+		{
+			`{"query": "{ mirror(value: 1) }", "variables": null}`,
+			"{\"data\":{\"mirror\":-1},\"errors\":null}\n",
+		},
+		{
+			`{"query": "{ mirror(value: 1) }", "variables": 5}`,
+			"{\"data\":null,\"errors\":[{\"message\":\"variables must be an object\",\"extensions\":{\"code\":\"GRAPHQL_VALIDATION_FAILED\"}}]}\n",
+		},
+		{
+			`{"query": "{ mirror(value: 1) }", "variables": []}`,
+			"{\"data\":null,\"errors\":[{\"message\":\"variables must be an object\",\"extensions\":{\"code\":\"GRAPHQL_VALIDATION_FAILED\"}}]}\n",
+		},
+		{
+			"\ufeff" + `{"query": "{ mirror(value: 1) }"}`,
+			"{\"data\":{\"mirror\":-1},\"errors\":null}\n",
+		},
+		{
+			"\ufeff" + `[{"query": "{ mirror(value: 2) }"}]`,
+			"[{\"data\":{\"mirror\":-2},\"errors\":null}]\n",
+		},
+		{
+			`{"query": "{ mirror(value: 1) }"`,
+			"{\"data\":null,\"errors\":[{\"message\":\"unexpected end of JSON input\",\"extensions\":{\"code\":\"GRAPHQL_VALIDATION_FAILED\"}}]}\n",
+		},
+	} {
+		req, err := http.NewRequest("POST", "/graphql", strings.NewReader(c.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := testHTTPRequest(req)
+
+		if diff := pretty.Compare(rr.Body.String(), c.response); diff != "" {
+			t.Errorf("expected response to match for %s, but received %s", c.body, diff)
+		}
+	}
+}
+
+func TestHTTPMaxBodySize(t *testing.T) {
+	body := `{"query": "{ mirror(value: 1) }", "variables": {"padding": "` + strings.Repeat("x", 1000) + `"}}`
+
+	req, err := http.NewRequest("POST", "/graphql", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := testHTTPRequestWithOptions(req, graphql.WithMaxBodySize(100))
+	if diff := pretty.Compare(rr.Body.String(), "{\"data\":null,\"errors\":[{\"message\":\"request body too large: exceeds 100 bytes\",\"extensions\":{\"code\":\"GRAPHQL_VALIDATION_FAILED\"}}]}\n"); diff != "" {
+		t.Errorf("expected response to match, but received %s", diff)
+	}
+
+	req, err = http.NewRequest("POST", "/graphql", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr = testHTTPRequestWithOptions(req, graphql.WithMaxBodySize(10000))
+	if diff := pretty.Compare(rr.Body.String(), "{\"data\":{\"mirror\":-1},\"errors\":null}\n"); diff != "" {
+		t.Errorf("expected response to match, but received %s", diff)
+	}
+}
+
+func TestHTTPReadTimeout(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("ok", func() bool { return true })
+	handler := graphql.HTTPHandlerWithOptions(schema.MustBuild(), graphql.WithReadTimeout(50*time.Millisecond))
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Promise a longer body than is sent, then stall.
+	body := `{"query": "{ ok }"`
+	if _, err := io.WriteString(conn, "POST /graphql HTTP/1.1\r\nHost: localhost\r\nContent-Length: 100\r\n\r\n"+body); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	bytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := pretty.Compare(string(bytes), "{\"data\":null,\"errors\":[{\"message\":\"timed out reading request body\",\"extensions\":{\"code\":\"GRAPHQL_VALIDATION_FAILED\"}}]}\n"); diff != "" {
+		t.Errorf("expected response to match, but received %s", diff)
+	}
+
+	// The server must have closed the connection.
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("expected connection to be closed, but received %v", err)
+	}
+}
+
+func TestHTTPErrorFilter(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		filter   func([]*graphql.GraphQLError) []*graphql.GraphQLError
+		expected string
+	}{
+		{
+			name: "rewrite",
+			filter: func(errs []*graphql.GraphQLError) []*graphql.GraphQLError {
+				for _, err := range errs {
+					err.Extensions["code"] = "RATE_LIMITED"
+				}
+				return errs
+			},
+			expected: "{\"data\":null,\"errors\":[{\"message\":\"slow down\",\"path\":[\"ratelimited\"],\"extensions\":{\"code\":\"RATE_LIMITED\",\"retryAfterMs\":1500,\"retryable\":true}}]}\n",
+		},
+		{
+			name: "drop",
+			filter: func(errs []*graphql.GraphQLError) []*graphql.GraphQLError {
+				return nil
+			},
+			expected: "{\"data\":null,\"errors\":null}\n",
+		},
+	} {
+		req, err := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query": "{ ratelimited }"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := testHTTPRequestWithOptions(req, graphql.WithErrorFilter(c.filter))
+		if diff := pretty.Compare(rr.Body.String(), c.expected); diff != "" {
+			t.Errorf("%s: expected response to match, but received %s", c.name, diff)
+		}
+	}
+}
+
+// streamingReader returns text one byte at a time, and records how much of
+// the response had been written when it was read to its end.
+type streamingReader struct {
+	text     io.Reader
+	recorder *httptest.ResponseRecorder
+	written  int
+}
+
+func (r *streamingReader) Read(p []byte) (int, error) {
+	n, err := iotest.OneByteReader(r.text).Read(p)
+	if err == io.EOF {
+		r.written = r.recorder.Body.Len()
+	}
+	return n, err
+}
+
+func TestHTTPStreamedReader(t *testing.T) {
+	text := strings.Repeat("line <1> \"quoted\" \u00e9\u4e16\U0001F600\n", 1000)
+	rr := httptest.NewRecorder()
+	reader := &streamingReader{text: strings.NewReader(text), recorder: rr}
+
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("report", func() io.Reader {
+		return reader
+	})
+	schema.Query().FieldFunc("none", func() io.Reader {
+		return nil
+	})
+	handler := graphql.HTTPHandler(schema.MustBuild())
+
+	req, err := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query": "{ report none }"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler.ServeHTTP(rr, req)
+
+	expected, err := json.Marshal(map[string]interface{}{
+		"data":   map[string]interface{}{"none": nil, "report": text},
+		"errors": nil,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := pretty.Compare(rr.Body.String(), string(expected)+"\n"); diff != "" {
+		t.Errorf("expected response to match, but received %s", diff)
+	}
+	if reader.written < len(text) {
+		t.Errorf("expected text to be streamed, but only %d bytes were written before it was read", reader.written)
+	}
+}
+
+func TestHTTPFieldLogMiddleware(t *testing.T) {
+	req, err := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query": "query Mirrors { a: mirror(value: 1) ...f } fragment f on Query { b: mirror(value: 2) __typename }"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ops []string
+	var fields [][]string
+	testHTTPRequestWithOptions(req, graphql.WithHTTPMiddlewares(graphql.FieldLogMiddleware(func(op string, f []string) {
+		ops = append(ops, op)
+		fields = append(fields, f)
+	})))
+
+	if diff := pretty.Compare(ops, []string{"Mirrors"}); diff != "" {
+		t.Errorf("expected logged operations to match, but received %s", diff)
+	}
+	if diff := pretty.Compare(fields, [][]string{{"__typename", "mirror"}}); diff != "" {
+		t.Errorf("expected logged fields to match, but received %s", diff)
+	}
+}
+
+func TestHTTPQueryLogMiddleware(t *testing.T) {
+	type entry struct {
+		Op    string
+		Query string
+		Err   string
+	}
+
+	for _, c := range []struct {
+		sampleRate float64
+		expected   []entry
+	}{
+		{0, []entry{{"Flaky", "query Flaky { flaky }", "Flaky.flaky: flaky failed"}}},
+		{1, []entry{
+			{"Mirror", "query Mirror { mirror(value: 1) }", ""},
+			{"Flaky", "query Flaky { flaky }", "Flaky.flaky: flaky failed"},
+		}},
+	} {
+		var logged []entry
+		middleware := graphql.QueryLogMiddleware(c.sampleRate, func(op, query string, dur time.Duration, err error) {
+			e := entry{Op: op, Query: query}
+			if err != nil {
+				e.Err = err.Error()
+			}
+			logged = append(logged, e)
+		})
+
+		for _, query := range []string{"query Mirror { mirror(value: 1) }", "query Flaky { flaky }"} {
+			req, err := http.NewRequest("GET", "/graphql?"+url.Values{"query": {query}}.Encode(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			testHTTPRequestWithOptions(req, graphql.WithHTTPMiddlewares(middleware))
+		}
+
+		if diff := pretty.Compare(logged, c.expected); diff != "" {
+			t.Errorf("expected logged queries to match at sample rate %v, but received %s", c.sampleRate, diff)
+		}
+	}
+}
+
+func TestHTTPSlowQueryLog(t *testing.T) {
+	type entry struct {
+		Op        string
+		Query     string
+		Variables map[string]interface{}
+	}
+
+	const query = "query Mirror($value: int64, $extra: Extra) { mirror(value: $value) }"
+	for _, c := range []struct {
+		threshold time.Duration
+		expected  []entry
+	}{
+		{time.Nanosecond, []entry{{"Mirror", query, map[string]interface{}{
+			"value": "[REDACTED]",
+			"extra": map[string]interface{}{"list": []interface{}{"[REDACTED]", nil}},
+		}}}},
+		{time.Hour, nil},
+	} {
+		req, err := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query": "`+query+`", "variables": {"value": 1, "extra": {"list": ["secret", null]}}}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var logged []entry
+		rr := testHTTPRequestWithOptions(req, graphql.WithSlowQueryLog(c.threshold, func(op, query string, variables map[string]interface{}, d time.Duration) {
+			if d <= c.threshold {
+				t.Errorf("expected only queries slower than %v to be logged, but received %v", c.threshold, d)
+			}
+			logged = append(logged, entry{Op: op, Query: query, Variables: variables})
+		}))
+
+		if diff := pretty.Compare(rr.Body.String(), "{\"data\":{\"mirror\":-1},\"errors\":null}\n"); diff != "" {
+			t.Errorf("expected response to match, but received %s", diff)
+		}
+		if diff := pretty.Compare(logged, c.expected); diff != "" {
+			t.Errorf("expected logged queries to match for threshold %v, but received %s", c.threshold, diff)
+		}
+	}
+}
+
+func TestHTTPCORS(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		opts     graphql.CORSOptions
+		method   string
+		headers  map[string]string
+		code     int
+		expected map[string]string
+	}{
+		{
+			name:   "preflight allowing all",
+			method: "OPTIONS",
+			headers: map[string]string{
+				"Origin":                         "https://app.example.com",
+				"Access-Control-Request-Method":  "POST",
+				"Access-Control-Request-Headers": "Content-Type, X-Token",
+			},
+			code: http.StatusNoContent,
+			expected: map[string]string{
+				"Access-Control-Allow-Origin":  "*",
+				"Access-Control-Allow-Methods": "GET, POST",
+				"Access-Control-Allow-Headers": "Content-Type, X-Token",
+			},
+		},
+		{
+			name: "preflight with options",
+			opts: graphql.CORSOptions{
+				AllowedOrigins:   []string{"https://app.example.com"},
+				AllowedMethods:   []string{"POST"},
+				AllowedHeaders:   []string{"Content-Type"},
+				AllowCredentials: true,
+				MaxAge:           10 * time.Minute,
+			},
+			method: "OPTIONS",
+			headers: map[string]string{
+				"Origin":                        "https://app.example.com",
+				"Access-Control-Request-Method": "POST",
+			},
+			code: http.StatusNoContent,
+			expected: map[string]string{
+				"Access-Control-Allow-Origin":      "https://app.example.com",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Allow-Methods":     "POST",
+				"Access-Control-Allow-Headers":     "Content-Type",
+				"Access-Control-Max-Age":           "600",
+				"Vary":                             "Origin",
+			},
+		},
+		{
+			name:   "preflight from another origin",
+			opts:   graphql.CORSOptions{AllowedOrigins: []string{"https://app.example.com"}},
+			method: "OPTIONS",
+			headers: map[string]string{
+				"Origin":                        "https://evil.example.com",
+				"Access-Control-Request-Method": "POST",
+			},
+			code:     http.StatusNoContent,
+			expected: map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:     "cross-origin POST",
+			opts:     graphql.CORSOptions{AllowedOrigins: []string{"https://app.example.com"}},
+			method:   "POST",
+			headers:  map[string]string{"Origin": "https://app.example.com"},
+			code:     http.StatusOK,
+			expected: map[string]string{"Access-Control-Allow-Origin": "https://app.example.com", "Vary": "Origin"},
+		},
+	} {
+		req, err := http.NewRequest(c.method, "/graphql", strings.NewReader(`{"query": "{ mirror(value: 1) }"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range c.headers {
+			req.Header.Set(k, v)
+		}
+
+		rr := testHTTPRequestWithOptions(req, graphql.WithCORS(c.opts))
+
+		if rr.Code != c.code {
+			t.Errorf("%s: expected %d, but received %d", c.name, c.code, rr.Code)
+		}
+		for k, v := range c.expected {
+			if got := rr.Header().Get(k); got != v {
+				t.Errorf("%s: expected %s to be %q, but received %q", c.name, k, v, got)
+			}
+		}
+		if c.method == "POST" && rr.Body.String() != "{\"data\":{\"mirror\":-1},\"errors\":null}\n" {
+			t.Errorf("%s: expected the query to be served, but received %s", c.name, rr.Body.String())
+		}
+	}
+}
+
+func TestHTTPCORSCredentials(t *testing.T) {
+	for _, origins := range [][]string{nil, {"https://app.example.com", "*"}} {
+		func() {
+			defer func() {
+				if r := recover(); r != "graphql: WithCORS requires explicit AllowedOrigins to allow credentials" {
+					t.Errorf("%v: expected WithCORS to refuse credentials from any origin, but received %v", origins, r)
+				}
+			}()
+			graphql.WithCORS(graphql.CORSOptions{AllowedOrigins: origins, AllowCredentials: true})
+		}()
+	}
+}
+
+func TestHTTPMaxResponseBytes(t *testing.T) {
+	for _, c := range []struct {
+		max      int
+		expected string
+	}{
+		{10, "{\"data\":null,\"errors\":[{\"message\":\"response too large: exceeds 10 bytes\",\"extensions\":{\"code\":\"RESPONSE_TOO_LARGE\"}}]}\n"},
+		{1000, "{\"data\":{\"mirror\":-1},\"errors\":null}\n"},
+	} {
+		req, err := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query": "{ mirror(value: 1) }"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := testHTTPRequestWithOptions(req, graphql.WithMaxResponseBytes(c.max))
+		if diff := pretty.Compare(rr.Body.String(), c.expected); diff != "" {
+			t.Errorf("expected response to match, but received %s", diff)
+		}
+	}
+
+	// Streamed responses are cut short.
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("report", func() io.Reader {
+		return strings.NewReader(strings.Repeat("x", 100000))
+	})
+	var reported []string
+	handler := graphql.HTTPHandlerWithOptions(schema.MustBuild(),
+		graphql.WithMaxResponseBytes(1000),
+		graphql.WithOnError(func(ctx context.Context, err error, query *string) {
+			reported = append(reported, err.Error())
+		}))
+
+	req, err := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query": "{ report }"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Body.Len() > 1000 {
+		t.Errorf("expected response to be cut short, but received %d bytes", rr.Body.Len())
+	}
+	if diff := pretty.Compare(reported, []string{"response too large: exceeds 1000 bytes"}); diff != "" {
+		t.Errorf("expected reported errors to match, but received %s", diff)
+	}
+}
+
+func TestHTTPResponseExtensions(t *testing.T) {
+	req, err := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query": "{ mirror(value: 1) }"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := testHTTPRequestWithOptions(req,
+		graphql.WithHTTPMiddlewares(func(input *graphql.ComputationInput, next graphql.MiddlewareNextFunc) *graphql.ComputationOutput {
+			output := next(input)
+			output.Metadata["cost"] = 42
+			return output
+		}),
+		graphql.WithResponseExtensions(func(ctx context.Context, metadata map[string]interface{}) map[string]interface{} {
+			return map[string]interface{}{"cost": metadata["cost"]}
+		}))
+
+	if diff := pretty.Compare(rr.Body.String(), "{\"data\":{\"mirror\":-1},\"errors\":null,\"extensions\":{\"cost\":42}}\n"); diff != "" {
+		t.Errorf("expected response to match, but received %s", diff)
+	}
+}
+
+func TestHTTPTracing(t *testing.T) {
+	type tracedUser struct {
+		Name string
+	}
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("users", func() []*tracedUser {
+		return []*tracedUser{{Name: "alice"}, {Name: "bob"}}
+	})
+	user := schema.Object("User", tracedUser{})
+	user.FieldFunc("friend", func(u *tracedUser) *tracedUser {
+		return &tracedUser{Name: u.Name + "'s friend"}
+	})
+	handler := graphql.HTTPHandlerWithOptions(schema.MustBuild(), graphql.WithTracing())
+
+	req, err := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query": "{ users { name friend { name } } }"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	var response struct {
+		Data       interface{}
+		Extensions struct {
+			Tracing graphql.Trace
+		}
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	tracing := response.Extensions.Tracing
+	if tracing.Version != 1 || tracing.Duration <= 0 || !tracing.EndTime.After(tracing.StartTime) {
+		t.Errorf("expected the query's timing, but received %+v", tracing)
+	}
+
+	type resolver struct {
+		Path       string
+		ParentType string
+		FieldName  string
+		ReturnType string
+	}
+	var resolvers []resolver
+	for _, r := range tracing.Execution.Resolvers {
+		if r.StartOffset < 0 || r.Duration < 0 || r.StartOffset+r.Duration > tracing.Duration {
+			t.Errorf("expected %v to run during the query, but received offset %d and duration %d", r.Path, r.StartOffset, r.Duration)
+		}
+		resolvers = append(resolvers, resolver{
+			Path:       fmt.Sprint(r.Path...),
+			ParentType: r.ParentType,
+			FieldName:  r.FieldName,
+			ReturnType: r.ReturnType,
+		})
+	}
+	sort.Slice(resolvers, func(i, j int) bool { return resolvers[i].Path < resolvers[j].Path })
+	if diff := pretty.Compare(resolvers, []resolver{
+		{Path: "users", ParentType: "Query", FieldName: "users", ReturnType: "[User!]!"},
+		{Path: "users0friend", ParentType: "User", FieldName: "friend", ReturnType: "User"},
+		{Path: "users0friendname", ParentType: "User", FieldName: "name", ReturnType: "string!"},
+		{Path: "users0name", ParentType: "User", FieldName: "name", ReturnType: "string!"},
+		{Path: "users1friend", ParentType: "User", FieldName: "friend", ReturnType: "User"},
+		{Path: "users1friendname", ParentType: "User", FieldName: "name", ReturnType: "string!"},
+		{Path: "users1name", ParentType: "User", FieldName: "name", ReturnType: "string!"},
+	}); diff != "" {
+		t.Errorf("expected resolvers to match, but received %s", diff)
+	}
+}
+
+func TestHTTPReadiness(t *testing.T) {
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("slow", func() bool {
+		close(started)
+		<-unblock
+		return true
+	})
+	readiness := &graphql.Readiness{}
+	handler := graphql.HTTPHandlerWithOptions(schema.MustBuild(), graphql.WithReadiness(readiness))
+
+	serve := func() *httptest.ResponseRecorder {
+		req, err := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query": "{ slow }"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	inFlight := make(chan *httptest.ResponseRecorder)
+	go func() {
+		inFlight <- serve()
+	}()
+	<-started
+
+	readiness.SetReady(false)
+	if readiness.InFlight() != 1 {
+		t.Errorf("expected 1 request in flight, but received %d", readiness.InFlight())
+	}
+	if rr := serve(); rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 while not ready, but received %d", rr.Code)
+	}
+
+	close(unblock)
+	rr := <-inFlight
+	if diff := pretty.Compare(rr.Body.String(), "{\"data\":{\"slow\":true},\"errors\":null}\n"); diff != "" {
+		t.Errorf("expected in-flight request to complete, but received %s", diff)
+	}
+	if readiness.InFlight() != 0 {
+		t.Errorf("expected no requests in flight, but received %d", readiness.InFlight())
+	}
+}
+
+func TestHTTPFieldMetrics(t *testing.T) {
+	type user struct{ Name string }
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("me", func() *user { return &user{Name: "alice"} })
+	schema.Object("user", user{}).FieldFunc("fail", func() (string, error) {
+		return "", errors.New("failed")
+	})
+
+	var mu sync.Mutex
+	var reported []string
+	handler := graphql.HTTPHandlerWithOptions(schema.MustBuild(), graphql.WithFieldMetrics(func(typeName, fieldName string, d time.Duration, errored bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		reported = append(reported, fmt.Sprintf("%s.%s errored=%v", typeName, fieldName, errored))
+	}))
+
+	for _, query := range []string{`{ me { name } }`, `{ me { fail } }`} {
+		req, err := http.NewRequest("POST", "/graphql", strings.NewReader(fmt.Sprintf(`{"query": %q}`, query)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if diff := pretty.Compare(reported, []string{
+		"Query.me errored=false",
+		"user.name errored=false",
+		"Query.me errored=false",
+		"user.fail errored=true",
+	}); diff != "" {
+		t.Errorf("expected reported fields to match, but received %s", diff)
+	}
+}
+
+func TestHTTPSetVariable(t *testing.T) {
+	req, err := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query": "query q($value: int64!) { mirror(value: $value) }", "variables": {"value": 500}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := testHTTPRequestWithOptions(req, graphql.WithHTTPMiddlewares(func(input *graphql.ComputationInput, next graphql.MiddlewareNextFunc) *graphql.ComputationOutput {
+		if value, ok := input.Variable("value"); ok && value.(float64) > 100 {
+			if err := input.SetVariable("value", float64(100)); err != nil {
+				return &graphql.ComputationOutput{Error: err}
+			}
+		}
+		return next(input)
+	}))
+
+	if diff := pretty.Compare(rr.Body.String(), "{\"data\":{\"mirror\":-100},\"errors\":null}\n"); diff != "" {
+		t.Errorf("expected response to match, but received %s", diff)
+	}
+}
+
+func TestHTTPSetVariablePrepares(t *testing.T) {
+	req, err := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query": "query q($show: Boolean!) { mirror(value: 1) bogus @include(if: $show) }", "variables": {"show": false}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The unknown field is skipped when the query is prepared, but the
+	// middleware includes it.
+	rr := testHTTPRequestWithOptions(req, graphql.WithHTTPMiddlewares(func(input *graphql.ComputationInput, next graphql.MiddlewareNextFunc) *graphql.ComputationOutput {
+		if err := input.SetVariable("show", true); err != nil {
+			return &graphql.ComputationOutput{Error: err}
+		}
+		return next(input)
+	}))
+
+	if diff := pretty.Compare(rr.Body.String(), "{\"data\":null,\"errors\":[{\"message\":\"unknown field \\\"bogus\\\"\",\"extensions\":{\"code\":\"GRAPHQL_VALIDATION_FAILED\"}}]}\n"); diff != "" {
+		t.Errorf("expected response to match, but received %s", diff)
+	}
+}
+
+func TestHTTPRejectionReason(t *testing.T) {
+	type rejection struct {
+		reason string
+		value  int64
+		limit  int64
+		ok     bool
+	}
+
+	for _, c := range []struct {
+		query    string
+		opts     []graphql.HTTPOption
+		expected rejection
+	}{
+		{`{ unknown }`, nil, rejection{graphql.RejectedUnknownField, 0, 0, true}},
+		{`{ a: mirror(value: 1) b: mirror(value: 2) }`, []graphql.HTTPOption{graphql.WithPrepareOptions(graphql.WithMaxSelections(1))}, rejection{graphql.RejectedWidth, 2, 1, true}},
+		{`{ a: mirror(value: 1) b: mirror(value: 2) }`, []graphql.HTTPOption{graphql.WithMaxComplexity(1)}, rejection{graphql.RejectedCost, 2, 1, true}},
+		{`{ mirror(value: 1) }`, []graphql.HTTPOption{graphql.WithMaxBodySize(10)}, rejection{graphql.RejectedSize, 72, 10, true}},
+		{`query Q($value: int64!) { mirror(value: $value) }`, []graphql.HTTPOption{graphql.WithMaxVariables(1)}, rejection{graphql.RejectedVariables, 2, 1, true}},
+		{`{ ratelimited }`, nil, rejection{}},
+	} {
+		req, err := http.NewRequest("POST", "/graphql", strings.NewReader(fmt.Sprintf(`{"query": %q, "variables": {"value": 1, "other": 2}}`, c.query)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var reported rejection
+		opts := append(c.opts, graphql.WithOnError(func(ctx context.Context, err error, query *string) {
+			reported.reason, reported.value, reported.limit, reported.ok = graphql.RejectionReason(err)
+		}))
+		testHTTPRequestWithOptions(req, opts...)
+
+		if reported != c.expected {
+			t.Errorf("%s: expected %v, but received %v", c.query, c.expected, reported)
+		}
+	}
+}
+
+func TestHTTPRetryableError(t *testing.T) {
+	req, err := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query": "{ ratelimited }"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := testHTTPRequest(req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected 200, but received %d", rr.Code)
+	}
+
+	if diff := pretty.Compare(rr.Header().Get("Retry-After"), "2"); diff != "" {
+		t.Errorf("expected Retry-After header to match, but received %s", diff)
+	}
+
+	if diff := pretty.Compare(rr.Body.String(), "{\"data\":null,\"errors\":[{\"message\":\"slow down\",\"path\":[\"ratelimited\"],\"extensions\":{\"code\":\"RETRYABLE\",\"retryAfterMs\":1500,\"retryable\":true}}]}\n"); diff != "" {
+		t.Errorf("expected response to match, but received %s", diff)
+	}
+}
+
+func TestHTTPPartialData(t *testing.T) {
+	req, err := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query": "{ mirror(value: 1) flaky }"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var reported []string
+	rr := testHTTPRequestWithOptions(req, graphql.WithOnError(func(ctx context.Context, err error, query *string) {
+		reported = append(reported, err.Error())
+	}))
+
+	if diff := pretty.Compare(rr.Body.String(), "{\"data\":{\"flaky\":null,\"mirror\":-1},\"errors\":[{\"message\":\"flaky failed\",\"path\":[\"flaky\"],\"extensions\":{\"code\":\"INTERNAL_SERVER_ERROR\"}}]}\n"); diff != "" {
+		t.Errorf("expected response to match, but received %s", diff)
+	}
+	if diff := pretty.Compare(reported, []string{"flaky: flaky failed"}); diff != "" {
+		t.Errorf("expected reported errors to match, but received %s", diff)
+	}
+}
+
+func TestHTTPStatusForPartialErrors(t *testing.T) {
+	for _, c := range []struct {
+		query    string
+		opts     []graphql.HTTPOption
+		expected int
+	}{
+		{`{ mirror(value: 1) flaky }`, nil, http.StatusOK},
+		{`{ mirror(value: 1) flaky }`, []graphql.HTTPOption{graphql.WithStatusForPartialErrors(http.StatusMultiStatus)}, http.StatusMultiStatus},
+		{`{ mirror(value: 1) }`, []graphql.HTTPOption{graphql.WithStatusForPartialErrors(http.StatusMultiStatus)}, http.StatusOK},
+		{`{ ratelimited }`, []graphql.HTTPOption{graphql.WithStatusForPartialErrors(http.StatusMultiStatus)}, http.StatusOK},
+	} {
+		req, err := http.NewRequest("POST", "/graphql", strings.NewReader(fmt.Sprintf(`{"query": %q}`, c.query)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := testHTTPRequestWithOptions(req, c.opts...)
+		if rr.Code != c.expected {
+			t.Errorf("%s: expected %d, but received %d", c.query, c.expected, rr.Code)
+		}
+	}
+}
+
+func TestHTTPMaxErrorRate(t *testing.T) {
+	for _, c := range []struct {
+		rate     float64
+		expected string
+	}{
+		{0.5, "{\"data\":{\"flaky\":null,\"mirror\":-1},\"errors\":[{\"message\":\"flaky failed\",\"path\":[\"flaky\"],\"extensions\":{\"code\":\"INTERNAL_SERVER_ERROR\"}}]}\n"},
+		{0.25, "{\"data\":null,\"errors\":[{\"message\":\"1 of 2 fields failed, exceeding the maximum error rate of 0.25\",\"extensions\":{\"code\":\"INTERNAL_SERVER_ERROR\"}}]}\n"},
+	} {
+		req, err := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query": "{ mirror(value: 1) flaky }"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := testHTTPRequestWithOptions(req, graphql.WithMaxErrorRate(c.rate))
+		if diff := pretty.Compare(rr.Body.String(), c.expected); diff != "" {
+			t.Errorf("%v: expected response to match, but received %s", c.rate, diff)
+		}
+	}
+}
+
+func TestHTTPOnError(t *testing.T) {
+	type report struct {
+		err   string
+		query *string
+	}
+	strPtr := func(s string) *string { return &s }
+
+	for _, c := range []struct {
+		method string
+		body   string
+		report *report
+	}{
+		{"PUT", "", &report{err: "request must be a GET or POST"}},
+		{"POST", `{"query": ""}`, &report{err: "must have a single query", query: strPtr("")}},
+		{"POST", `{"query": "{ missing }"}`, &report{err: `unknown field "missing"`, query: strPtr("{ missing }")}},
+		{"POST", `{"query": "{ ratelimited }"}`, &report{err: "ratelimited: slow down", query: strPtr("{ ratelimited }")}},
+		{"POST", `{"query": "{ mirror(value: 1) }"}`, nil},
+	} {
+		req, err := http.NewRequest(c.method, "/graphql", strings.NewReader(c.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var reports []*report
+		testHTTPRequestWithOptions(req, graphql.WithOnError(func(ctx context.Context, err error, query *string) {
+			reports = append(reports, &report{err: err.Error(), query: query})
+		}))
+
+		var expected []*report
+		if c.report != nil {
+			expected = []*report{c.report}
+		}
+		if diff := pretty.Compare(reports, expected); diff != "" {
+			t.Errorf("expected reported errors to match for %q, but received %s", c.body, diff)
+		}
+	}
+}
+
+// mapCache is a ResponseCache for tests.
+type mapCache struct {
+	mu      sync.Mutex
+	entries map[string][]byte
+	ttl     time.Duration
+}
+
+func (c *mapCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	response, ok := c.entries[key]
+	return response, ok
+}
+
+func (c *mapCache) Set(key string, response []byte, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = response
+	c.ttl = ttl
+}
+
+func TestHTTPResponseCache(t *testing.T) {
+	type userKey struct{}
+	cache := &mapCache{entries: make(map[string][]byte)}
+	executions, requests := 0, 0
+	countExecutions := func(typeName, fieldName string, d time.Duration, errored bool) {
+		executions++
+	}
+	// The middleware runs for cached responses too, and tells the cache who
+	// the caller is.
+	authenticate := func(input *graphql.ComputationInput, next graphql.MiddlewareNextFunc) *graphql.ComputationOutput {
+		requests++
+		user, _ := input.Variable("user")
+		input.Ctx = context.WithValue(input.Ctx, userKey{}, user)
+		return next(input)
+	}
+	scope := func(ctx context.Context) string {
+		return fmt.Sprint(ctx.Value(userKey{}))
+	}
+
+	for _, c := range []struct {
+		body       string
+		response   string
+		executions int
+	}{
+		{`{"query": "query Q($value: int64) { mirror(value: $value) }", "variables": {"value": 1, "user": "alice"}}`, "{\"data\":{\"mirror\":-1},\"errors\":null}\n", 1},
+		{`{"query": "query Q($value: int64) { mirror(value: $value) }", "variables": {"value": 1, "user": "alice"}}`, "{\"data\":{\"mirror\":-1},\"errors\":null}\n", 1},
+		{`{"query": "query Other {\n  mirror(value: 1)\n}", "variables": {"user": "alice"}}`, "{\"data\":{\"mirror\":-1},\"errors\":null}\n", 1},
+		{`{"query": "query Q($value: int64) { mirror(value: $value) }", "variables": {"value": 1, "user": "bob"}}`, "{\"data\":{\"mirror\":-1},\"errors\":null}\n", 2},
+		{`{"query": "query Q($value: int64) { mirror(value: $value) }", "variables": {"value": 2, "user": "alice"}}`, "{\"data\":{\"mirror\":-2},\"errors\":null}\n", 3},
+		{`{"query": "{ ratelimited }"}`, "{\"data\":null,\"errors\":[{\"message\":\"slow down\",\"path\":[\"ratelimited\"],\"extensions\":{\"code\":\"RETRYABLE\",\"retryAfterMs\":1500,\"retryable\":true}}]}\n", 4},
+		{`{"query": "{ ratelimited }"}`, "{\"data\":null,\"errors\":[{\"message\":\"slow down\",\"path\":[\"ratelimited\"],\"extensions\":{\"code\":\"RETRYABLE\",\"retryAfterMs\":1500,\"retryable\":true}}]}\n", 5},
+	} {
+		req, err := http.NewRequest("POST", "/graphql", strings.NewReader(c.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := testHTTPRequestWithOptions(req,
+			graphql.WithHTTPMiddlewares(authenticate),
+			graphql.WithFieldMetrics(countExecutions),
+			graphql.WithResponseCache(cache, time.Minute, scope))
+
+		if diff := pretty.Compare(rr.Body.String(), c.response); diff != "" {
+			t.Errorf("expected response to match for %s, but received %s", c.body, diff)
+		}
+		if executions != c.executions {
+			t.Errorf("expected %d executions after %s, but received %d", c.executions, c.body, executions)
+		}
+	}
+
+	if requests != 7 {
+		t.Errorf("expected the middleware to run for all 7 requests, but it ran for %d", requests)
+	}
+	if len(cache.entries) != 3 || cache.ttl != time.Minute {
+		t.Errorf("expected three responses to be cached for a minute, but received %d for %v", len(cache.entries), cache.ttl)
+	}
+}
+
+func TestHTTPPersistedQueries(t *testing.T) {
+	const query = "{ mirror(value: 1) }"
+	sum := sha256.Sum256([]byte(query))
+	hash := hex.EncodeToString(sum[:])
+	cache := graphql.NewLRUPersistedQueryCache(10)
+
+	for _, c := range []struct {
+		method   string
+		body     string
+		response string
+	}{
+		{
+			"POST",
+			`{"extensions": {"persistedQuery": {"version": 1, "sha256Hash": "` + hash + `"}}}`,
+			"{\"data\":null,\"errors\":[{\"message\":\"PersistedQueryNotFound\",\"extensions\":{\"code\":\"PERSISTED_QUERY_NOT_FOUND\"}}]}\n",
+		},
+		{
+			"POST",
+			`{"query": "{ mirror(value: 2) }", "extensions": {"persistedQuery": {"version": 1, "sha256Hash": "` + hash + `"}}}`,
+			"{\"data\":null,\"errors\":[{\"message\":\"provided sha256Hash does not match query\",\"extensions\":{\"code\":\"GRAPHQL_VALIDATION_FAILED\"}}]}\n",
+		},
+		{
+			"POST",
+			`{"extensions": {"persistedQuery": {"version": 1, "sha256Hash": "` + hash + `"}}}`,
+			"{\"data\":null,\"errors\":[{\"message\":\"PersistedQueryNotFound\",\"extensions\":{\"code\":\"PERSISTED_QUERY_NOT_FOUND\"}}]}\n",
+		},
+		{
+			"POST",
+			`{"query": "` + query + `", "extensions": {"persistedQuery": {"version": 1, "sha256Hash": "` + hash + `"}}}`,
+			"{\"data\":{\"mirror\":-1},\"errors\":null}\n",
+		},
+		{
+			"POST",
+			`{"extensions": {"persistedQuery": {"version": 1, "sha256Hash": "` + hash + `"}}}`,
+			"{\"data\":{\"mirror\":-1},\"errors\":null}\n",
+		},
+		{
+			"GET",
+			url.Values{"extensions": {`{"persistedQuery": {"version": 1, "sha256Hash": "` + hash + `"}}`}}.Encode(),
+			"{\"data\":{\"mirror\":-1},\"errors\":null}\n",
+		},
+		{
+			"POST",
+			`{"extensions": {"persistedQuery": {"version": 2, "sha256Hash": "` + hash + `"}}}`,
+			"{\"data\":null,\"errors\":[{\"message\":\"unsupported persistedQuery version\",\"extensions\":{\"code\":\"GRAPHQL_VALIDATION_FAILED\"}}]}\n",
+		},
+	} {
+		var req *http.Request
+		var err error
+		if c.method == "GET" {
+			req, err = http.NewRequest("GET", "/graphql?"+c.body, nil)
+		} else {
+			req, err = http.NewRequest("POST", "/graphql", strings.NewReader(c.body))
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := testHTTPRequestWithOptions(req, graphql.WithPersistedQueries(cache))
+		if diff := pretty.Compare(rr.Body.String(), c.response); diff != "" {
+			t.Errorf("expected response to match for %s, but received %s", c.body, diff)
+		}
+	}
+}
+
+func TestLRUPersistedQueryCache(t *testing.T) {
+	cache := graphql.NewLRUPersistedQueryCache(2)
+	cache.Set("a", "{ a }")
+	cache.Set("b", "{ b }")
+	if query, ok := cache.Get("a"); !ok || query != "{ a }" {
+		t.Errorf("expected a to be cached, got %q", query)
+	}
+	cache.Set("c", "{ c }")
+
+	if _, ok := cache.Get("b"); ok {
+		t.Error("expected the least recently used query to be evicted")
+	}
+	for _, hash := range []string{"a", "c"} {
+		if _, ok := cache.Get(hash); !ok {
+			t.Errorf("expected %s to be cached", hash)
+		}
+	}
+}
+
+// flateEncoder stands in for a Brotli implementation in tests.
+var flateEncoder = graphql.ResponseEncoder{
+	Encoding: "br",
+	NewWriter: func(w io.Writer) io.WriteCloser {
+		writer, _ := flate.NewWriter(w, flate.DefaultCompression)
+		return writer
+	},
+}
+
+func TestHTTPMinCompressSize(t *testing.T) {
+	for _, c := range []struct {
+		query           string
+		contentEncoding string
+		body            string
+	}{
+		{"{ mirror(value: 1) }", "", "{\"data\":{\"mirror\":-1},\"errors\":null}\n"},
+		{
+			"{ a: mirror(value: 1) b: mirror(value: 2) c: mirror(value: 3) d: mirror(value: 4) }",
+			"gzip",
+			"{\"data\":{\"a\":-1,\"b\":-2,\"c\":-3,\"d\":-4},\"errors\":null}\n",
+		},
+	} {
+		req, err := http.NewRequest("GET", "/graphql?"+url.Values{"query": {c.query}}.Encode(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Accept-Encoding", "gzip")
+
+		rr := testHTTPRequestWithOptions(req, graphql.WithResponseEncoders(graphql.GzipEncoder), graphql.WithMinCompressSize(50))
+
+		if diff := pretty.Compare(rr.Header().Get("Content-Encoding"), c.contentEncoding); diff != "" {
+			t.Errorf("expected Content-Encoding to match for %s, but received %s", c.query, diff)
+		}
+		var body io.Reader = rr.Body
+		if c.contentEncoding == "gzip" {
+			if body, err = gzip.NewReader(rr.Body); err != nil {
+				t.Fatal(err)
+			}
+		}
+		decoded, err := ioutil.ReadAll(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := pretty.Compare(string(decoded), c.body); diff != "" {
+			t.Errorf("expected response to match for %s, but received %s", c.query, diff)
+		}
+	}
+}
+
+func TestHTTPResponseEncoding(t *testing.T) {
+	const body = "{\"data\":{\"mirror\":-1},\"errors\":null}\n"
+
+	for _, c := range []struct {
+		acceptEncoding  string
+		contentEncoding string
+		decode          func(io.Reader) io.Reader
+	}{
+		{"gzip, deflate, br", "br", func(r io.Reader) io.Reader { return flate.NewReader(r) }},
+		{"gzip, br;q=0", "gzip", func(r io.Reader) io.Reader {
+			reader, err := gzip.NewReader(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return reader
+		}},
+		{"", "", func(r io.Reader) io.Reader { return r }},
+		{"identity", "", func(r io.Reader) io.Reader { return r }},
+	} {
+		req, err := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query": "{ mirror(value: 1) }"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Accept-Encoding", c.acceptEncoding)
+
+		rr := testHTTPRequestWithOptions(req, graphql.WithResponseEncoders(flateEncoder, graphql.GzipEncoder))
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("expected 200, but received %d", rr.Code)
+		}
+		if diff := pretty.Compare(rr.Header().Get("Content-Encoding"), c.contentEncoding); diff != "" {
+			t.Errorf("expected Content-Encoding to match for %q, but received %s", c.acceptEncoding, diff)
+		}
+
+		decoded, err := ioutil.ReadAll(c.decode(rr.Body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := pretty.Compare(string(decoded), body); diff != "" {
+			t.Errorf("expected response to match for %q, but received %s", c.acceptEncoding, diff)
+		}
+	}
+}
@@ -11,9 +11,10 @@ import (
 )
 
 type introspection struct {
-	types    map[string]graphql.Type
-	query    graphql.Type
-	mutation graphql.Type
+	types        map[string]graphql.Type
+	query        graphql.Type
+	mutation     graphql.Type
+	subscription graphql.Type
 }
 
 type DirectiveLocation string
@@ -41,10 +42,36 @@ const (
 )
 
 type InputValue struct {
-	Name         string
-	Description  string
-	Type         Type
-	DefaultValue *string
+	Name              string
+	Description       string
+	Type              Type
+	DefaultValue      *string
+	IsDeprecated      bool
+	DeprecationReason *string
+}
+
+// defaultValue returns the default value of name in defaults, if it has one.
+func defaultValue(defaults map[string]string, name string) *string {
+	if value, ok := defaults[name]; ok {
+		return &value
+	}
+	return nil
+}
+
+// newInputValue describes the input value name of type typ, given the
+// default values and deprecation reasons of its siblings.
+func newInputValue(name string, typ graphql.Type, defaults, deprecationReasons map[string]string) InputValue {
+	reason, deprecated := deprecationReasons[name]
+	value := InputValue{
+		Name:         name,
+		Type:         Type{Inner: typ},
+		DefaultValue: defaultValue(defaults, name),
+		IsDeprecated: deprecated,
+	}
+	if deprecated {
+		value.DeprecationReason = &reason
+	}
+	return value
 }
 
 func (s *introspection) registerInputValue(schema *schemabuilder.Schema) {
@@ -55,7 +82,7 @@ type EnumValue struct {
 	Name              string
 	Description       string
 	IsDeprecated      bool
-	DeprecationReason string
+	DeprecationReason *string
 }
 
 func (s *introspection) registerEnumValue(schema *schemabuilder.Schema) {
@@ -97,6 +124,8 @@ func (s *introspection) registerType(schema *schemabuilder.Schema) {
 			return OBJECT
 		case *graphql.Union:
 			return UNION
+		case *graphql.Interface:
+			return INTERFACE
 		case *graphql.Scalar:
 			return SCALAR
 		case *graphql.Enum:
@@ -118,6 +147,8 @@ func (s *introspection) registerType(schema *schemabuilder.Schema) {
 			return t.Name
 		case *graphql.Union:
 			return t.Name
+		case *graphql.Interface:
+			return t.Name
 		case *graphql.Scalar:
 			return t.Type
 		case *graphql.Enum:
@@ -135,12 +166,41 @@ func (s *introspection) registerType(schema *schemabuilder.Schema) {
 			return t.Description
 		case *graphql.Union:
 			return t.Description
+		case *graphql.Interface:
+			return t.Description
+		case *graphql.Scalar:
+			return t.Description
+		case *graphql.Enum:
+			return t.Description
 		default:
 			return ""
 		}
 	})
 
-	object.FieldFunc("interfaces", func() []Type { return nil })
+	object.FieldFunc("specifiedByURL", func(t Type) *string {
+		if t, ok := t.Inner.(*graphql.Scalar); ok && t.SpecifiedByURL != "" {
+			return &t.SpecifiedByURL
+		}
+		return nil
+	})
+
+	object.FieldFunc("interfaces", func(t Type) []Type {
+		object, ok := t.Inner.(*graphql.Object)
+		if !ok {
+			return nil
+		}
+
+		types := []Type{}
+		for _, typ := range s.types {
+			if iface, ok := typ.(*graphql.Interface); ok {
+				if _, ok := iface.Types[object.Name]; ok {
+					types = append(types, Type{Inner: iface})
+				}
+			}
+		}
+		sort.Slice(types, func(i, j int) bool { return types[i].Inner.String() < types[j].Inner.String() })
+		return types
+	})
 	object.FieldFunc("possibleTypes", func(t Type) []Type {
 		switch t := t.Inner.(type) {
 		case *graphql.Union:
@@ -149,6 +209,14 @@ func (s *introspection) registerType(schema *schemabuilder.Schema) {
 				types = append(types, Type{Inner: typ})
 			}
 
+			sort.Slice(types, func(i, j int) bool { return types[i].Inner.String() < types[j].Inner.String() })
+			return types
+		case *graphql.Interface:
+			types := make([]Type, 0, len(t.Types))
+			for _, typ := range t.Types {
+				types = append(types, Type{Inner: typ})
+			}
+
 			sort.Slice(types, func(i, j int) bool { return types[i].Inner.String() < types[j].Inner.String() })
 			return types
 		default:
@@ -162,10 +230,7 @@ func (s *introspection) registerType(schema *schemabuilder.Schema) {
 		switch t := t.Inner.(type) {
 		case *graphql.InputObject:
 			for name, f := range t.InputFields {
-				fields = append(fields, InputValue{
-					Name: name,
-					Type: Type{Inner: f},
-				})
+				fields = append(fields, newInputValue(name, f, t.DefaultValues, t.DeprecationReasons))
 			}
 		}
 
@@ -178,24 +243,33 @@ func (s *introspection) registerType(schema *schemabuilder.Schema) {
 	}) []field {
 		var fields []field
 
+		var graphqlFields map[string]*graphql.Field
 		switch t := t.Inner.(type) {
 		case *graphql.Object:
-			for name, f := range t.Fields {
-				var args []InputValue
-				for name, a := range f.Args {
-					args = append(args, InputValue{
-						Name: name,
-						Type: Type{Inner: a},
-					})
-				}
-				sort.Slice(args, func(i, j int) bool { return args[i].Name < args[j].Name })
-
-				fields = append(fields, field{
-					Name: name,
-					Type: Type{Inner: f.Type},
-					Args: args,
-				})
+			graphqlFields = t.Fields
+		case *graphql.Interface:
+			graphqlFields = t.Fields
+		}
+		includeDeprecated := args.IncludeDeprecated != nil && *args.IncludeDeprecated
+		for name, f := range graphqlFields {
+			if f.Deprecated && !includeDeprecated {
+				continue
 			}
+			var args []InputValue
+			for name, a := range f.Args {
+				args = append(args, newInputValue(name, a, f.ArgDefaultValues, f.ArgDeprecationReasons))
+			}
+			sort.Slice(args, func(i, j int) bool { return args[i].Name < args[j].Name })
+
+			fields = append(fields, field{
+				Name:        name,
+				Description: f.Description,
+				Type:        Type{Inner: f.Type},
+				Args:        args,
+
+				IsDeprecated:      f.Deprecated,
+				DeprecationReason: f.DeprecationReason,
+			})
 		}
 		sort.Slice(fields, func(i, j int) bool { return fields[i].Name < fields[j].Name })
 
@@ -219,11 +293,22 @@ func (s *introspection) registerType(schema *schemabuilder.Schema) {
 
 		switch t := t.Inner.(type) {
 		case *graphql.Enum:
+			includeDeprecated := args.IncludeDeprecated != nil && *args.IncludeDeprecated
 			var enumVals []EnumValue
 			for k, v := range t.ReverseMap {
-				val := fmt.Sprintf("%v", k)
-				enumVals = append(enumVals,
-					EnumValue{Name: v, Description: val, IsDeprecated: false, DeprecationReason: ""})
+				reason, deprecated := t.DeprecationReasons[v]
+				if deprecated && !includeDeprecated {
+					continue
+				}
+				description, ok := t.ValueDescriptions[v]
+				if !ok {
+					description = fmt.Sprintf("%v", k)
+				}
+				enumVal := EnumValue{Name: v, Description: description, IsDeprecated: deprecated}
+				if deprecated {
+					enumVal.DeprecationReason = &reason
+				}
+				enumVals = append(enumVals, enumVal)
 			}
 			sort.Slice(enumVals, func(i, j int) bool { return enumVals[i].Name < enumVals[j].Name })
 			return enumVals
@@ -238,7 +323,7 @@ type field struct {
 	Args              []InputValue
 	Type              Type
 	IsDeprecated      bool
-	DeprecationReason string
+	DeprecationReason *string
 }
 
 func (s *introspection) registerField(schema *schemabuilder.Schema) {
@@ -270,6 +355,22 @@ func collectTypes(typ graphql.Type, types map[string]graphql.Type) {
 			collectTypes(graphqlTyp, types)
 		}
 
+	case *graphql.Interface:
+		if _, ok := types[typ.Name]; ok {
+			return
+		}
+		types[typ.Name] = typ
+		for _, field := range typ.Fields {
+			collectTypes(field.Type, types)
+
+			for _, arg := range field.Args {
+				collectTypes(arg, types)
+			}
+		}
+		for _, graphqlTyp := range typ.Types {
+			collectTypes(graphqlTyp, types)
+		}
+
 	case *graphql.List:
 		collectTypes(typ.Type, types)
 
@@ -311,10 +412,16 @@ func (s *introspection) registerQuery(schema *schemabuilder.Schema) {
 		}
 		sort.Slice(types, func(i, j int) bool { return types[i].Inner.String() < types[j].Inner.String() })
 
+		var subscriptionType *Type
+		if s.subscription != nil {
+			subscriptionType = &Type{Inner: s.subscription}
+		}
+
 		return &Schema{
-			Types:        types,
-			QueryType:    &Type{Inner: s.query},
-			MutationType: &Type{Inner: s.mutation},
+			Types:            types,
+			QueryType:        &Type{Inner: s.query},
+			MutationType:     &Type{Inner: s.mutation},
+			SubscriptionType: subscriptionType,
 		}
 	})
 
@@ -344,14 +451,21 @@ func (s *introspection) schema() *graphql.Schema {
 	return schema.MustBuild()
 }
 
+// AddIntrospectionToSchema adds the __schema and __type introspection fields
+// to schema's Query object, for tools such as GraphiQL and code generators.
+// The fields describe the types reachable from the schema's roots. To serve
+// the schema without introspection, for example in production, prepare
+// queries with graphql.WithoutIntrospection.
 func AddIntrospectionToSchema(schema *graphql.Schema) {
 	types := make(map[string]graphql.Type)
 	collectTypes(schema.Query, types)
 	collectTypes(schema.Mutation, types)
+	collectTypes(schema.Subscription, types)
 	is := &introspection{
-		types:    types,
-		query:    schema.Query,
-		mutation: schema.Mutation,
+		types:        types,
+		query:        schema.Query,
+		mutation:     schema.Mutation,
+		subscription: schema.Subscription,
 	}
 	isSchema := is.schema()
 
@@ -1,10 +1,13 @@
 package introspection_test
 
 import (
+	"context"
 	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/samsarahq/go/snapshotter"
+	"github.com/samsarahq/thunder/graphql"
 	"github.com/samsarahq/thunder/graphql/introspection"
 	"github.com/samsarahq/thunder/graphql/schemabuilder"
 	"github.com/stretchr/testify/require"
@@ -111,6 +114,151 @@ func TestComputeSchemaJSON(t *testing.T) {
 	snap.Snapshot("schema", actual)
 }
 
+func TestSpecifiedByURL(t *testing.T) {
+	schemaBuilderSchema := schemabuilder.NewSchema()
+	query := schemaBuilderSchema.Query()
+	query.FieldFunc("now", func() time.Time { return time.Time{} })
+	query.FieldFunc("name", func() string { return "" })
+
+	schema := schemaBuilderSchema.MustBuild()
+	introspection.AddIntrospectionToSchema(schema)
+
+	q := graphql.MustParse(`{
+		time: __type(name: "Time") { specifiedByURL }
+		string: __type(name: "string") { specifiedByURL }
+	}`, nil)
+	require.NoError(t, graphql.PrepareQuery(schema.Query, q.SelectionSet))
+
+	e := graphql.Executor{}
+	value, err := e.Execute(context.Background(), schema.Query, nil, q)
+	require.NoError(t, err)
+
+	bytes, err := json.Marshal(value)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"time": {"specifiedByURL": "https://tools.ietf.org/html/rfc3339"},
+		"string": {"specifiedByURL": null}
+	}`, string(bytes))
+}
+
+func TestDeprecatedArgs(t *testing.T) {
+	schemaBuilderSchema := schemabuilder.NewSchema()
+	query := schemaBuilderSchema.Query()
+	query.FieldFunc("users", func(args struct {
+		First int64
+		Limit *int64 `graphql:",deprecated=use first"`
+	}) []string {
+		return nil
+	})
+
+	schema := schemaBuilderSchema.MustBuild()
+	introspection.AddIntrospectionToSchema(schema)
+
+	q := graphql.MustParse(`{
+		__type(name: "Query") { fields { name args { name isDeprecated deprecationReason } } }
+	}`, nil)
+	require.NoError(t, graphql.PrepareQuery(schema.Query, q.SelectionSet))
+
+	e := graphql.Executor{}
+	value, err := e.Execute(context.Background(), schema.Query, nil, q)
+	require.NoError(t, err)
+
+	bytes, err := json.Marshal(value)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"__type": {"fields": [{"name": "users", "args": [
+			{"name": "first", "isDeprecated": false, "deprecationReason": null},
+			{"name": "limit", "isDeprecated": true, "deprecationReason": "use first"}
+		]}]}
+	}`, string(bytes))
+}
+
+func TestDeprecatedFields(t *testing.T) {
+	schemaBuilderSchema := schemabuilder.NewSchema()
+	query := schemaBuilderSchema.Query()
+	query.FieldFunc("fullName", func() string { return "" })
+	query.FieldFunc("name", func() string { return "" }, schemabuilder.Deprecated("use fullName"))
+
+	schema := schemaBuilderSchema.MustBuild()
+	introspection.AddIntrospectionToSchema(schema)
+
+	q := graphql.MustParse(`{
+		all: __type(name: "Query") { fields(includeDeprecated: true) { name isDeprecated deprecationReason } }
+		current: __type(name: "Query") { fields { name } }
+	}`, nil)
+	require.NoError(t, graphql.PrepareQuery(schema.Query, q.SelectionSet))
+
+	e := graphql.Executor{}
+	value, err := e.Execute(context.Background(), schema.Query, nil, q)
+	require.NoError(t, err)
+
+	bytes, err := json.Marshal(value)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"all": {"fields": [
+			{"name": "fullName", "isDeprecated": false, "deprecationReason": null},
+			{"name": "name", "isDeprecated": true, "deprecationReason": "use fullName"}
+		]},
+		"current": {"fields": [{"name": "fullName"}]}
+	}`, string(bytes))
+}
+
+type Status int
+
+func TestDeprecatedEnumValues(t *testing.T) {
+	schemaBuilderSchema := schemabuilder.NewSchema()
+	schemaBuilderSchema.Enum(Status(0), map[string]Status{
+		"active":   0,
+		"inactive": 1,
+		"disabled": 2,
+	},
+		schemabuilder.EnumValueDescription("active", "The status can be used."),
+		schemabuilder.DeprecatedEnumValue("inactive", "use disabled"),
+	)
+	query := schemaBuilderSchema.Query()
+	query.FieldFunc("status", func() Status { return 0 })
+
+	schema := schemaBuilderSchema.MustBuild()
+	introspection.AddIntrospectionToSchema(schema)
+
+	q := graphql.MustParse(`{
+		all: __type(name: "Status") { enumValues(includeDeprecated: true) { name description isDeprecated deprecationReason } }
+		current: __type(name: "Status") { enumValues { name } }
+	}`, nil)
+	require.NoError(t, graphql.PrepareQuery(schema.Query, q.SelectionSet))
+
+	e := graphql.Executor{}
+	value, err := e.Execute(context.Background(), schema.Query, nil, q)
+	require.NoError(t, err)
+
+	bytes, err := json.Marshal(value)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"all": {"enumValues": [
+			{"name": "active", "description": "The status can be used.", "isDeprecated": false, "deprecationReason": null},
+			{"name": "disabled", "description": "2", "isDeprecated": false, "deprecationReason": null},
+			{"name": "inactive", "description": "1", "isDeprecated": true, "deprecationReason": "use disabled"}
+		]},
+		"current": {"enumValues": [{"name": "active"}, {"name": "disabled"}]}
+	}`, string(bytes))
+}
+
+func TestWithoutIntrospection(t *testing.T) {
+	schemaBuilderSchema := schemabuilder.NewSchema()
+	query := schemaBuilderSchema.Query()
+	query.FieldFunc("users", func() []string { return nil })
+
+	schema := schemaBuilderSchema.MustBuild()
+	introspection.AddIntrospectionToSchema(schema)
+
+	q := graphql.MustParse(`{ __typename users }`, nil)
+	require.NoError(t, graphql.PrepareQuery(schema.Query, q.SelectionSet, graphql.WithoutIntrospection()))
+
+	q = graphql.MustParse(`{ users ...f } fragment f on Query { __schema { types { name } } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(schema.Query, q.SelectionSet))
+	require.EqualError(t, graphql.PrepareQuery(schema.Query, q.SelectionSet, graphql.WithoutIntrospection()), `introspection is disabled: cannot select "__schema"`)
+}
+
 // Uuid is a stub version of a "Text Marshalable" type.
 type Uuid struct{}
 
@@ -0,0 +1,75 @@
+package graphql
+
+import (
+	"context"
+	"reflect"
+)
+
+// A list field's resolver may return its items lazily, for sources too large
+// to materialize, as a channel or a ListIterator instead of a slice. The
+// executor takes items until the source runs out or, if the field was
+// selected with a numeric "first" argument, until it has that many, and then
+// stops.
+//
+// A resolver that returns a channel should stop sending once its context is
+// done: the executor stops receiving after the items it needs, and the
+// context is canceled when the query finishes.
+
+// A ListIterator returns the next item of a list, or false once there are no
+// more items.
+type ListIterator func() (item interface{}, ok bool, err error)
+
+// collectList converts value, as returned by the resolver of a field of type
+// typ for selection, into a slice if it is a channel or a ListIterator.
+// Other values are returned as is.
+func collectList(ctx context.Context, typ Type, selection *Selection, value interface{}) (interface{}, error) {
+	if nonNull, ok := typ.(*NonNull); ok {
+		typ = nonNull.Type
+	}
+	if _, ok := typ.(*List); !ok {
+		return value, nil
+	}
+
+	limit := -1
+	if args, ok := selection.Args.(map[string]interface{}); ok {
+		if first, ok := args["first"].(float64); ok && first >= 0 {
+			limit = int(first)
+		}
+	}
+
+	if iterator, ok := value.(ListIterator); ok {
+		items := []interface{}{}
+		for limit < 0 || len(items) < limit {
+			item, ok, err := iterator()
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				break
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	}
+
+	channel := reflect.ValueOf(value)
+	if channel.Kind() != reflect.Chan {
+		return value, nil
+	}
+	cases := []reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: channel},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+	}
+	items := []interface{}{}
+	for limit < 0 || len(items) < limit {
+		chosen, item, ok := reflect.Select(cases)
+		if chosen == 1 {
+			return nil, ctx.Err()
+		}
+		if !ok {
+			break
+		}
+		items = append(items, item.Interface())
+	}
+	return items, nil
+}
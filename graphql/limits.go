@@ -0,0 +1,330 @@
+package graphql
+
+// This file contains limits that PrepareQuery can enforce on queries before
+// they are executed, to protect servers from queries that are too expensive.
+
+type prepareOptions struct {
+	maxSelections          int
+	maxExpensiveSelections int
+	maxDepth               int
+	withoutIntrospection   bool
+}
+
+// A PrepareOption configures the limits PrepareQuery enforces.
+type PrepareOption func(*prepareOptions)
+
+// WithMaxSelections limits the number of selections in a query after all
+// fragments are expanded. It rejects queries that reuse fragments to expand
+// into a huge number of fields. A limit of 0 means unlimited.
+func WithMaxSelections(max int) PrepareOption {
+	return func(o *prepareOptions) {
+		o.maxSelections = max
+	}
+}
+
+// WithMaxExpensiveSelections limits the number of selections of Expensive
+// fields in a query after all fragments are expanded, counting every alias of
+// a field separately. It rejects queries that alias an expensive field many
+// times to multiply its cost. A limit of 0 means unlimited.
+func WithMaxExpensiveSelections(max int) PrepareOption {
+	return func(o *prepareOptions) {
+		o.maxExpensiveSelections = max
+	}
+}
+
+// WithMaxDepth limits how deeply the selections of a query nest after all
+// fragments are expanded. Top-level fields have depth 1, and __typename is
+// not counted. It rejects deeply nested queries through recursive types. A
+// limit of 0 means unlimited.
+func WithMaxDepth(max int) PrepareOption {
+	return func(o *prepareOptions) {
+		o.maxDepth = max
+	}
+}
+
+// WithoutIntrospection rejects queries that select the __schema or __type
+// introspection fields, added by introspection.AddIntrospectionToSchema, so
+// that production servers can hide their schema while still serving it in
+// development. __typename is still allowed.
+func WithoutIntrospection() PrepareOption {
+	return func(o *prepareOptions) {
+		o.withoutIntrospection = true
+	}
+}
+
+// checkLimits checks that selectionSet, to be executed against typ, stays
+// within the limits in options.
+func checkLimits(typ Type, selectionSet *SelectionSet, options *prepareOptions) error {
+	if options.maxSelections > 0 {
+		count, err := countSelections(selectionSet, options.maxSelections)
+		if err != nil {
+			return err
+		}
+		if count > options.maxSelections {
+			return newRejectionError(RejectedWidth, int64(count), int64(options.maxSelections), "query exceeds maximum of %d selections", options.maxSelections)
+		}
+	}
+	if options.maxExpensiveSelections > 0 {
+		count, err := countExpensiveSelections(typ, selectionSet, options.maxExpensiveSelections)
+		if err != nil {
+			return err
+		}
+		if count > options.maxExpensiveSelections {
+			return newRejectionError(RejectedWidth, int64(count), int64(options.maxExpensiveSelections), "query exceeds maximum of %d selections of expensive fields", options.maxExpensiveSelections)
+		}
+	}
+	if options.withoutIntrospection {
+		if name, ok := selectsIntrospection(selectionSet, make(map[*SelectionSet]bool)); ok {
+			return newValidationError("introspection is disabled: cannot select %q", name)
+		}
+	}
+	if options.maxDepth > 0 {
+		depth, err := selectionDepth(selectionSet, options.maxDepth)
+		if err != nil {
+			return err
+		}
+		if depth > options.maxDepth {
+			return newRejectionError(RejectedDepth, int64(depth), int64(options.maxDepth), "query exceeds maximum depth of %d", options.maxDepth)
+		}
+	}
+	return nil
+}
+
+// countSelections counts the selections in selectionSet after expanding
+// fragments. Each selection set is counted once, so shared fragments don't
+// have to be expanded, and counting stops once the count exceeds max.
+func countSelections(selectionSet *SelectionSet, max int) (int, error) {
+	state := make(map[*SelectionSet]visitState)
+	counts := make(map[*SelectionSet]int)
+
+	var count func(*SelectionSet) (int, error)
+	count = func(selectionSet *SelectionSet) (int, error) {
+		if selectionSet == nil {
+			return 0, nil
+		}
+		switch state[selectionSet] {
+		case visiting:
+			return 0, newValidationError("fragment contains itself")
+		case visited:
+			return counts[selectionSet], nil
+		}
+		state[selectionSet] = visiting
+
+		total := 0
+		add := func(n int) {
+			total += n
+			if total > max {
+				total = max + 1
+			}
+		}
+		for _, selection := range selectionSet.Selections {
+			n, err := count(selection.SelectionSet)
+			if err != nil {
+				return 0, err
+			}
+			add(n + 1)
+		}
+		for _, fragment := range selectionSet.Fragments {
+			n, err := count(fragment.SelectionSet)
+			if err != nil {
+				return 0, err
+			}
+			add(n)
+		}
+
+		state[selectionSet] = visited
+		counts[selectionSet] = total
+		return total, nil
+	}
+
+	return count(selectionSet)
+}
+
+// countExpensiveSelections counts the selections of Expensive fields in
+// selectionSet, executed against typ, after expanding fragments. Like
+// countSelections, it counts each selection set once per type and stops once
+// the count exceeds max. Unknown fields are not counted; PrepareQuery rejects
+// them.
+func countExpensiveSelections(typ Type, selectionSet *SelectionSet, max int) (int, error) {
+	state := make(map[preparedSelectionSet]visitState)
+	counts := make(map[preparedSelectionSet]int)
+
+	var count func(Type, *SelectionSet) (int, error)
+	count = func(typ Type, selectionSet *SelectionSet) (int, error) {
+		switch t := typ.(type) {
+		case *NonNull:
+			return count(t.Type, selectionSet)
+		case *List:
+			return count(t.Type, selectionSet)
+		}
+		if selectionSet == nil {
+			return 0, nil
+		}
+
+		key := preparedSelectionSet{typ: typ, selectionSet: selectionSet}
+		switch state[key] {
+		case visiting:
+			return 0, newValidationError("fragment contains itself")
+		case visited:
+			return counts[key], nil
+		}
+		state[key] = visiting
+
+		total := 0
+		add := func(n int) {
+			total += n
+			if total > max {
+				total = max + 1
+			}
+		}
+		switch typ := typ.(type) {
+		case *Object:
+			for _, selection := range selectionSet.Selections {
+				field, ok := typ.Fields[selection.Name]
+				if !ok {
+					continue
+				}
+				n, err := count(field.Type, selection.SelectionSet)
+				if err != nil {
+					return 0, err
+				}
+				if field.Expensive {
+					n++
+				}
+				add(n)
+			}
+			for _, fragment := range selectionSet.Fragments {
+				n, err := count(typ, fragment.SelectionSet)
+				if err != nil {
+					return 0, err
+				}
+				add(n)
+			}
+		case *Interface:
+			for _, selection := range selectionSet.Selections {
+				field, ok := typ.Fields[selection.Name]
+				if !ok {
+					continue
+				}
+				n, err := count(field.Type, selection.SelectionSet)
+				if err != nil {
+					return 0, err
+				}
+				if field.Expensive {
+					n++
+				}
+				add(n)
+			}
+			for _, fragment := range selectionSet.Fragments {
+				var fragmentTyp Type = typ
+				if fragment.On != typ.Name {
+					object, ok := typ.Types[fragment.On]
+					if !ok {
+						continue
+					}
+					fragmentTyp = object
+				}
+				n, err := count(fragmentTyp, fragment.SelectionSet)
+				if err != nil {
+					return 0, err
+				}
+				add(n)
+			}
+		case *Union:
+			for _, fragment := range selectionSet.Fragments {
+				if fragmentTyp, ok := typ.Types[fragment.On]; ok {
+					n, err := count(fragmentTyp, fragment.SelectionSet)
+					if err != nil {
+						return 0, err
+					}
+					add(n)
+				}
+			}
+		}
+
+		state[key] = visited
+		counts[key] = total
+		return total, nil
+	}
+
+	return count(typ, selectionSet)
+}
+
+// selectionDepth returns how deeply the selections in selectionSet nest after
+// expanding fragments. Like countSelections, it visits each selection set
+// once, and stops once the depth exceeds max.
+func selectionDepth(selectionSet *SelectionSet, max int) (int, error) {
+	state := make(map[*SelectionSet]visitState)
+	depths := make(map[*SelectionSet]int)
+
+	var depth func(*SelectionSet) (int, error)
+	depth = func(selectionSet *SelectionSet) (int, error) {
+		if selectionSet == nil {
+			return 0, nil
+		}
+		switch state[selectionSet] {
+		case visiting:
+			return 0, newValidationError("fragment contains itself")
+		case visited:
+			return depths[selectionSet], nil
+		}
+		state[selectionSet] = visiting
+
+		deepest := 0
+		for _, selection := range selectionSet.Selections {
+			if deepest > max {
+				break
+			}
+			if selection.Name == "__typename" {
+				continue
+			}
+			d, err := depth(selection.SelectionSet)
+			if err != nil {
+				return 0, err
+			}
+			if d+1 > deepest {
+				deepest = d + 1
+			}
+		}
+		for _, fragment := range selectionSet.Fragments {
+			if deepest > max {
+				break
+			}
+			d, err := depth(fragment.SelectionSet)
+			if err != nil {
+				return 0, err
+			}
+			if d > deepest {
+				deepest = d
+			}
+		}
+
+		state[selectionSet] = visited
+		depths[selectionSet] = deepest
+		return deepest, nil
+	}
+
+	return depth(selectionSet)
+}
+
+// selectsIntrospection reports whether the top-level selections of
+// selectionSet, including those of its fragments, select an introspection
+// field, and returns the name of the field.
+func selectsIntrospection(selectionSet *SelectionSet, visited map[*SelectionSet]bool) (string, bool) {
+	if selectionSet == nil || visited[selectionSet] {
+		return "", false
+	}
+	visited[selectionSet] = true
+
+	for _, selection := range selectionSet.Selections {
+		if selection.Name == "__schema" || selection.Name == "__type" {
+			return selection.Name, true
+		}
+	}
+	for _, fragment := range selectionSet.Fragments {
+		if name, ok := selectsIntrospection(fragment.SelectionSet, visited); ok {
+			return name, true
+		}
+	}
+	return "", false
+}
@@ -2,6 +2,9 @@ package graphql
 
 import (
 	"context"
+	"math/rand"
+	"sort"
+	"time"
 )
 
 type ComputationInput struct {
@@ -15,6 +18,35 @@ type ComputationInput struct {
 	Extensions           map[string]interface{}
 }
 
+// Variable returns the value of the query's variable name, as decoded from
+// JSON. To read variables into Go types, use schemabuilder.BindVariables.
+func (input *ComputationInput) Variable(name string) (interface{}, bool) {
+	value, ok := input.Variables[name]
+	return value, ok
+}
+
+// SetVariable rewrites the query's variable name to value, which should be
+// like those generated by json.Unmarshal, and reparses ParsedQuery so that
+// the resolvers that take the variable as an argument see value. A
+// middleware can use it to enforce rules on variables, such as clamping a
+// page size, before calling next. The rewritten query is prepared again
+// before it is executed, so it is validated like the query received.
+func (input *ComputationInput) SetVariable(name string, value interface{}) error {
+	variables := make(map[string]interface{}, len(input.Variables)+1)
+	for k, v := range input.Variables {
+		variables[k] = v
+	}
+	variables[name] = value
+
+	parsed, err := Parse(input.Query, variables)
+	if err != nil {
+		return err
+	}
+	input.Variables = variables
+	input.ParsedQuery = parsed
+	return nil
+}
+
 type ComputationOutput struct {
 	Metadata map[string]interface{}
 	Current  interface{}
@@ -41,3 +73,51 @@ func RunMiddlewares(middlewares []MiddlewareFunc, input *ComputationInput) *Comp
 
 	return run(0, middlewares, input)
 }
+
+// FieldLogMiddleware returns a middleware that calls log with the name of
+// every query's operation and the names of the top-level fields it selects,
+// after expanding fragments, for auditing which fields are accessed. Fields
+// are listed once each, in sorted order, regardless of aliases.
+func FieldLogMiddleware(log func(op string, fields []string)) MiddlewareFunc {
+	return func(input *ComputationInput, next MiddlewareNextFunc) *ComputationOutput {
+		if input.ParsedQuery != nil {
+			log(input.ParsedQuery.Name, topLevelFields(input.ParsedQuery.SelectionSet))
+		}
+		return next(input)
+	}
+}
+
+// QueryLogMiddleware returns a middleware that calls log with the operation
+// name, text, duration, and error of a sampleRate fraction of queries, such as
+// 0.01 for 1%, chosen at random. Queries that fail are always logged.
+func QueryLogMiddleware(sampleRate float64, log func(op, query string, dur time.Duration, err error)) MiddlewareFunc {
+	return func(input *ComputationInput, next MiddlewareNextFunc) *ComputationOutput {
+		start := time.Now()
+		output := next(input)
+		if output.Error == nil && rand.Float64() >= sampleRate {
+			return output
+		}
+
+		var op string
+		if input.ParsedQuery != nil {
+			op = input.ParsedQuery.Name
+		}
+		log(op, input.Query, time.Since(start), output.Error)
+		return output
+	}
+}
+
+// topLevelFields returns the sorted, distinct names of the fields selected by
+// selectionSet.
+func topLevelFields(selectionSet *SelectionSet) []string {
+	seen := make(map[string]bool)
+	var fields []string
+	for _, selection := range Flatten(selectionSet) {
+		if !seen[selection.Name] {
+			seen[selection.Name] = true
+			fields = append(fields, selection.Name)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
@@ -0,0 +1,154 @@
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ObjectFromStruct builds an Object named name from the exported fields of
+// the struct type of example. Every field is resolved by reading the
+// corresponding struct field of the source, which may be a struct or a
+// pointer to one.
+//
+// Field names are the struct field names with their first letter lowercased,
+// and can be overridden with a `graphql:"name"` tag. Fields tagged
+// `graphql:"-"` are skipped. Field types are inferred from the Go types:
+// scalars, pointers to scalars (which are nullable), slices, and nested
+// structs, which become objects named after their Go type.
+func ObjectFromStruct(name string, example interface{}) (*Object, error) {
+	typ := reflect.TypeOf(example)
+	if typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("bad type %v: should be a struct", typ)
+	}
+
+	b := &structObjectBuilder{objects: make(map[reflect.Type]*Object)}
+	return b.buildObject(name, typ)
+}
+
+// structObjectBuilder builds the objects for ObjectFromStruct. It remembers
+// the objects built so far so that recursive structs reference themselves.
+type structObjectBuilder struct {
+	objects map[reflect.Type]*Object
+}
+
+func (b *structObjectBuilder) buildObject(name string, typ reflect.Type) (*Object, error) {
+	if object, ok := b.objects[typ]; ok {
+		return object, nil
+	}
+
+	object := &Object{
+		Name:   name,
+		Fields: make(map[string]*Field),
+	}
+	b.objects[typ] = object
+
+	for i := 0; i < typ.NumField(); i++ {
+		structField := typ.Field(i)
+		if structField.PkgPath != "" || structField.Anonymous {
+			continue
+		}
+
+		fieldName := strings.Split(structField.Tag.Get("graphql"), ",")[0]
+		if fieldName == "-" {
+			continue
+		}
+		if fieldName == "" {
+			fieldName = lowerFirst(structField.Name)
+		}
+		if _, ok := object.Fields[fieldName]; ok {
+			return nil, fmt.Errorf("bad type %s: two fields named %s", typ, fieldName)
+		}
+
+		fieldType, err := b.getType(structField.Type)
+		if err != nil {
+			return nil, fmt.Errorf("bad field %s on type %s: %s", structField.Name, typ, err)
+		}
+
+		object.Fields[fieldName] = &Field{
+			Resolve: structFieldResolver(structField.Index),
+			Type:    fieldType,
+			Args:    map[string]Type{},
+		}
+	}
+
+	return object, nil
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+var bytesType = reflect.TypeOf([]byte{})
+
+func (b *structObjectBuilder) getType(typ reflect.Type) (Type, error) {
+	switch {
+	case typ == timeType:
+		return &NonNull{Type: &Scalar{Type: "Time", SpecifiedByURL: "https://tools.ietf.org/html/rfc3339"}}, nil
+	case typ == bytesType:
+		return &NonNull{Type: &Scalar{Type: "bytes"}}, nil
+	}
+
+	switch typ.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return &NonNull{Type: &Scalar{Type: typ.Kind().String()}}, nil
+
+	case reflect.Ptr:
+		elemType, err := b.getType(typ.Elem())
+		if err != nil {
+			return nil, err
+		}
+		if nonNull, ok := elemType.(*NonNull); ok {
+			return nonNull.Type, nil
+		}
+		return elemType, nil
+
+	case reflect.Slice:
+		elemType, err := b.getType(typ.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &NonNull{Type: &List{Type: elemType}}, nil
+
+	case reflect.Struct:
+		if typ.Name() == "" {
+			return nil, fmt.Errorf("bad type %s: should have a name", typ)
+		}
+		object, err := b.buildObject(typ.Name(), typ)
+		if err != nil {
+			return nil, err
+		}
+		return &NonNull{Type: object}, nil
+
+	default:
+		return nil, fmt.Errorf("bad type %s: should be a scalar, slice, or struct type", typ)
+	}
+}
+
+// structFieldResolver returns a Resolver that reads the struct field at index
+// from its source.
+func structFieldResolver(index []int) Resolver {
+	return func(ctx context.Context, source, args interface{}, selectionSet *SelectionSet) (interface{}, error) {
+		value := reflect.ValueOf(source)
+		if value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				return nil, nil
+			}
+			value = value.Elem()
+		}
+		return value.FieldByIndex(index).Interface(), nil
+	}
+}
+
+// lowerFirst lowercases the first letter of s.
+func lowerFirst(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToLower(r)) + s[size:]
+}
@@ -1,6 +1,7 @@
 package graphql
 
 import (
+	"fmt"
 	"reflect"
 	"strconv"
 
@@ -19,14 +20,14 @@ func valueToJson(value ast.Value, vars map[string]interface{}) (interface{}, err
 	case *ast.IntValue:
 		v, err := strconv.ParseInt(value.Value, 10, 64)
 		if err != nil {
-			return nil, NewClientError("bad int arg: %s", err)
+			return nil, newValidationError("bad int arg: %s", err)
 		}
 		return float64(v), nil
 
 	case *ast.FloatValue:
 		v, err := strconv.ParseFloat(value.Value, 64)
 		if err != nil {
-			return nil, NewClientError("bad float arg: %s", err)
+			return nil, newValidationError("bad float arg: %s", err)
 		}
 		return v, nil
 	case *ast.StringValue:
@@ -46,7 +47,10 @@ func valueToJson(value ast.Value, vars map[string]interface{}) (interface{}, err
 		for _, field := range value.Fields {
 			name := field.Name.Value
 			if _, found := obj[name]; found {
-				return nil, NewClientError("duplicate field")
+				return nil, newValidationError("duplicate field")
+			}
+			if isMissingVariable(field.Value, vars) {
+				continue
 			}
 			value, err := valueToJson(field.Value, vars)
 			if err != nil {
@@ -66,8 +70,20 @@ func valueToJson(value ast.Value, vars map[string]interface{}) (interface{}, err
 		}
 		return list, nil
 	default:
-		return nil, NewClientError("unsupported value type: %s", value.GetKind())
+		return nil, newValidationError("unsupported value type: %s", value.GetKind())
+	}
+}
+
+// isMissingVariable reports whether value is a variable that was not given.
+// An argument or input field set to such a variable is omitted, rather than
+// null, so that resolvers can tell the two apart.
+func isMissingVariable(value ast.Value, vars map[string]interface{}) bool {
+	variable, ok := value.(*ast.Variable)
+	if !ok {
+		return false
 	}
+	_, given := vars[variable.Name.Value]
+	return !given
 }
 
 // argsToJson converts a graphql-go ast argument list to a json.Marshal-style
@@ -77,7 +93,10 @@ func argsToJson(input []*ast.Argument, vars map[string]interface{}) (interface{}
 	for _, arg := range input {
 		name := arg.Name.Value
 		if _, found := args[name]; found {
-			return nil, NewClientError("duplicate arg")
+			return nil, newValidationError(`duplicate argument "%s"`, name)
+		}
+		if isMissingVariable(arg.Value, vars) {
+			continue
 		}
 		value, err := valueToJson(arg.Value, vars)
 		if err != nil {
@@ -88,9 +107,73 @@ func argsToJson(input []*ast.Argument, vars map[string]interface{}) (interface{}
 	return args, nil
 }
 
+// includeDirective evaluates the @include and @skip directives of a
+// selection, and reports whether the selection is included.
+func includeDirective(directives []*ast.Directive, vars map[string]interface{}) (bool, error) {
+	include := true
+	for _, directive := range directives {
+		name := directive.Name.Value
+		if name != "include" && name != "skip" {
+			return false, newValidationError("directives not supported")
+		}
+
+		var condition ast.Value
+		for _, arg := range directive.Arguments {
+			if arg.Name.Value != "if" {
+				return false, newValidationError(`unknown argument "%s" for directive @%s`, arg.Name.Value, name)
+			}
+			condition = arg.Value
+		}
+		if condition == nil {
+			return false, newValidationError(`directive @%s requires argument "if" of type Boolean!`, name)
+		}
+
+		value, err := valueToJson(condition, vars)
+		if err != nil {
+			return false, err
+		}
+		b, ok := value.(bool)
+		if !ok {
+			received := "received " + describeValue(value)
+			if variable, ok := condition.(*ast.Variable); ok {
+				received = "variable $" + variable.Name.Value + " is " + describeValue(value)
+			}
+			return false, newValidationError(`directive @%s expects argument "if" of type Boolean!, but %s`, name, received)
+		}
+
+		if (name == "include") != b {
+			include = false
+		}
+	}
+	return include, nil
+}
+
+// describeValue describes the kind of a value like those generated by
+// json.Unmarshal, for error messages.
+func describeValue(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "a boolean"
+	case float64:
+		return "a number"
+	case string:
+		return "a string"
+	case []interface{}:
+		return "a list"
+	case map[string]interface{}:
+		return "an object"
+	default:
+		return fmt.Sprintf("a %T", value)
+	}
+}
+
 // parseSelectionSet takes a grapqhl-go selection set and converts it to a
-// simplified *SelectionSet, bindings vars
-func parseSelectionSet(input *ast.SelectionSet, globalFragments map[string]*Fragment, vars map[string]interface{}) (*SelectionSet, error) {
+// simplified *SelectionSet, bindings vars. Selections and fragments excluded
+// by @include or @skip are left out, and added to skipped instead, so that
+// the fragments they spread still count as used.
+func parseSelectionSet(input *ast.SelectionSet, globalFragments map[string]*Fragment, vars map[string]interface{}, skipped *SelectionSet) (*SelectionSet, error) {
 	if input == nil {
 		return nil, nil
 	}
@@ -105,8 +188,9 @@ func parseSelectionSet(input *ast.SelectionSet, globalFragments map[string]*Frag
 				alias = selection.Alias.Value
 			}
 
-			if len(selection.Directives) != 0 {
-				return nil, NewClientError("directives not supported")
+			include, err := includeDirective(selection.Directives, vars)
+			if err != nil {
+				return nil, err
 			}
 
 			args, err := argsToJson(selection.Arguments, vars)
@@ -114,48 +198,64 @@ func parseSelectionSet(input *ast.SelectionSet, globalFragments map[string]*Frag
 				return nil, err
 			}
 
-			selectionSet, err := parseSelectionSet(selection.SelectionSet, globalFragments, vars)
+			selectionSet, err := parseSelectionSet(selection.SelectionSet, globalFragments, vars, skipped)
 			if err != nil {
 				return nil, err
 			}
 
-			selections = append(selections, &Selection{
+			parsed := &Selection{
 				Alias:        alias,
 				Name:         selection.Name.Value,
 				Args:         args,
 				SelectionSet: selectionSet,
-			})
+			}
+			if !include {
+				skipped.Selections = append(skipped.Selections, parsed)
+				continue
+			}
+			selections = append(selections, parsed)
 
 		case *ast.FragmentSpread:
 			name := selection.Name.Value
 
-			if len(selection.Directives) != 0 {
-				return nil, NewClientError("directives not supported")
+			include, err := includeDirective(selection.Directives, vars)
+			if err != nil {
+				return nil, err
 			}
 
 			fragment, found := globalFragments[name]
 			if !found {
-				return nil, NewClientError("unknown fragment")
+				return nil, newValidationError("unknown fragment")
 			}
 
+			if !include {
+				skipped.Fragments = append(skipped.Fragments, fragment)
+				continue
+			}
 			fragments = append(fragments, fragment)
 
 		case *ast.InlineFragment:
 			on := selection.TypeCondition.Name.Value
 
-			if len(selection.Directives) != 0 {
-				return nil, NewClientError("directives not supported")
+			include, err := includeDirective(selection.Directives, vars)
+			if err != nil {
+				return nil, err
 			}
 
-			selectionSet, err := parseSelectionSet(selection.SelectionSet, globalFragments, vars)
+			selectionSet, err := parseSelectionSet(selection.SelectionSet, globalFragments, vars, skipped)
 			if err != nil {
 				return nil, err
 			}
 
-			fragments = append(fragments, &Fragment{
+			fragment := &Fragment{
 				On:           on,
 				SelectionSet: selectionSet,
-			})
+			}
+			if !include {
+				skipped.Fragments = append(skipped.Fragments, fragment)
+				continue
+			}
+			fragments = append(fragments, fragment)
 		}
 	}
 
@@ -175,8 +275,9 @@ const (
 )
 
 // detectCyclesAndUnusedFragments finds cycles in fragments that include
-// eachother as well as fragments that don't appear anywhere
-func detectCyclesAndUnusedFragments(selectionSet *SelectionSet, globalFragments map[string]*Fragment) error {
+// eachother as well as fragments that don't appear anywhere. skipped holds the
+// selections and fragments excluded by directives, which still use fragments.
+func detectCyclesAndUnusedFragments(selectionSet *SelectionSet, skipped *SelectionSet, globalFragments map[string]*Fragment) error {
 	state := make(map[*Fragment]visitState)
 
 	var visitFragment func(*Fragment) error
@@ -205,7 +306,7 @@ func detectCyclesAndUnusedFragments(selectionSet *SelectionSet, globalFragments
 	visitFragment = func(fragment *Fragment) error {
 		switch state[fragment] {
 		case visiting:
-			return NewClientError("fragment contains itself")
+			return newValidationError("fragment contains itself")
 		case visited:
 			return nil
 		}
@@ -222,16 +323,20 @@ func detectCyclesAndUnusedFragments(selectionSet *SelectionSet, globalFragments
 	if err := visitSelectionSet(selectionSet); err != nil {
 		return err
 	}
+	if err := visitSelectionSet(skipped); err != nil {
+		return err
+	}
 
 	for _, fragment := range globalFragments {
 		if state[fragment] != visited {
-			return NewClientError("unused fragment")
+			return newValidationError("unused fragment")
 		}
 	}
 	return nil
 }
 
-// detectConflicts finds conflicts
+// detectConflicts finds conflicts, in selectionSet and the selection sets
+// nested in it
 //
 // Conflicts are selections that can not be merged, for example
 //
@@ -240,9 +345,28 @@ func detectCyclesAndUnusedFragments(selectionSet *SelectionSet, globalFragments
 //
 // A query cannot contain both selections, because they have the same alias
 // with different source names, and they also have different arguments.
+//
+// Selections in fragments on different types, such as
+//
+//     ... on Dog { sound: bark }
+//     ... on Cat { sound: meow }
+//
+// are left to PrepareQuery, which knows whether the types are distinct
+// objects, of which only one applies to any value.
 func detectConflicts(selectionSet *SelectionSet) error {
 	state := make(map[*SelectionSet]visitState)
 
+	// scopedSelection is a selection along with the type condition of the
+	// innermost fragment it is in, or "" if it is in none.
+	type scopedSelection struct {
+		selection *Selection
+		on        string
+	}
+	type sibling struct {
+		selectionSet *SelectionSet
+		on           string
+	}
+
 	var visitChild func(*SelectionSet) error
 	visitChild = func(selectionSet *SelectionSet) error {
 		if state[selectionSet] == visited {
@@ -250,25 +374,50 @@ func detectConflicts(selectionSet *SelectionSet) error {
 		}
 		state[selectionSet] = visited
 
-		selections := make(map[string]*Selection)
+		selections := make(map[string][]scopedSelection)
+		siblings := make(map[sibling]visitState)
+		var children []*SelectionSet
+
+		var visitSibling func(*SelectionSet, string) error
+		visitSibling = func(selectionSet *SelectionSet, on string) error {
+			// A fragment spread more than once only needs to be checked once.
+			if siblings[sibling{selectionSet, on}] == visited {
+				return nil
+			}
+			siblings[sibling{selectionSet, on}] = visited
 
-		var visitSibling func(*SelectionSet) error
-		visitSibling = func(selectionSet *SelectionSet) error {
 			for _, selection := range selectionSet.Selections {
-				if other, found := selections[selection.Alias]; found {
-					if other.Name != selection.Name {
-						return NewClientError("same alias with different name")
+				first := true
+				for _, other := range selections[selection.Alias] {
+					if on != "" && other.on != "" && on != other.on {
+						continue
+					}
+					if other.selection.Name != selection.Name {
+						return newValidationError("same alias with different name")
 					}
-					if !reflect.DeepEqual(other.Args, selection.Args) {
-						return NewClientError("same alias with different args")
+					if !reflect.DeepEqual(other.selection.Args, selection.Args) {
+						return newValidationError("same alias with different args")
 					}
-				} else {
-					selections[selection.Alias] = selection
+					// Later selections in this scope are checked against
+					// other just the same.
+					if other.on == on {
+						first = false
+					}
+				}
+				if first {
+					selections[selection.Alias] = append(selections[selection.Alias], scopedSelection{selection: selection, on: on})
+				}
+				if selection.SelectionSet != nil {
+					children = append(children, selection.SelectionSet)
 				}
 			}
 
 			for _, fragment := range selectionSet.Fragments {
-				if err := visitSibling(fragment.SelectionSet); err != nil {
+				fragmentOn := on
+				if fragment.On != "" {
+					fragmentOn = fragment.On
+				}
+				if err := visitSibling(fragment.SelectionSet, fragmentOn); err != nil {
 					return err
 				}
 			}
@@ -276,10 +425,16 @@ func detectConflicts(selectionSet *SelectionSet) error {
 			return nil
 		}
 
-		if err := visitSibling(selectionSet); err != nil {
+		if err := visitSibling(selectionSet, ""); err != nil {
 			return err
 		}
 
+		for _, child := range children {
+			if err := visitChild(child); err != nil {
+				return err
+			}
+		}
+
 		return nil
 	}
 
@@ -301,7 +456,7 @@ type Query struct {
 func Parse(source string, vars map[string]interface{}) (*Query, error) {
 	document, err := parser.Parse(parser.ParseParams{Source: source})
 	if err != nil {
-		return nil, NewClientError(err.Error())
+		return nil, newValidationError("%s", err)
 	}
 
 	var queryDefinition *ast.OperationDefinition
@@ -312,26 +467,26 @@ func Parse(source string, vars map[string]interface{}) (*Query, error) {
 		case *ast.FragmentDefinition:
 			name := definition.Name.Value
 			if _, found := fragmentDefinitions[name]; found {
-				return nil, NewClientError("duplicate fragment")
+				return nil, newValidationError("duplicate fragment")
 			}
 			fragmentDefinitions[name] = definition
 
 		case *ast.OperationDefinition:
-			if definition.Operation != "query" && definition.Operation != "mutation" {
-				return nil, NewClientError("only support queries or mutations")
+			if definition.Operation != "query" && definition.Operation != "mutation" && definition.Operation != "subscription" {
+				return nil, newValidationError("only support queries, mutations, or subscriptions")
 			}
 			if queryDefinition != nil {
-				return nil, NewClientError("only support a single query")
+				return nil, newValidationError("only support a single query")
 			}
 			queryDefinition = definition
 
 		default:
-			return nil, NewClientError("unsupported definition")
+			return nil, newValidationError("unsupported definition")
 		}
 	}
 
 	if queryDefinition == nil {
-		return nil, NewClientError("must have a single query")
+		return nil, newValidationError("must have a single query")
 	}
 
 	kind := queryDefinition.Operation
@@ -353,7 +508,7 @@ func Parse(source string, vars map[string]interface{}) (*Query, error) {
 
 		if _, ok := variableDefinition.Type.(*ast.NonNull); ok {
 			if variableDefinition.DefaultValue != nil {
-				return rv, NewClientError("required variable cannot provide a default value: $%s", name)
+				return rv, newValidationError("required variable cannot provide a default value: $%s", name)
 			}
 
 			continue
@@ -377,7 +532,7 @@ func Parse(source string, vars map[string]interface{}) (*Query, error) {
 			// See: https://github.com/graphql/graphql-js/blob/17a0bfd5292f39cafe4eec5b3bd0e22514243b68/src/execution/values.js#L84
 			val, err := valueToJson(variableDefinition.DefaultValue, nil)
 			if err != nil {
-				return rv, NewClientError("failed to parse default value: %s", err.Error())
+				return rv, newValidationError("failed to parse default value: %s", err.Error())
 			}
 
 			defaultedVars[name] = val
@@ -395,20 +550,21 @@ func Parse(source string, vars map[string]interface{}) (*Query, error) {
 		}
 	}
 
+	skipped := &SelectionSet{}
 	for name, fragment := range fragmentDefinitions {
-		selectionSet, err := parseSelectionSet(fragment.SelectionSet, globalFragments, vars)
+		selectionSet, err := parseSelectionSet(fragment.SelectionSet, globalFragments, vars, skipped)
 		if err != nil {
 			return rv, err
 		}
 		globalFragments[name].SelectionSet = selectionSet
 	}
 
-	selectionSet, err := parseSelectionSet(queryDefinition.SelectionSet, globalFragments, vars)
+	selectionSet, err := parseSelectionSet(queryDefinition.SelectionSet, globalFragments, vars, skipped)
 	if err != nil {
 		return rv, err
 	}
 
-	if err := detectCyclesAndUnusedFragments(selectionSet, globalFragments); err != nil {
+	if err := detectCyclesAndUnusedFragments(selectionSet, skipped, globalFragments); err != nil {
 		return rv, err
 	}
 
@@ -483,11 +639,16 @@ func Flatten(selectionSet *SelectionSet) []*Selection {
 			merged.Fragments = append(merged.Fragments, selection.SelectionSet.Fragments...)
 		}
 
+		mergedFrom := selections[0]
+		if mergedFrom.mergedFrom != nil {
+			mergedFrom = mergedFrom.mergedFrom
+		}
 		flattened = append(flattened, &Selection{
 			Name:         selections[0].Name,
 			Alias:        selections[0].Alias,
 			Args:         selections[0].Args,
 			SelectionSet: merged,
+			mergedFrom:   mergedFrom,
 		})
 	}
 
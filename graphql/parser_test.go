@@ -218,9 +218,9 @@ func TestParseUnsupported(t *testing.T) {
 
 	_, err = Parse(`
 {
-	a(x: 1, x: 1)
+	a(x: 1, x: 2)
 }`, map[string]interface{}{})
-	if err == nil || err.Error() != "duplicate arg" {
+	if err == nil || err.Error() != `duplicate argument "x"` {
 		t.Error("expected duplicate args to fail", err)
 	}
 
@@ -280,3 +280,67 @@ query Operation($x: int64 = 2) {
 		t.Errorf("expected 2, received %v", val)
 	}
 }
+
+func TestParseIncludeAndSkip(t *testing.T) {
+	query, err := Parse(`
+query Operation($show: Boolean!) {
+	a @include(if: $show)
+	b @skip(if: $show)
+	c @include(if: true) @skip(if: false)
+	... on Foo @skip(if: true) {
+		d
+	}
+	... Bar @include(if: false)
+}
+
+fragment Bar on Foo {
+	e
+}`, map[string]interface{}{
+		"show": false,
+	})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	expected := &SelectionSet{
+		Selections: []*Selection{
+			{Name: "b", Alias: "b", Args: map[string]interface{}{}},
+			{Name: "c", Alias: "c", Args: map[string]interface{}{}},
+		},
+	}
+	if !reflect.DeepEqual(query.SelectionSet, expected) {
+		t.Errorf("expected skipped selections to be left out, got %v", query.SelectionSet)
+	}
+
+	for _, tc := range []struct {
+		query string
+		err   string
+	}{
+		{
+			query: `query Operation($flags: Flags) { a @include(if: $flags) }`,
+			err:   `directive @include expects argument "if" of type Boolean!, but variable $flags is an object`,
+		},
+		{
+			query: `{ a @skip(if: "yes") }`,
+			err:   `directive @skip expects argument "if" of type Boolean!, but received a string`,
+		},
+		{
+			query: `{ a @include }`,
+			err:   `directive @include requires argument "if" of type Boolean!`,
+		},
+		{
+			query: `{ a @include(if: true, unless: false) }`,
+			err:   `unknown argument "unless" for directive @include`,
+		},
+	} {
+		_, err := Parse(tc.query, map[string]interface{}{
+			"flags": map[string]interface{}{"showDetails": true},
+		})
+		if err == nil || err.Error() != tc.err {
+			t.Errorf("expected %s to fail with %q, but received %v", tc.query, tc.err, err)
+		}
+		if _, ok := err.(ClientError); !ok {
+			t.Errorf("expected %s to fail with a ClientError, but received %T", tc.query, err)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package graphql
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+)
+
+// A PersistedQueryCache stores the text of queries by their SHA-256 hash for
+// Automatic Persisted Queries. Implementations must be safe for concurrent
+// use.
+type PersistedQueryCache interface {
+	// Get returns the query whose hash is hash, if it is stored.
+	Get(hash string) (string, bool)
+	// Set stores query under its hash.
+	Set(hash, query string)
+}
+
+// DefaultPersistedQueryCacheSize is the number of queries kept by the cache
+// WithPersistedQueries uses by default.
+const DefaultPersistedQueryCacheSize = 1000
+
+// lruPersistedQueryCache is a PersistedQueryCache that keeps the most
+// recently used queries.
+type lruPersistedQueryCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List // of *lruEntry, most recently used first
+	entries map[string]*list.Element
+}
+
+type lruEntry struct {
+	hash  string
+	query string
+}
+
+// NewLRUPersistedQueryCache returns an in-memory PersistedQueryCache that
+// keeps the size most recently used queries.
+func NewLRUPersistedQueryCache(size int) PersistedQueryCache {
+	return &lruPersistedQueryCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (c *lruPersistedQueryCache) Get(hash string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[hash]
+	if !ok {
+		return "", false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*lruEntry).query, true
+}
+
+func (c *lruPersistedQueryCache) Set(hash, query string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[hash]; ok {
+		element.Value.(*lruEntry).query = query
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[hash] = c.order.PushFront(&lruEntry{hash: hash, query: query})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).hash)
+	}
+}
+
+// errPersistedQueryNotFound tells a client to send the text of the query it
+// sent a hash for. Apollo clients look for its exact message.
+var errPersistedQueryNotFound error = ClientError{message: "PersistedQueryNotFound", code: ErrorCodePersistedQueryNotFound}
+
+// resolvePersistedQuery implements Automatic Persisted Queries: if params
+// carry a persistedQuery extension with only a hash, it fills in the query
+// stored under the hash, and if they carry the query as well, it stores the
+// query under the hash after checking that they match.
+func (h *httpHandler) resolvePersistedQuery(params *httpPostBody) error {
+	extension, ok := params.Extensions["persistedQuery"]
+	if !ok || h.persisted == nil {
+		return nil
+	}
+	persistedQuery, ok := extension.(map[string]interface{})
+	if !ok {
+		return newValidationError("persistedQuery must be an object")
+	}
+	if version, ok := persistedQuery["version"].(float64); !ok || version != 1 {
+		return newValidationError("unsupported persistedQuery version")
+	}
+	hash, ok := persistedQuery["sha256Hash"].(string)
+	if !ok || hash == "" {
+		return newValidationError("persistedQuery must include a sha256Hash")
+	}
+
+	if params.Query == "" {
+		query, ok := h.persisted.Get(hash)
+		if !ok {
+			return errPersistedQueryNotFound
+		}
+		params.Query = query
+		return nil
+	}
+
+	sum := sha256.Sum256([]byte(params.Query))
+	if hex.EncodeToString(sum[:]) != hash {
+		return newValidationError("provided sha256Hash does not match query")
+	}
+	h.persisted.Set(hash, params.Query)
+	return nil
+}
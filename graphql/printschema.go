@@ -0,0 +1,301 @@
+package graphql
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PrintSchema prints s in the GraphQL schema definition language, for
+// documentation and for diffing schemas. Every type reachable from the
+// schema's roots is printed once, sorted by name, with its fields and
+// arguments sorted by name, so the output is deterministic. The built-in
+// scalars Int, Float, String, Boolean, and ID are not declared, and a
+// Mutation root without fields is left out.
+func PrintSchema(s *Schema) string {
+	types := make(map[string]Type)
+	collectSchemaTypes(s.Query, types)
+	if mutation, ok := s.Mutation.(*Object); ok && len(mutation.Fields) > 0 {
+		collectSchemaTypes(mutation, types)
+	}
+	collectSchemaTypes(s.Subscription, types)
+
+	names := make([]string, 0, len(types))
+	for name := range types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buffer bytes.Buffer
+	printSchemaDefinition(&buffer, s)
+	for _, name := range names {
+		if buffer.Len() > 0 {
+			buffer.WriteString("\n")
+		}
+		printTypeDefinition(&buffer, types[name], types)
+	}
+	return buffer.String()
+}
+
+// Hash returns a hash of the schema's type system: the names of its types,
+// their fields, arguments, and types, and their descriptions. Like
+// PrintSchema, which it hashes, the hash is deterministic, so clients can
+// compare it to detect that the schema changed, for example to bust caches.
+func (s *Schema) Hash() string {
+	sum := sha256.Sum256([]byte(PrintSchema(s)))
+	return hex.EncodeToString(sum[:])
+}
+
+// collectSchemaTypes adds typ, and every named type reachable from it, to
+// types by name.
+func collectSchemaTypes(typ Type, types map[string]Type) {
+	switch typ := typ.(type) {
+	case *Object:
+		if _, ok := types[typ.Name]; ok {
+			return
+		}
+		types[typ.Name] = typ
+		for _, field := range typ.Fields {
+			collectSchemaTypes(field.Type, types)
+			for _, arg := range field.Args {
+				collectSchemaTypes(arg, types)
+			}
+		}
+	case *Union:
+		if _, ok := types[typ.Name]; ok {
+			return
+		}
+		types[typ.Name] = typ
+		for _, object := range typ.Types {
+			collectSchemaTypes(object, types)
+		}
+	case *Interface:
+		if _, ok := types[typ.Name]; ok {
+			return
+		}
+		types[typ.Name] = typ
+		for _, field := range typ.Fields {
+			collectSchemaTypes(field.Type, types)
+			for _, arg := range field.Args {
+				collectSchemaTypes(arg, types)
+			}
+		}
+		for _, object := range typ.Types {
+			collectSchemaTypes(object, types)
+		}
+	case *InputObject:
+		if _, ok := types[typ.Name]; ok {
+			return
+		}
+		types[typ.Name] = typ
+		for _, field := range typ.InputFields {
+			collectSchemaTypes(field, types)
+		}
+	case *Scalar:
+		for _, builtin := range sdlScalars {
+			if typ.Type == builtin {
+				return
+			}
+		}
+		types[typ.Type] = typ
+	case *Enum:
+		types[typ.Type] = typ
+	case *List:
+		collectSchemaTypes(typ.Type, types)
+	case *NonNull:
+		collectSchemaTypes(typ.Type, types)
+	}
+}
+
+// printSchemaDefinition prints a schema definition if s's roots aren't named
+// Query, Mutation, and Subscription, as SDL assumes otherwise.
+func printSchemaDefinition(buffer *bytes.Buffer, s *Schema) {
+	var operations []string
+	conventional := true
+	for _, root := range []struct {
+		operation string
+		name      string
+		typ       Type
+	}{
+		{"query", "Query", s.Query},
+		{"mutation", "Mutation", s.Mutation},
+		{"subscription", "Subscription", s.Subscription},
+	} {
+		object, ok := root.typ.(*Object)
+		if !ok || (root.operation == "mutation" && len(object.Fields) == 0) {
+			continue
+		}
+		operations = append(operations, fmt.Sprintf("  %s: %s\n", root.operation, object.Name))
+		if object.Name != root.name {
+			conventional = false
+		}
+	}
+	if conventional {
+		return
+	}
+
+	buffer.WriteString("schema {\n")
+	for _, operation := range operations {
+		buffer.WriteString(operation)
+	}
+	buffer.WriteString("}\n")
+}
+
+// printTypeDefinition prints the definition of a named type. types holds
+// every type in the schema, to find the interfaces an object implements.
+func printTypeDefinition(buffer *bytes.Buffer, typ Type, types map[string]Type) {
+	switch typ := typ.(type) {
+	case *Object:
+		printDescription(buffer, "", typ.Description)
+		fmt.Fprintf(buffer, "type %s", typ.Name)
+		var interfaces []string
+		for name, other := range types {
+			if iface, ok := other.(*Interface); ok {
+				if _, ok := iface.Types[typ.Name]; ok {
+					interfaces = append(interfaces, name)
+				}
+			}
+		}
+		if len(interfaces) > 0 {
+			sort.Strings(interfaces)
+			fmt.Fprintf(buffer, " implements %s", strings.Join(interfaces, " & "))
+		}
+		printFields(buffer, typ.Fields)
+
+	case *Interface:
+		printDescription(buffer, "", typ.Description)
+		fmt.Fprintf(buffer, "interface %s", typ.Name)
+		printFields(buffer, typ.Fields)
+
+	case *Union:
+		printDescription(buffer, "", typ.Description)
+		members := make([]string, 0, len(typ.Types))
+		for name := range typ.Types {
+			members = append(members, name)
+		}
+		sort.Strings(members)
+		fmt.Fprintf(buffer, "union %s = %s\n", typ.Name, strings.Join(members, " | "))
+
+	case *InputObject:
+		fmt.Fprintf(buffer, "input %s {\n", typ.Name)
+		names := make([]string, 0, len(typ.InputFields))
+		for name := range typ.InputFields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(buffer, "  %s\n", printInputValue(name, typ.InputFields[name], typ.DefaultValues, typ.DeprecationReasons))
+		}
+		buffer.WriteString("}\n")
+
+	case *Scalar:
+		printDescription(buffer, "", typ.Description)
+		fmt.Fprintf(buffer, "scalar %s", typ.Type)
+		if typ.SpecifiedByURL != "" {
+			fmt.Fprintf(buffer, " @specifiedBy(url: %s)", strconv.Quote(typ.SpecifiedByURL))
+		}
+		buffer.WriteString("\n")
+
+	case *Enum:
+		printDescription(buffer, "", typ.Description)
+		fmt.Fprintf(buffer, "enum %s {\n", typ.Type)
+		for _, value := range typ.Values {
+			printDescription(buffer, "  ", typ.ValueDescriptions[value])
+			deprecation := ""
+			if reason, ok := typ.DeprecationReasons[value]; ok {
+				deprecation = printDeprecated(reason)
+			}
+			fmt.Fprintf(buffer, "  %s%s\n", value, deprecation)
+		}
+		buffer.WriteString("}\n")
+	}
+}
+
+// printFields prints the block of fields of an object or interface.
+func printFields(buffer *bytes.Buffer, fields map[string]*Field) {
+	if len(fields) == 0 {
+		buffer.WriteString("\n")
+		return
+	}
+	buffer.WriteString(" {\n")
+	for _, name := range sortedFieldNames(fields) {
+		field := fields[name]
+		printDescription(buffer, "  ", field.Description)
+		deprecation := ""
+		if field.Deprecated {
+			var reason string
+			if field.DeprecationReason != nil {
+				reason = *field.DeprecationReason
+			}
+			deprecation = printDeprecated(reason)
+		}
+		fmt.Fprintf(buffer, "  %s%s: %s%s\n", name, printArguments(field), field.Type, deprecation)
+	}
+	buffer.WriteString("}\n")
+}
+
+// printArguments prints the arguments of field, if any, in parentheses.
+func printArguments(field *Field) string {
+	if len(field.Args) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(field.Args))
+	for name := range field.Args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	args := make([]string, 0, len(names))
+	for _, name := range names {
+		args = append(args, printInputValue(name, field.Args[name], field.ArgDefaultValues, field.ArgDeprecationReasons))
+	}
+	return "(" + strings.Join(args, ", ") + ")"
+}
+
+// printInputValue prints an argument or input field with its default value
+// and deprecation, if any.
+func printInputValue(name string, typ Type, defaults map[string]string, deprecationReasons map[string]string) string {
+	printed := fmt.Sprintf("%s: %s", name, typ)
+	if value, ok := defaults[name]; ok {
+		printed += " = " + value
+	}
+	if reason, ok := deprecationReasons[name]; ok {
+		printed += printDeprecated(reason)
+	}
+	return printed
+}
+
+// printDeprecated prints a @deprecated directive with reason, if any.
+func printDeprecated(reason string) string {
+	if reason == "" {
+		return " @deprecated"
+	}
+	return fmt.Sprintf(" @deprecated(reason: %s)", strconv.Quote(reason))
+}
+
+// printDescription prints description as a block string, indented by
+// indent, if it is not empty.
+func printDescription(buffer *bytes.Buffer, indent, description string) {
+	if description == "" {
+		return
+	}
+	fmt.Fprintf(buffer, "%s\"\"\"\n", indent)
+	for _, line := range strings.Split(description, "\n") {
+		fmt.Fprintf(buffer, "%s%s\n", indent, strings.Replace(line, `"""`, `\"""`, -1))
+	}
+	fmt.Fprintf(buffer, "%s\"\"\"\n", indent)
+}
+
+// sortedFieldNames returns the names of fields, sorted.
+func sortedFieldNames(fields map[string]*Field) []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,46 @@
+package graphql
+
+import "sync"
+
+// scalarCodec is how the values of a scalar registered with RegisterScalar
+// are serialized into responses and parsed from arguments.
+type scalarCodec struct {
+	serialize func(interface{}) (interface{}, error)
+	parse     func(interface{}) (interface{}, error)
+}
+
+var (
+	scalarCodecsMu sync.RWMutex
+	scalarCodecs   = make(map[string]scalarCodec)
+)
+
+// RegisterScalar registers how the values of scalars named name are
+// serialized and parsed, so that custom scalars such as DateTime, Decimal,
+// or JSON can be added without an Unwrapper and ParseValue on every Scalar.
+//
+// The executor calls serialize to convert a resolved value into its output
+// value, and the default argument parser and schemabuilder's scalar arguments
+// call parse to convert an argument's JSON value into the value passed to
+// resolvers. Either may be nil. A Scalar's own Unwrapper and ParseValue take
+// precedence over the registered funcs.
+//
+// RegisterScalar is meant to be called from init functions; registering a
+// name again replaces its funcs.
+func RegisterScalar(name string, serialize func(interface{}) (interface{}, error), parse func(interface{}) (interface{}, error)) {
+	scalarCodecsMu.Lock()
+	defer scalarCodecsMu.Unlock()
+	scalarCodecs[name] = scalarCodec{serialize: serialize, parse: parse}
+}
+
+// lookupScalarCodec returns the funcs registered for the scalar name.
+func lookupScalarCodec(name string) scalarCodec {
+	scalarCodecsMu.RLock()
+	defer scalarCodecsMu.RUnlock()
+	return scalarCodecs[name]
+}
+
+// ScalarParser returns the parse func registered with RegisterScalar for the
+// scalar name, or nil if there is none.
+func ScalarParser(name string) func(interface{}) (interface{}, error) {
+	return lookupScalarCodec(name).parse
+}
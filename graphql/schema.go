@@ -0,0 +1,121 @@
+package graphql
+
+import (
+	"fmt"
+	"sort"
+)
+
+// NewSchema returns a Schema with the root types query and mutation, after
+// checking with Validate that they are objects whose fields are wired.
+// mutation may be nil.
+func NewSchema(query, mutation Type) (*Schema, error) {
+	schema := &Schema{Query: query, Mutation: mutation}
+	if err := schema.Validate(); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+// Validate checks that the schema's root types are objects, that every
+// field of the objects reachable from them has a resolver and an output
+// type, and that the Defaults of the input objects of their arguments are
+// values of the right types. The query type is required; the mutation and
+// subscription types are optional.
+func (s *Schema) Validate() error {
+	if s.Query == nil {
+		return fmt.Errorf("query type is required")
+	}
+	for _, root := range []struct {
+		name string
+		typ  Type
+	}{
+		{"query", s.Query},
+		{"mutation", s.Mutation},
+		{"subscription", s.Subscription},
+	} {
+		if root.typ == nil {
+			continue
+		}
+		if _, ok := root.typ.(*Object); !ok {
+			return fmt.Errorf("%s type %v must be an object", root.name, root.typ)
+		}
+	}
+
+	objects := s.objects()
+	inputObjects := make(map[string]*InputObject)
+	names := make([]string, 0, len(objects))
+	for name := range objects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		object := objects[name]
+		fieldNames := make([]string, 0, len(object.Fields))
+		for fieldName := range object.Fields {
+			fieldNames = append(fieldNames, fieldName)
+		}
+		sort.Strings(fieldNames)
+
+		for _, fieldName := range fieldNames {
+			field := object.Fields[fieldName]
+			if field.Resolve == nil {
+				return fmt.Errorf("field %s.%s has no resolver", name, fieldName)
+			}
+			if field.Type == nil {
+				return fmt.Errorf("field %s.%s has no type", name, fieldName)
+			}
+			if !isFieldType(field.Type) {
+				return fmt.Errorf("field %s.%s has type %v, which is not an output type", name, fieldName, field.Type)
+			}
+			for _, argType := range field.Args {
+				collectInputObjects(argType, inputObjects)
+			}
+		}
+	}
+
+	inputNames := make([]string, 0, len(inputObjects))
+	for name := range inputObjects {
+		inputNames = append(inputNames, name)
+	}
+	sort.Strings(inputNames)
+	for _, name := range inputNames {
+		if err := checkDefaults(inputObjects[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// collectInputObjects adds the input objects reachable from typ to
+// inputObjects by name.
+func collectInputObjects(typ Type, inputObjects map[string]*InputObject) {
+	switch typ := typ.(type) {
+	case *InputObject:
+		if _, ok := inputObjects[typ.Name]; ok {
+			return
+		}
+		inputObjects[typ.Name] = typ
+		for _, fieldType := range typ.InputFields {
+			collectInputObjects(fieldType, inputObjects)
+		}
+	case *List:
+		collectInputObjects(typ.Type, inputObjects)
+	case *NonNull:
+		collectInputObjects(typ.Type, inputObjects)
+	}
+}
+
+// isFieldType reports whether typ may be the type of a field.
+func isFieldType(typ Type) bool {
+	switch typ := typ.(type) {
+	case *Scalar, *Enum, *Object, *Union, *Interface:
+		return true
+	case *List:
+		return isFieldType(typ.Type)
+	case *NonNull:
+		return isFieldType(typ.Type)
+	default:
+		return false
+	}
+}
@@ -2,6 +2,7 @@ package schemabuilder
 
 import (
 	"encoding"
+	"encoding/json"
 	"fmt"
 	"reflect"
 	"time"
@@ -25,6 +26,14 @@ type schemaBuilder struct {
 type EnumMapping struct {
 	Map        map[string]interface{}
 	ReverseMap map[interface{}]string
+
+	// CaseInsensitive is set by the CaseInsensitive option.
+	CaseInsensitive bool
+
+	// ValueDescriptions and DeprecationReasons are set by the
+	// EnumValueDescription and DeprecatedEnumValue options.
+	ValueDescriptions  map[string]string
+	DeprecationReasons map[string]string
 }
 
 // cachedType is a container for GraphQL datatype and the list of its fields
@@ -42,15 +51,22 @@ func (sb *schemaBuilder) getType(nodeType reflect.Type) (graphql.Type, error) {
 	// Support scalars and optional scalars. Scalars have precedence over structs
 	// to have eg. time.Time function as a scalar.
 	if typeName, values, ok := sb.getEnum(nodeType); ok {
-		return &graphql.NonNull{Type: &graphql.Enum{Type: typeName, Values: values, ReverseMap: sb.enumMappings[nodeType].ReverseMap}}, nil
+		return &graphql.NonNull{Type: sb.enumMappings[nodeType].enum(typeName, values)}, nil
+	}
+
+	if nodeType.Kind() != reflect.Ptr && reflect.PtrTo(nodeType).Implements(marshalerType) {
+		return &graphql.NonNull{Type: getMarshalerType(nodeType)}, nil
+	}
+	if nodeType.Kind() == reflect.Ptr && nodeType.Implements(marshalerType) {
+		return getMarshalerType(nodeType.Elem()), nil
 	}
 
 	if typeName, ok := getScalar(nodeType); ok {
-		return &graphql.NonNull{Type: &graphql.Scalar{Type: typeName}}, nil
+		return &graphql.NonNull{Type: &graphql.Scalar{Type: typeName, SpecifiedByURL: scalarSpecifiedByURLs[typeName]}}, nil
 	}
 	if nodeType.Kind() == reflect.Ptr {
 		if typeName, ok := getScalar(nodeType.Elem()); ok {
-			return &graphql.Scalar{Type: typeName}, nil // XXX: prefix typ with "*"
+			return &graphql.Scalar{Type: typeName, SpecifiedByURL: scalarSpecifiedByURLs[typeName]}, nil // XXX: prefix typ with "*"
 		}
 	}
 
@@ -58,6 +74,15 @@ func (sb *schemaBuilder) getType(nodeType reflect.Type) (graphql.Type, error) {
 		return sb.getTextMarshalerType(nodeType)
 	}
 
+	if nodeType == jsonType {
+		return jsonScalar, nil
+	}
+
+	// Readers are streamed into responses as strings. A nil reader is null.
+	if nodeType == readerType {
+		return &graphql.Scalar{Type: "string"}, nil
+	}
+
 	// Structs
 	if nodeType.Kind() == reflect.Struct {
 		if err := sb.buildStruct(nodeType); err != nil {
@@ -91,6 +116,46 @@ func (sb *schemaBuilder) getType(nodeType reflect.Type) (graphql.Type, error) {
 	}
 }
 
+// jsonScalar is the type of interface{} values. They are output as the JSON
+// they marshal to.
+var jsonScalar = &graphql.Scalar{
+	Type: "JSON",
+	Unwrapper: func(source interface{}) (interface{}, error) {
+		bytes, err := json.Marshal(source)
+		if err != nil {
+			return nil, err
+		}
+		var value interface{}
+		if err := json.Unmarshal(bytes, &value); err != nil {
+			return nil, err
+		}
+		return value, nil
+	},
+}
+
+// getMarshalerType returns a scalar for a type that implements
+// graphql.Marshaler, either directly or through a pointer.
+func getMarshalerType(typ reflect.Type) *graphql.Scalar {
+	return &graphql.Scalar{
+		Type: typ.Name(),
+		Unwrapper: func(source interface{}) (interface{}, error) {
+			value := reflect.ValueOf(source)
+			if value.Kind() == reflect.Ptr {
+				if value.IsNil() {
+					return nil, nil
+				}
+			} else {
+				// Copy the value so that methods with pointer receivers can be
+				// called.
+				ptr := reflect.New(value.Type())
+				ptr.Elem().Set(value)
+				value = ptr
+			}
+			return value.Interface().(graphql.Marshaler).MarshalGraphQL()
+		},
+	}
+}
+
 // getTextMarshalerType returns a graphQL type that can be used to parse a
 // encoding.TextMarshaler and convert it's value into a string in the graphQL
 // response.
@@ -119,6 +184,18 @@ func (sb *schemaBuilder) getTextMarshalerType(typ reflect.Type) (graphql.Type, e
 	return &graphql.NonNull{Type: scalar}, nil
 }
 
+// enum returns the Enum type named name with values, as mapped by m.
+func (m *EnumMapping) enum(name string, values []string) *graphql.Enum {
+	return &graphql.Enum{
+		Type:               name,
+		Values:             values,
+		ReverseMap:         m.ReverseMap,
+		CaseInsensitive:    m.CaseInsensitive,
+		ValueDescriptions:  m.ValueDescriptions,
+		DeprecationReasons: m.DeprecationReasons,
+	}
+}
+
 // getEnum gets the Enum type information for the passed in reflect.Type by
 // looking it up in our enum mappings.
 func (sb *schemaBuilder) getEnum(typ reflect.Type) (string, []string, bool) {
@@ -161,3 +238,9 @@ var scalars = map[reflect.Type]string{
 	reflect.TypeOf(time.Time{}): "Time",
 	reflect.TypeOf([]byte{}):    "bytes",
 }
+
+// scalarSpecifiedByURLs links the scalars above that follow a published
+// format to its specification.
+var scalarSpecifiedByURLs = map[string]string{
+	"Time": "https://tools.ietf.org/html/rfc3339",
+}
@@ -36,7 +36,7 @@ func (sb *schemaBuilder) buildFunction(typ reflect.Type, m *method) (*graphql.Fi
 
 	// We have succeeded if no arguments remain.
 	if len(in) != 0 {
-		return nil, fmt.Errorf("%s arguments should be [context][, [*]%s][, args][, selectionSet]", funcCtx.funcType, typ)
+		return nil, fmt.Errorf("%s arguments should be [context][, [*]%s][, args][, selectionSet] or [*]%s, context[, args][, selectionSet]", funcCtx.funcType, typ, typ)
 	}
 
 	// Parse return values. The first return value must be the actual value, and
@@ -67,10 +67,12 @@ func (sb *schemaBuilder) buildFunction(typ reflect.Type, m *method) (*graphql.Fi
 			return funcCtx.extractResultAndErr(funcOutputArgs, retType)
 
 		},
-		Args:           args,
-		Type:           retType,
-		ParseArguments: argParser.Parse,
-		Expensive:      funcCtx.hasContext,
+		Args:                  args,
+		ArgDefaultValues:      argDefaultValues(argType),
+		ArgDeprecationReasons: argDeprecationReasons(argType),
+		Type:                  retType,
+		ParseArguments:        argParser.Parse,
+		Expensive:             funcCtx.hasContext,
 	}, nil
 }
 
@@ -83,6 +85,10 @@ type funcContext struct {
 	hasRet          bool
 	hasError        bool
 
+	// contextAfterSource is set for functions that take the source before the
+	// context, such as method expressions like (*User).Friends.
+	contextAfterSource bool
+
 	funcType     reflect.Type
 	isPtrFunc    bool
 	typ          reflect.Type
@@ -115,6 +121,9 @@ func (funcCtx *funcContext) getFuncInputTypes() []reflect.Type {
 // object type that this function is connected to).  If we find either of these
 // fields we will pop that field from the input parameters we return (since we've
 // already "dealt" with those fields).
+//
+// The context may also follow the source, so that methods can be registered
+// as method expressions, whose receiver is their first parameter.
 func (funcCtx *funcContext) consumeContextAndSource(in []reflect.Type) []reflect.Type {
 	ptr := reflect.PtrTo(funcCtx.typ)
 
@@ -127,6 +136,12 @@ func (funcCtx *funcContext) consumeContextAndSource(in []reflect.Type) []reflect
 		funcCtx.hasSource = true
 		funcCtx.isPtrFunc = in[0] == ptr
 		in = in[1:]
+
+		if !funcCtx.hasContext && len(in) > 0 && in[0] == contextType {
+			funcCtx.hasContext = true
+			funcCtx.contextAfterSource = true
+			in = in[1:]
+		}
 	}
 
 	return in
@@ -236,11 +251,29 @@ func (funcCtx *funcContext) argsTypeMap(argType graphql.Type) (map[string]graphq
 	return args, nil
 }
 
+// argDefaultValues returns the default values of the fields of argType, if it
+// is an input object.
+func argDefaultValues(argType graphql.Type) map[string]string {
+	if inputObject, ok := argType.(*graphql.InputObject); ok {
+		return inputObject.DefaultValues
+	}
+	return nil
+}
+
+// argDeprecationReasons returns the deprecation reasons of the fields of
+// argType, if it is an input object.
+func argDeprecationReasons(argType graphql.Type) map[string]string {
+	if inputObject, ok := argType.(*graphql.InputObject); ok {
+		return inputObject.DeprecationReasons
+	}
+	return nil
+}
+
 // prepareResolveArgs converts the provided source, args and context into the
 // required list of reflect.Value types that the function needs to be called.
 func (funcCtx *funcContext) prepareResolveArgs(source interface{}, args interface{}, ctx context.Context) []reflect.Value {
 	in := make([]reflect.Value, 0, funcCtx.funcType.NumIn())
-	if funcCtx.hasContext {
+	if funcCtx.hasContext && !funcCtx.contextAfterSource {
 		in = append(in, reflect.ValueOf(ctx))
 	}
 
@@ -259,6 +292,9 @@ func (funcCtx *funcContext) prepareResolveArgs(source interface{}, args interfac
 			in = append(in, sourceValue)
 		}
 	}
+	if funcCtx.contextAfterSource {
+		in = append(in, reflect.ValueOf(ctx))
+	}
 
 	// Set up other arguments.
 	if funcCtx.hasArgs {
@@ -3,8 +3,10 @@ package schemabuilder
 import (
 	"encoding"
 	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"reflect"
 	"time"
 
@@ -59,6 +61,14 @@ func (sb *schemaBuilder) makeStructParser(typ reflect.Type) (*argParser, graphql
 		return nil, nil, err
 	}
 
+	// Find the ProvidedFields field, if any, to record which fields are given.
+	var providedIndex []int
+	for i := 0; i < typ.NumField(); i++ {
+		if field := typ.Field(i); field.Type == providedFieldsType && field.PkgPath == "" {
+			providedIndex = field.Index
+		}
+	}
+
 	return &argParser{
 		FromJSON: func(value interface{}, dest reflect.Value) error {
 			asMap, ok := value.(map[string]interface{})
@@ -66,19 +76,31 @@ func (sb *schemaBuilder) makeStructParser(typ reflect.Type) (*argParser, graphql
 				return errors.New("not an object")
 			}
 
+			invalid := make(map[string]error)
 			for name, field := range fields {
-				value := asMap[name]
 				fieldDest := dest.FieldByIndex(field.field.Index)
-				if err := field.parser.FromJSON(value, fieldDest); err != nil {
-					return fmt.Errorf("%s: %s", name, err)
+				if err := field.parser.FromJSON(asMap[name], fieldDest); err != nil {
+					invalid[name] = err
 				}
 			}
+			var unknown []string
 			for name := range asMap {
 				if _, ok := fields[name]; !ok {
-					return fmt.Errorf("unknown arg %s", name)
+					unknown = append(unknown, name)
 				}
 			}
 
+			if err := internal.ArgsError(invalid, unknown); err != nil {
+				return err
+			}
+
+			if providedIndex != nil {
+				provided := make(ProvidedFields, len(asMap))
+				for name := range asMap {
+					provided[name] = true
+				}
+				dest.FieldByIndex(providedIndex).Set(reflect.ValueOf(provided))
+			}
 			return nil
 		},
 		Type: typ,
@@ -115,6 +137,9 @@ func (sb *schemaBuilder) getStructObjectFields(typ reflect.Type) (*graphql.Input
 		if field.Anonymous {
 			return nil, nil, fmt.Errorf("bad arg type %s: anonymous fields not supported", typ)
 		}
+		if field.Type == providedFieldsType {
+			continue
+		}
 
 		fieldInfo, err := parseGraphQLFieldInfo(field)
 		if err != nil {
@@ -134,6 +159,23 @@ func (sb *schemaBuilder) getStructObjectFields(typ reflect.Type) (*graphql.Input
 		if fieldInfo.OptionalInputField {
 			parser, fieldArgTyp = wrapWithZeroValue(parser, fieldArgTyp)
 		}
+		if fieldInfo.DefaultValue != nil {
+			var literal string
+			parser, fieldArgTyp, literal, err = wrapWithDefault(parser, fieldArgTyp, *fieldInfo.DefaultValue)
+			if err != nil {
+				return nil, nil, fmt.Errorf("bad arg type %s: field %s: %s", typ, fieldInfo.Name, err)
+			}
+			if argType.DefaultValues == nil {
+				argType.DefaultValues = make(map[string]string)
+			}
+			argType.DefaultValues[fieldInfo.Name] = literal
+		}
+		if fieldInfo.DeprecationReason != nil {
+			if argType.DeprecationReasons == nil {
+				argType.DeprecationReasons = make(map[string]string)
+			}
+			argType.DeprecationReasons[fieldInfo.Name] = *fieldInfo.DeprecationReason
+		}
 
 		fields[fieldInfo.Name] = argField{
 			field:  field,
@@ -148,6 +190,10 @@ func (sb *schemaBuilder) getStructObjectFields(typ reflect.Type) (*graphql.Input
 // makeArgParser reads the information on a passed in variable type and returns
 // an ArgParser that can be used to "fill" that type from a GraphQL JSON input.
 func (sb *schemaBuilder) makeArgParser(typ reflect.Type) (*argParser, graphql.Type, error) {
+	if typ == jsonType {
+		return jsonArgParser, jsonScalar, nil
+	}
+
 	if typ.Kind() == reflect.Ptr {
 		parser, argType, err := sb.makeArgParserInner(typ.Elem())
 		if err != nil {
@@ -171,6 +217,10 @@ func (sb *schemaBuilder) makeArgParserInner(typ reflect.Type) (*argParser, graph
 		return parser, argType, nil
 	}
 
+	if reflect.PtrTo(typ).Implements(unmarshalerType) {
+		return makeUnmarshalerParser(typ), &graphql.Scalar{Type: typ.Name()}, nil
+	}
+
 	if parser, argType, ok := getScalarArgParser(typ); ok {
 		return parser, argType, nil
 	}
@@ -196,6 +246,18 @@ func (sb *schemaBuilder) makeArgParserInner(typ reflect.Type) (*argParser, graph
 	}
 }
 
+// jsonArgParser passes arbitrary JSON through to an interface{} without
+// validating it.
+var jsonArgParser = &argParser{
+	FromJSON: func(value interface{}, dest reflect.Value) error {
+		if value != nil {
+			dest.Set(reflect.ValueOf(value))
+		}
+		return nil
+	},
+	Type: jsonType,
+}
+
 // wrapPtrParser wraps an ArgParser with a helper that will convert the parsed
 // type into a pointer type.
 func wrapPtrParser(inner *argParser) *argParser {
@@ -237,27 +299,77 @@ func wrapWithZeroValue(inner *argParser, fieldArgTyp graphql.Type) (*argParser,
 	}, fieldArgTyp
 }
 
+// wrapWithDefault wraps an ArgParser with a helper that will parse the value in
+// a `graphql:",default=..."` tag for non-provided parameters. The tag's value
+// is read as JSON if possible, and as a string otherwise. wrapWithDefault
+// also returns the default as a GraphQL literal.
+func wrapWithDefault(inner *argParser, fieldArgTyp graphql.Type, tag string) (*argParser, graphql.Type, string, error) {
+	var defaultValue interface{}
+	if err := json.Unmarshal([]byte(tag), &defaultValue); err != nil {
+		defaultValue = tag
+	}
+	// Check the default when building the schema, rather than on every query.
+	if err := inner.FromJSON(defaultValue, reflect.New(inner.Type).Elem()); err != nil {
+		return nil, nil, "", fmt.Errorf("bad default value %s: %s", tag, err)
+	}
+	literal, err := json.Marshal(defaultValue)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	// Make sure the "fieldArgType" we expose in graphQL is a Nullable field.
+	if f, ok := fieldArgTyp.(*graphql.NonNull); ok {
+		fieldArgTyp = f.Type
+	}
+	return &argParser{
+		FromJSON: func(value interface{}, dest reflect.Value) error {
+			if value == nil {
+				value = defaultValue
+			}
+			return inner.FromJSON(value, dest)
+		},
+		Type: inner.Type,
+	}, fieldArgTyp, string(literal), nil
+}
+
 // getEnumArgParser creates an arg parser for an Enum type.
 func (sb *schemaBuilder) getEnumArgParser(typ reflect.Type) (*argParser, graphql.Type) {
+	mapping := sb.enumMappings[typ]
 	var values []string
-	for mapping := range sb.enumMappings[typ].Map {
-		values = append(values, mapping)
+	for value := range mapping.Map {
+		values = append(values, value)
 	}
+	enum := mapping.enum(typ.Name(), values)
+
 	return &argParser{FromJSON: func(value interface{}, dest reflect.Value) error {
 		asString, ok := value.(string)
 		if !ok {
 			return errors.New("not a string")
 		}
-		val, ok := sb.enumMappings[typ].Map[asString]
-		if !ok {
-			return fmt.Errorf("unknown enum value %v", asString)
+		name, err := enum.MatchValue(asString)
+		if err != nil {
+			return err
 		}
-		dest.Set(reflect.ValueOf(val).Convert(dest.Type()))
+		dest.Set(reflect.ValueOf(mapping.Map[name]).Convert(dest.Type()))
 		return nil
-	}, Type: typ}, &graphql.Enum{Type: typ.Name(), Values: values, ReverseMap: sb.enumMappings[typ].ReverseMap}
+	}, Type: typ}, enum
 
 }
 
+// makeUnmarshalerParser returns an argParser that will parse the passed in
+// value with the destination type's graphql.Unmarshaler implementation.
+func makeUnmarshalerParser(typ reflect.Type) *argParser {
+	return &argParser{
+		FromJSON: func(value interface{}, dest reflect.Value) error {
+			if !dest.CanAddr() {
+				return errors.New("destination type cannot be referenced")
+			}
+			return dest.Addr().Interface().(graphql.Unmarshaler).UnmarshalGraphQL(value)
+		},
+		Type: typ,
+	}
+}
+
 // makeTextUnmarshalerParser returns an argParser that will read the passed in
 // value as a string and insert it into the destination type using the
 // encoding.TextUnmarshaler API.
@@ -327,12 +439,59 @@ func getScalarArgParser(typ reflect.Type) (*argParser, graphql.Type, bool) {
 				argParser = &newParser
 			}
 
-			return argParser, &graphql.Scalar{Type: name}, true
+			return registeredScalarParser(name, argParser), &graphql.Scalar{Type: name}, true
 		}
 	}
 	return nil, nil, false
 }
 
+// registeredScalarParser wraps parser so that values of the scalar name are
+// parsed by the func registered with graphql.RegisterScalar, if there is one,
+// instead of by parser.
+func registeredScalarParser(name string, parser *argParser) *argParser {
+	return &argParser{
+		FromJSON: func(value interface{}, dest reflect.Value) error {
+			parse := graphql.ScalarParser(name)
+			if parse == nil {
+				return parser.FromJSON(value, dest)
+			}
+			parsed, err := parse(value)
+			if err != nil {
+				return err
+			}
+			parsedValue := reflect.ValueOf(parsed)
+			if !parsedValue.IsValid() || !parsedValue.Type().ConvertibleTo(dest.Type()) {
+				return fmt.Errorf("registered parser for %s returned %T, not %v", name, parsed, dest.Type())
+			}
+			dest.Set(parsedValue.Convert(dest.Type()))
+			return nil
+		},
+		Type: parser.Type,
+	}
+}
+
+// intFromJSON returns a FromJSON function for an integer type with the given
+// name and size. Numbers outside of the type's range are rejected instead of
+// silently overflowing.
+func intFromJSON(name string, bits uint, signed bool) func(interface{}, reflect.Value) error {
+	min, limit := 0.0, math.Ldexp(1, int(bits))
+	if signed {
+		min, limit = -math.Ldexp(1, int(bits)-1), math.Ldexp(1, int(bits)-1)
+	}
+
+	return func(value interface{}, dest reflect.Value) error {
+		asFloat, ok := value.(float64)
+		if !ok {
+			return errors.New("not a number")
+		}
+		if asFloat < min || asFloat >= limit {
+			return fmt.Errorf("value out of range for %s", name)
+		}
+		dest.Set(reflect.ValueOf(asFloat).Convert(dest.Type()))
+		return nil
+	}
+}
+
 // scalarArgParsers are the static arg parsers that we can use for all scalar &
 // static types.
 var scalarArgParsers = map[reflect.Type]*argParser{
@@ -367,84 +526,28 @@ var scalarArgParsers = map[reflect.Type]*argParser{
 		},
 	},
 	reflect.TypeOf(int64(0)): {
-		FromJSON: func(value interface{}, dest reflect.Value) error {
-			asFloat, ok := value.(float64)
-			if !ok {
-				return errors.New("not a number")
-			}
-			dest.Set(reflect.ValueOf(int64(asFloat)).Convert(dest.Type()))
-			return nil
-		},
+		FromJSON: intFromJSON("int64", 64, true),
 	},
 	reflect.TypeOf(int32(0)): {
-		FromJSON: func(value interface{}, dest reflect.Value) error {
-			asFloat, ok := value.(float64)
-			if !ok {
-				return errors.New("not a number")
-			}
-			dest.Set(reflect.ValueOf(int32(asFloat)).Convert(dest.Type()))
-			return nil
-		},
+		FromJSON: intFromJSON("int32", 32, true),
 	},
 	reflect.TypeOf(int16(0)): {
-		FromJSON: func(value interface{}, dest reflect.Value) error {
-			asFloat, ok := value.(float64)
-			if !ok {
-				return errors.New("not a number")
-			}
-			dest.Set(reflect.ValueOf(int16(asFloat)).Convert(dest.Type()))
-			return nil
-		},
+		FromJSON: intFromJSON("int16", 16, true),
 	},
 	reflect.TypeOf(int8(0)): {
-		FromJSON: func(value interface{}, dest reflect.Value) error {
-			asFloat, ok := value.(float64)
-			if !ok {
-				return errors.New("not a number")
-			}
-			dest.Set(reflect.ValueOf(int8(asFloat)).Convert(dest.Type()))
-			return nil
-		},
+		FromJSON: intFromJSON("int8", 8, true),
 	},
 	reflect.TypeOf(uint64(0)): {
-		FromJSON: func(value interface{}, dest reflect.Value) error {
-			asFloat, ok := value.(float64)
-			if !ok {
-				return errors.New("not a number")
-			}
-			dest.Set(reflect.ValueOf(int64(asFloat)).Convert(dest.Type()))
-			return nil
-		},
+		FromJSON: intFromJSON("uint64", 64, false),
 	},
 	reflect.TypeOf(uint32(0)): {
-		FromJSON: func(value interface{}, dest reflect.Value) error {
-			asFloat, ok := value.(float64)
-			if !ok {
-				return errors.New("not a number")
-			}
-			dest.Set(reflect.ValueOf(uint32(asFloat)).Convert(dest.Type()))
-			return nil
-		},
+		FromJSON: intFromJSON("uint32", 32, false),
 	},
 	reflect.TypeOf(uint16(0)): {
-		FromJSON: func(value interface{}, dest reflect.Value) error {
-			asFloat, ok := value.(float64)
-			if !ok {
-				return errors.New("not a number")
-			}
-			dest.Set(reflect.ValueOf(uint16(asFloat)).Convert(dest.Type()))
-			return nil
-		},
+		FromJSON: intFromJSON("uint16", 16, false),
 	},
 	reflect.TypeOf(uint8(0)): {
-		FromJSON: func(value interface{}, dest reflect.Value) error {
-			asFloat, ok := value.(float64)
-			if !ok {
-				return errors.New("not a number")
-			}
-			dest.Set(reflect.ValueOf(uint8(asFloat)).Convert(dest.Type()))
-			return nil
-		},
+		FromJSON: intFromJSON("uint8", 8, false),
 	},
 	reflect.TypeOf(string("")): {
 		FromJSON: func(value interface{}, dest reflect.Value) error {
@@ -95,6 +95,7 @@ func (sb *schemaBuilder) buildStruct(typ reflect.Type) error {
 			if err != nil {
 				return err
 			}
+			markDeprecated(typedField, method)
 			object.Fields[name] = typedField
 			continue
 		}
@@ -103,6 +104,7 @@ func (sb *schemaBuilder) buildStruct(typ reflect.Type) error {
 		if err != nil {
 			return fmt.Errorf("bad method %s on type %s: %s", name, typ, err)
 		}
+		markDeprecated(built, method)
 		object.Fields[name] = built
 	}
 
@@ -121,6 +123,18 @@ func (sb *schemaBuilder) buildStruct(typ reflect.Type) error {
 	return nil
 }
 
+// markDeprecated marks field as deprecated if its method is.
+func markDeprecated(field *graphql.Field, m *method) {
+	if !m.Deprecated {
+		return
+	}
+	field.Deprecated = true
+	if m.DeprecationReason != "" {
+		reason := m.DeprecationReason
+		field.DeprecationReason = &reason
+	}
+}
+
 // hasUnionMarkerEmbedded determines if a struct has an embedded schemabuilder.Union
 // field embedded on the type.
 func hasUnionMarkerEmbedded(typ reflect.Type) bool {
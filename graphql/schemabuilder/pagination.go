@@ -903,10 +903,12 @@ func (sb *schemaBuilder) buildPaginatedField(typ reflect.Type, m *method) (*grap
 			return c.extractReturnAndErr(ctx, out, args, retType)
 
 		},
-		Args:           args,
-		Type:           retType,
-		ParseArguments: argParser.Parse,
-		Expensive:      c.hasContext,
+		Args:                  args,
+		ArgDefaultValues:      argDefaultValues(argType),
+		ArgDeprecationReasons: argDeprecationReasons(argType),
+		Type:                  retType,
+		ParseArguments:        argParser.Parse,
+		Expensive:             c.hasContext,
 	}
 
 	return ret, nil
@@ -59,3 +59,39 @@ func BenchmarkSimpleExecute(b *testing.B) {
 		<-done
 	}
 }
+
+func BenchmarkParseArguments(b *testing.B) {
+	type Filter struct {
+		Name  string
+		Tags  []string
+		Limit *int64
+	}
+
+	schema := NewSchema()
+	query := schema.Query()
+	query.FieldFunc("search", func(args struct {
+		Filter  Filter
+		Filters []*Filter
+		Page    int64
+	}) int64 {
+		return args.Page
+	})
+	builtSchema := schema.MustBuild()
+
+	// The parser is built with the schema, so parsing only allocates the
+	// parsed values.
+	field := builtSchema.Query.(*graphql.Object).Fields["search"]
+	args := map[string]interface{}{
+		"filter":  map[string]interface{}{"name": "alice", "tags": []interface{}{"a", "b"}, "limit": float64(10)},
+		"filters": []interface{}{map[string]interface{}{"name": "bob", "tags": []interface{}{}}},
+		"page":    float64(2),
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := field.ParseArguments(args); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
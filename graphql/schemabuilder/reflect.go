@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding"
 	"fmt"
+	"io"
 	"reflect"
 	"strings"
 	"unicode"
@@ -53,6 +54,14 @@ type graphQLFieldInfo struct {
 	// OptionalInputField indicates that this field should be treated as an optional
 	// field on graphQL input args.
 	OptionalInputField bool
+
+	// DefaultValue, if non-nil, is the value used for this field on graphQL
+	// input args when it is omitted.
+	DefaultValue *string
+
+	// DeprecationReason, if non-nil, marks this field on graphQL input args as
+	// deprecated, for the given reason.
+	DeprecationReason *string
 }
 
 // parseGraphQLFieldInfo parses a struct field and returns a struct with the
@@ -75,6 +84,8 @@ func parseGraphQLFieldInfo(field reflect.StructField) (*graphQLFieldInfo, error)
 
 	var key bool
 	var optional bool
+	var defaultValue *string
+	var deprecationReason *string
 
 	if len(tags) > 1 {
 		for _, tag := range tags[1:] {
@@ -82,12 +93,18 @@ func parseGraphQLFieldInfo(field reflect.StructField) (*graphQLFieldInfo, error)
 				key = true
 			} else if tag == "optional" && !optional {
 				optional = true
+			} else if strings.HasPrefix(tag, "default=") && defaultValue == nil {
+				value := strings.TrimPrefix(tag, "default=")
+				defaultValue = &value
+			} else if (tag == "deprecated" || strings.HasPrefix(tag, "deprecated=")) && deprecationReason == nil {
+				reason := strings.TrimPrefix(strings.TrimPrefix(tag, "deprecated"), "=")
+				deprecationReason = &reason
 			} else {
 				return nil, fmt.Errorf("field %s has unexpected tag %s", name, tag)
 			}
 		}
 	}
-	return &graphQLFieldInfo{Name: name, KeyField: key, OptionalInputField: optional}, nil
+	return &graphQLFieldInfo{Name: name, KeyField: key, OptionalInputField: optional, DefaultValue: defaultValue, DeprecationReason: deprecationReason}, nil
 }
 
 // Common Types that we will need to perform type assertions against.
@@ -96,3 +113,8 @@ var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
 var selectionSetType = reflect.TypeOf(&graphql.SelectionSet{})
 var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
 var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+var jsonType = reflect.TypeOf((*interface{})(nil)).Elem()
+var readerType = reflect.TypeOf((*io.Reader)(nil)).Elem()
+var marshalerType = reflect.TypeOf((*graphql.Marshaler)(nil)).Elem()
+var unmarshalerType = reflect.TypeOf((*graphql.Unmarshaler)(nil)).Elem()
+var providedFieldsType = reflect.TypeOf(ProvidedFields(nil))
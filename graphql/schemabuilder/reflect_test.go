@@ -3,6 +3,7 @@ package schemabuilder
 import (
 	"context"
 	"errors"
+	"fmt"
 	"reflect"
 	"strings"
 	"testing"
@@ -595,6 +596,480 @@ func TestArgParser(t *testing.T) {
 	}
 }
 
+type intRangeArgs struct {
+	Value  int64
+	Small  int8
+	Unsign uint8
+}
+
+func TestIntArgRange(t *testing.T) {
+	sb := &schemaBuilder{
+		typeCache: make(map[reflect.Type]cachedType, 0),
+	}
+	parser, _, err := sb.makeArgParser(reflect.TypeOf(intRangeArgs{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		input string
+		err   string
+	}{
+		{`{"value": 99999999999999999999, "small": 0, "unsign": 0}`, "value: value out of range for int64"},
+		{`{"value": -99999999999999999999, "small": 0, "unsign": 0}`, "value: value out of range for int64"},
+		{`{"value": 0, "small": 128, "unsign": 0}`, "small: value out of range for int8"},
+		{`{"value": 0, "small": -129, "unsign": 0}`, "small: value out of range for int8"},
+		{`{"value": 0, "small": 0, "unsign": -1}`, "unsign: value out of range for uint8"},
+		{`{"value": 0, "small": 0, "unsign": 256}`, "unsign: value out of range for uint8"},
+	} {
+		if _, err := parser.Parse(internal.ParseJSON(c.input)); err == nil || err.Error() != c.err {
+			t.Errorf("p(%s) = %v, expected %q", c.input, err, c.err)
+		}
+	}
+
+	if _, err := parser.Parse(internal.ParseJSON(`{"value": -9223372036854775808, "small": -128, "unsign": 255}`)); err != nil {
+		t.Errorf("expected boundary values to parse, got %v", err)
+	}
+}
+
+func TestJSONScalar(t *testing.T) {
+	schema := NewSchema()
+	query := schema.Query()
+	query.FieldFunc("echo", func(args struct{ Metadata interface{} }) interface{} {
+		return args.Metadata
+	})
+	query.FieldFunc("user", func() interface{} {
+		return User{Name: "Alice", Age: 30}
+	})
+
+	builtSchema := schema.MustBuild()
+
+	q := graphql.MustParse(`
+		query Test($metadata: JSON) {
+			echo(metadata: $metadata)
+			missing: echo
+			user
+		}
+	`, map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"tags":   []interface{}{"a", float64(1), true},
+			"nested": map[string]interface{}{"x": nil},
+		},
+	})
+
+	if err := graphql.PrepareQuery(builtSchema.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+
+	e := graphql.Executor{}
+	result, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, map[string]interface{}{
+		"echo": map[string]interface{}{
+			"tags":   []interface{}{"a", float64(1), true},
+			"nested": map[string]interface{}{"x": nil},
+		},
+		"missing": nil,
+		"user":    map[string]interface{}{"Name": "Alice", "Age": float64(30)},
+	}, result)
+}
+
+func TestArgDefaults(t *testing.T) {
+	schema := NewSchema()
+	query := schema.Query()
+	query.FieldFunc("users", func(args struct {
+		Limit int64  `graphql:",default=10"`
+		Name  string `graphql:",default=bob"`
+	}) string {
+		return fmt.Sprintf("%s:%d", args.Name, args.Limit)
+	})
+
+	builtSchema := schema.MustBuild()
+
+	field := builtSchema.Query.(*graphql.Object).Fields["users"]
+	assert.Equal(t, map[string]string{"limit": "10", "name": `"bob"`}, field.ArgDefaultValues)
+	if _, ok := field.Args["limit"].(*graphql.NonNull); ok {
+		t.Error("expected arg with a default to be nullable")
+	}
+
+	for _, c := range []struct {
+		query     string
+		variables map[string]interface{}
+		expected  string
+	}{
+		{`{ users }`, nil, "bob:10"},
+		{`{ users(limit: 5, name: "alice") }`, nil, "alice:5"},
+		{`query Q($limit: int64) { users(limit: $limit) }`, nil, "bob:10"},
+		{`query Q($limit: int64 = 3) { users(limit: $limit) }`, nil, "bob:3"},
+		{`query Q($limit: int64 = 3) { users(limit: $limit) }`, map[string]interface{}{"limit": float64(7)}, "bob:7"},
+	} {
+		q := graphql.MustParse(c.query, c.variables)
+		if err := graphql.PrepareQuery(builtSchema.Query, q.SelectionSet); err != nil {
+			t.Fatal(err)
+		}
+
+		e := graphql.Executor{}
+		result, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, map[string]interface{}{"users": c.expected}, result, c.query)
+	}
+
+	badSchema := NewSchema()
+	badSchema.Query().FieldFunc("bad", func(args struct {
+		Limit int64 `graphql:",default=ten"`
+	}) int64 {
+		return args.Limit
+	})
+	if _, err := badSchema.Build(); err == nil {
+		t.Error("expected bad default value to fail")
+	}
+}
+
+// Cursor is a scalar that implements graphql.Marshaler and
+// graphql.Unmarshaler.
+type Cursor struct {
+	Offset int64
+}
+
+func (c *Cursor) MarshalGraphQL() (interface{}, error) {
+	return fmt.Sprintf("offset:%d", c.Offset), nil
+}
+
+func (c *Cursor) UnmarshalGraphQL(value interface{}) error {
+	asString, ok := value.(string)
+	if !ok {
+		return errors.New("not a string")
+	}
+	_, err := fmt.Sscanf(asString, "offset:%d", &c.Offset)
+	return err
+}
+
+func TestMarshalerScalar(t *testing.T) {
+	schema := NewSchema()
+	query := schema.Query()
+	query.FieldFunc("next", func(args struct {
+		After  Cursor
+		Before *Cursor
+	}) Cursor {
+		if args.Before != nil {
+			return Cursor{Offset: args.Before.Offset - 1}
+		}
+		return Cursor{Offset: args.After.Offset + 1}
+	})
+	query.FieldFunc("none", func() *Cursor {
+		return nil
+	})
+
+	builtSchema := schema.MustBuild()
+
+	q := graphql.MustParse(`{
+		after: next(after: "offset:10")
+		before: next(after: "offset:0", before: "offset:5")
+		none
+	}`, nil)
+	if err := graphql.PrepareQuery(builtSchema.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+
+	e := graphql.Executor{}
+	result, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, map[string]interface{}{
+		"after":  "offset:11",
+		"before": "offset:4",
+		"none":   nil,
+	}, result)
+
+	q = graphql.MustParse(`{ next(after: 10) }`, nil)
+	if err := graphql.PrepareQuery(builtSchema.Query, q.SelectionSet); err == nil || !strings.Contains(err.Error(), "not a string") {
+		t.Errorf("expected bad cursor to fail, got %v", err)
+	}
+}
+
+type methodUser struct {
+	Name string
+}
+
+func (u methodUser) Plain() string {
+	return u.Name
+}
+
+func (u *methodUser) WithContext(ctx context.Context) (string, error) {
+	return "hi " + u.Name, nil
+}
+
+func (u *methodUser) WithArgs(ctx context.Context, args struct{ Greeting string }) (string, error) {
+	return args.Greeting + " " + u.Name, nil
+}
+
+func (u *methodUser) WithSelectionSet(ctx context.Context, args struct{ Greeting string }, selectionSet *graphql.SelectionSet) (string, error) {
+	if selectionSet != nil {
+		return "", errors.New("unexpected selection set")
+	}
+	return args.Greeting + ", " + u.Name, nil
+}
+
+func TestMethodResolvers(t *testing.T) {
+	schema := NewSchema()
+	query := schema.Query()
+	query.FieldFunc("user", func() *methodUser {
+		return &methodUser{Name: "bob"}
+	})
+
+	user := schema.Object("User", methodUser{})
+	user.FieldFunc("plain", methodUser.Plain)
+	user.FieldFunc("withContext", (*methodUser).WithContext)
+	user.FieldFunc("withArgs", (*methodUser).WithArgs)
+	user.FieldFunc("withSelectionSet", (*methodUser).WithSelectionSet)
+
+	builtSchema := schema.MustBuild()
+
+	q := graphql.MustParse(`{
+		user {
+			plain
+			withContext
+			withArgs(greeting: "hello")
+			withSelectionSet(greeting: "hey")
+		}
+	}`, nil)
+	if err := graphql.PrepareQuery(builtSchema.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+
+	e := graphql.Executor{}
+	result, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, map[string]interface{}{
+		"user": map[string]interface{}{
+			"plain":            "bob",
+			"withContext":      "hi bob",
+			"withArgs":         "hello bob",
+			"withSelectionSet": "hey, bob",
+		},
+	}, result)
+}
+
+type caseStatus int
+
+type strictStatus int
+
+func TestCaseInsensitiveEnum(t *testing.T) {
+	schema := NewSchema()
+	schema.Enum(caseStatus(0), map[string]caseStatus{
+		"ACTIVE":  1,
+		"PENDING": 2,
+		"Pending": 3,
+	}, CaseInsensitive)
+	schema.Enum(strictStatus(0), map[string]strictStatus{
+		"ACTIVE": 1,
+	})
+
+	query := schema.Query()
+	query.FieldFunc("status", func(args struct{ Status caseStatus }) int64 {
+		return int64(args.Status)
+	})
+	query.FieldFunc("strict", func(args struct{ Status strictStatus }) int64 {
+		return int64(args.Status)
+	})
+	builtSchema := schema.MustBuild()
+
+	for _, c := range []struct {
+		query  string
+		result int64
+		err    string
+	}{
+		{query: `{ v: status(status: ACTIVE) }`, result: 1},
+		{query: `{ v: status(status: active) }`, result: 1},
+		{query: `{ v: status(status: Pending) }`, result: 3},
+		{query: `{ v: status(status: pending) }`, err: "ambiguous enum value pending"},
+		{query: `{ v: status(status: unknown) }`, err: "unknown enum value unknown"},
+		{query: `{ v: strict(status: ACTIVE) }`, result: 1},
+		{query: `{ v: strict(status: active) }`, err: "unknown enum value active"},
+	} {
+		q := graphql.MustParse(c.query, nil)
+		err := graphql.PrepareQuery(builtSchema.Query, q.SelectionSet)
+		if c.err != "" {
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("%s: expected error %q, got %v", c.query, c.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", c.query, err)
+		}
+
+		e := graphql.Executor{}
+		result, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, map[string]interface{}{"v": c.result}, result, c.query)
+	}
+}
+
+type dropdownStatus int64
+
+func TestEnumValuesField(t *testing.T) {
+	schema := NewSchema()
+	schema.Enum(dropdownStatus(0), map[string]dropdownStatus{
+		"active":   1,
+		"inactive": 2,
+		"disabled": 3,
+	},
+		EnumValueDescription("active", "In use."),
+		DeprecatedEnumValue("inactive", "use disabled"),
+	)
+	query := schema.Query()
+	query.FieldFunc("status", func() dropdownStatus { return 1 })
+	schema.EnumValuesField(query, "enumValues")
+	builtSchema := schema.MustBuild()
+
+	q := graphql.MustParse(`{ enumValues(name: "dropdownStatus") { name description isDeprecated deprecationReason } }`, nil)
+	if err := graphql.PrepareQuery(builtSchema.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+	e := graphql.Executor{}
+	result, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, internal.ParseJSON(`{"enumValues": [
+		{"name": "active", "description": "In use.", "isDeprecated": false, "deprecationReason": null},
+		{"name": "disabled", "description": "", "isDeprecated": false, "deprecationReason": null},
+		{"name": "inactive", "description": "", "isDeprecated": true, "deprecationReason": "use disabled"}
+	]}`), internal.AsJSON(result))
+
+	q = graphql.MustParse(`{ enumValues(name: "missing") { name } }`, nil)
+	if _, err := e.Execute(context.Background(), builtSchema.Query, nil, q); err == nil || err.Error() != "enumValues: unknown enum missing" {
+		t.Errorf("expected unknown enum to fail, got %v", err)
+	}
+}
+
+func TestProvidedFields(t *testing.T) {
+	type UserPatch struct {
+		Name     *string
+		Email    *string
+		Provided ProvidedFields
+	}
+
+	var patches []UserPatch
+	schema := NewSchema()
+	_ = schema.Query()
+	mutation := schema.Mutation()
+	mutation.FieldFunc("updateUser", func(args struct{ Patch UserPatch }) bool {
+		patches = append(patches, args.Patch)
+		return true
+	})
+	builtSchema := schema.MustBuild()
+
+	assert.Equal(t, map[string]graphql.Type{
+		"name":  &graphql.Scalar{Type: "string"},
+		"email": &graphql.Scalar{Type: "string"},
+	}, builtSchema.Mutation.(*graphql.Object).Fields["updateUser"].Args["patch"].(*graphql.NonNull).Type.(*graphql.InputObject).InputFields)
+
+	for _, vars := range []string{
+		`{"patch": {"name": null}}`,
+		`{"patch": {}}`,
+		`{"patch": {"name": "Bob", "email": null}}`,
+		`{"patch": {"email": "bob@example.com"}}`,
+	} {
+		q := graphql.MustParse(`mutation ($patch: UserPatch_InputObject!) {
+			a: updateUser(patch: $patch)
+		}`, internal.ParseJSON(vars).(map[string]interface{}))
+		if err := graphql.PrepareQuery(builtSchema.Mutation, q.SelectionSet); err != nil {
+			t.Fatal(err)
+		}
+		e := graphql.Executor{}
+		if _, err := e.Execute(context.Background(), builtSchema.Mutation, nil, q); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	name, email := "Bob", "bob@example.com"
+	assert.Equal(t, []UserPatch{
+		{Provided: ProvidedFields{"name": true}},
+		{Provided: ProvidedFields{}},
+		{Name: &name, Provided: ProvidedFields{"name": true, "email": true}},
+		{Email: &email, Provided: ProvidedFields{"email": true}},
+	}, patches)
+
+	// An input field set to a variable that is not given is omitted.
+	patches = nil
+	for _, vars := range []string{`{"name": null}`, `{}`} {
+		q := graphql.MustParse(`mutation ($name: string) {
+			a: updateUser(patch: {name: $name})
+		}`, internal.ParseJSON(vars).(map[string]interface{}))
+		if err := graphql.PrepareQuery(builtSchema.Mutation, q.SelectionSet); err != nil {
+			t.Fatal(err)
+		}
+		e := graphql.Executor{}
+		if _, err := e.Execute(context.Background(), builtSchema.Mutation, nil, q); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assert.Equal(t, []UserPatch{
+		{Provided: ProvidedFields{"name": true}},
+		{Provided: ProvidedFields{}},
+	}, patches)
+}
+
+func TestBindVariables(t *testing.T) {
+	type filter struct {
+		Name string
+		Tags []string
+	}
+	type variables struct {
+		Id     int64
+		Limit  int64 `graphql:",default=10"`
+		Cursor *string
+		Filter filter
+	}
+
+	var vars variables
+	err := BindVariables(map[string]interface{}{
+		"id":     float64(4),
+		"filter": map[string]interface{}{"name": "bob", "tags": []interface{}{"a", "b"}},
+	}, &vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, variables{
+		Id:     4,
+		Limit:  10,
+		Filter: filter{Name: "bob", Tags: []string{"a", "b"}},
+	}, vars)
+
+	for _, c := range []struct {
+		vars map[string]interface{}
+		err  string
+	}{
+		{map[string]interface{}{"id": "4", "filter": map[string]interface{}{"name": "", "tags": []interface{}{}}}, "invalid variables: id: not a number"},
+		{map[string]interface{}{"id": float64(4)}, "invalid variables: filter: not an object"},
+		{map[string]interface{}{"id": float64(4), "filter": map[string]interface{}{"name": "", "tags": []interface{}{}}, "extra": 1}, "invalid variables: unknown arg extra"},
+	} {
+		err := BindVariables(c.vars, &variables{})
+		if err == nil || err.Error() != c.err {
+			t.Errorf("expected %q, got %v", c.err, err)
+		}
+		if _, ok := err.(graphql.ClientError); !ok {
+			t.Errorf("expected a ClientError, got %T", err)
+		}
+	}
+
+	if err := BindVariables(nil, variables{}); err == nil {
+		t.Error("expected non-pointer dest to fail")
+	}
+}
+
 func TestBadArguments(t *testing.T) {
 	schema := NewSchema()
 	query := schema.Query()
@@ -677,3 +1152,43 @@ func TestObjectKeyMustBeScalar(t *testing.T) {
 	})
 
 }
+
+func TestRegisteredScalar(t *testing.T) {
+	const date = "2006-01-02"
+	graphql.RegisterScalar("Time",
+		func(value interface{}) (interface{}, error) {
+			return value.(time.Time).Format(date), nil
+		},
+		func(value interface{}) (interface{}, error) {
+			s, ok := value.(string)
+			if !ok {
+				return nil, errors.New("not a string")
+			}
+			return time.Parse(date, s)
+		},
+	)
+	defer graphql.RegisterScalar("Time", nil, nil)
+
+	schema := NewSchema()
+	query := schema.Query()
+	query.FieldFunc("nextDay", func(args struct{ After time.Time }) time.Time {
+		return args.After.Add(24 * time.Hour)
+	})
+	builtSchema := schema.MustBuild()
+
+	q := graphql.MustParse(`{ nextDay(after: "2020-02-28") }`, nil)
+	if err := graphql.PrepareQuery(builtSchema.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+	e := graphql.Executor{}
+	result, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, map[string]interface{}{"nextDay": "2020-02-29"}, result)
+
+	q = graphql.MustParse(`{ nextDay(after: "2020-02-28T12:00:00Z") }`, nil)
+	if err := graphql.PrepareQuery(builtSchema.Query, q.SelectionSet); err == nil {
+		t.Error("expected a time that does not match the registered parser to fail")
+	}
+}
@@ -3,6 +3,7 @@ package schemabuilder
 import (
 	"fmt"
 	"reflect"
+	"sort"
 
 	"github.com/samsarahq/thunder/graphql"
 )
@@ -32,7 +33,8 @@ func NewSchema() *Schema {
 
 // Enum registers an enumType in the schema. The val should be any arbitrary value
 // of the enumType to be used for reflection, and the enumMap should be
-// the corresponding map of the enums.
+// the corresponding map of the enums. Options such as CaseInsensitive
+// configure how the enum is parsed.
 //
 // For example a enum could be declared as follows:
 //   type enumType int32
@@ -48,14 +50,66 @@ func NewSchema() *Schema {
 //     "two":   enumType(2),
 //     "three": enumType(3),
 //   })
-func (s *Schema) Enum(val interface{}, enumMap interface{}) {
+func (s *Schema) Enum(val interface{}, enumMap interface{}, options ...EnumOption) {
 	typ := reflect.TypeOf(val)
 	if s.enumTypes == nil {
 		s.enumTypes = make(map[reflect.Type]*EnumMapping)
 	}
 
 	eMap, rMap := getEnumMap(enumMap, typ)
-	s.enumTypes[typ] = &EnumMapping{Map: eMap, ReverseMap: rMap}
+	mapping := &EnumMapping{Map: eMap, ReverseMap: rMap}
+	for _, option := range options {
+		option.apply(mapping)
+	}
+	s.enumTypes[typ] = mapping
+}
+
+// EnumValue describes a value of an enum registered with Enum, as returned
+// by the field added by EnumValuesField.
+type EnumValue struct {
+	Name              string
+	Description       string
+	IsDeprecated      bool
+	DeprecationReason *string
+}
+
+// EnumValuesField adds a field named name to object that returns the values
+// of the enum registered with Enum whose name is passed as the field's name
+// argument, with their descriptions and deprecation, sorted by name. Clients
+// can use it to fetch the options of a single enum, for example for a
+// dropdown, without introspecting the whole schema.
+//
+// For example, after
+//   s.EnumValuesField(s.Query(), "enumValues")
+// the query
+//   { enumValues(name: "Status") { name description isDeprecated } }
+// returns the values of the enum Status.
+func (s *Schema) EnumValuesField(object *Object, name string) {
+	s.Object("EnumValue", EnumValue{})
+	object.FieldFunc(name, func(args struct{ Name string }) ([]EnumValue, error) {
+		for typ, mapping := range s.enumTypes {
+			if typ.Name() != args.Name {
+				continue
+			}
+
+			values := make([]EnumValue, 0, len(mapping.Map))
+			for value := range mapping.Map {
+				reason, deprecated := mapping.DeprecationReasons[value]
+				enumValue := EnumValue{
+					Name:         value,
+					Description:  mapping.ValueDescriptions[value],
+					IsDeprecated: deprecated,
+				}
+				if deprecated {
+					enumValue.DeprecationReason = &reason
+				}
+				values = append(values, enumValue)
+			}
+			sort.Slice(values, func(i, j int) bool { return values[i].Name < values[j].Name })
+			return values, nil
+		}
+		return nil, graphql.NewClientError("unknown enum %s", args.Name)
+	})
 }
 
 func getEnumMap(enumMap interface{}, typ reflect.Type) (map[string]interface{}, map[interface{}]string) {
@@ -123,6 +177,15 @@ func (s *Schema) Mutation() *Object {
 	return s.Object("Mutation", mutation{})
 }
 
+type subscription struct{}
+
+// Subscription returns an Object struct that we can use to register all the
+// top level graphql subscription functions we'd like to expose. A schema only
+// supports subscriptions if Subscription is called.
+func (s *Schema) Subscription() *Object {
+	return s.Object("Subscription", subscription{})
+}
+
 // Build takes the schema we have built on our Query and Mutation starting
 // points and builds a full graphql.Schema we can use to execute and run
 // queries.  Essentially we read through all the methods we've attached to our
@@ -157,9 +220,16 @@ func (s *Schema) Build() (*graphql.Schema, error) {
 	if err != nil {
 		return nil, err
 	}
+	var subscriptionTyp graphql.Type
+	if _, ok := s.objects["Subscription"]; ok {
+		if subscriptionTyp, err = sb.getType(reflect.TypeOf(&subscription{})); err != nil {
+			return nil, err
+		}
+	}
 	return &graphql.Schema{
-		Query:    queryTyp,
-		Mutation: mutationTyp,
+		Query:        queryTyp,
+		Mutation:     mutationTyp,
+		Subscription: subscriptionTyp,
 	}, nil
 }
 
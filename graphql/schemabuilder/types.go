@@ -41,6 +41,66 @@ var Paginated fieldFuncOptionFunc = func(m *method) {
 	m.Paginated = true
 }
 
+// Deprecated is an option that can be passed to a FieldFunc to mark the field
+// as deprecated for reason in introspection, such as "use fullName". The field
+// can still be queried.
+func Deprecated(reason string) FieldFuncOption {
+	return fieldFuncOptionFunc(func(m *method) {
+		m.Deprecated = true
+		m.DeprecationReason = reason
+	})
+}
+
+// ProvidedFields, as the type of a field of an input struct, records which of
+// the input object's fields the client gave, by their GraphQL names. Unlike
+// the struct's other fields, it tells a field given as null apart from one
+// that was omitted, so that, for example, a mutation can clear a name given
+// as null and leave an omitted name unchanged. A ProvidedFields field is not
+// exposed in the schema.
+type ProvidedFields map[string]bool
+
+// EnumOption is an interface for the variadic options that can be passed to
+// Enum for configuring options on that enum.
+type EnumOption interface {
+	apply(*EnumMapping)
+}
+
+// enumOptionFunc is a helper to define EnumOptions from a func.
+type enumOptionFunc func(*EnumMapping)
+
+func (f enumOptionFunc) apply(m *EnumMapping) { f(m) }
+
+// CaseInsensitive is an option that can be passed to Enum to accept argument
+// values that match one of the enum's values ignoring case, such as "active"
+// for "ACTIVE". A value that matches several values ignoring case is
+// rejected as ambiguous.
+var CaseInsensitive enumOptionFunc = func(m *EnumMapping) {
+	m.CaseInsensitive = true
+}
+
+// EnumValueDescription is an option that can be passed to Enum to document
+// the enum's value in introspection.
+func EnumValueDescription(value, description string) EnumOption {
+	return enumOptionFunc(func(m *EnumMapping) {
+		if m.ValueDescriptions == nil {
+			m.ValueDescriptions = make(map[string]string)
+		}
+		m.ValueDescriptions[value] = description
+	})
+}
+
+// DeprecatedEnumValue is an option that can be passed to Enum to mark the
+// enum's value as deprecated for reason in introspection. The value can
+// still be used.
+func DeprecatedEnumValue(value, reason string) EnumOption {
+	return enumOptionFunc(func(m *EnumMapping) {
+		if m.DeprecationReasons == nil {
+			m.DeprecationReasons = make(map[string]string)
+		}
+		m.DeprecationReasons[value] = reason
+	})
+}
+
 type TextFilterFields map[string]interface{}
 
 func (s TextFilterFields) apply(m *method) {
@@ -109,6 +169,10 @@ type method struct {
 	TextFilterFuncs map[string]interface{}
 	// Sort methods
 	SortFuncs map[string]interface{}
+
+	// Deprecated and DeprecationReason are set by the Deprecated option.
+	Deprecated        bool
+	DeprecationReason string
 }
 
 // A Methods map represents the set of methods exposed on a Object.
@@ -0,0 +1,43 @@
+package schemabuilder
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/samsarahq/thunder/graphql"
+)
+
+// BindVariables decodes the variables of a query into dest, which must be a
+// pointer to a struct. The struct's fields are parsed like the fields of an
+// args struct: a field named Count is bound from the variable "count", or
+// from the name in its `graphql` tag, fields that are not pointers or marked
+// optional are required, and default values are honored. Unknown variables
+// are rejected.
+//
+// Enums are not known to BindVariables, as they are registered with a Schema;
+// bind enum variables as strings instead.
+//
+// Variables that don't match dest's shape are reported with a ClientError,
+// so the error can be returned to the client as is.
+func BindVariables(vars map[string]interface{}, dest interface{}) error {
+	value := reflect.ValueOf(dest)
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("dest must be a non-nil pointer to a struct, not %T", dest)
+	}
+
+	sb := &schemaBuilder{
+		typeCache: make(map[reflect.Type]cachedType),
+	}
+	parser, _, err := sb.makeStructParser(value.Elem().Type())
+	if err != nil {
+		return err
+	}
+
+	if vars == nil {
+		vars = map[string]interface{}{}
+	}
+	if err := parser.FromJSON(vars, value.Elem()); err != nil {
+		return graphql.NewClientError("invalid variables: %s", err)
+	}
+	return nil
+}
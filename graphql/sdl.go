@@ -0,0 +1,506 @@
+package graphql
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/printer"
+)
+
+// sdlScalars are the scalars built into the schema definition language.
+var sdlScalars = []string{"Int", "Float", "String", "Boolean", "ID"}
+
+// ParseSDL builds a Schema from type definitions in the GraphQL schema
+// definition language. Objects, interfaces, scalars, enums, unions, and input
+// objects are supported; directive definitions are not. The query and
+// mutation types are named by a schema definition, or are otherwise the
+// objects named Query and Mutation.
+//
+// Fields are resolved by looking up their name in a map[string]interface{}
+// source until a resolver is attached with BindResolver. Enum values are
+// resolved from strings, and union fields from a pointer to a struct with a
+// field named after each member type, one of which is set, unless the
+// union's ResolveType is set. Interface fields are resolved from a
+// map[string]interface{} whose "__typename" entry names the implementing
+// object, unless the interface's ResolveType is set.
+func ParseSDL(sdl string) (*Schema, error) {
+	document, err := parser.Parse(parser.ParseParams{Source: sdl})
+	if err != nil {
+		return nil, err
+	}
+
+	b := &sdlBuilder{
+		types:       make(map[string]Type),
+		definitions: make(map[string]ast.Node),
+	}
+	for _, name := range sdlScalars {
+		b.types[name] = &Scalar{Type: name}
+	}
+
+	// Create every named type before filling in fields, so that types can
+	// reference each other in any order.
+	operations := map[string]string{"query": "Query", "mutation": "Mutation"}
+	for _, definition := range document.Definitions {
+		switch definition := definition.(type) {
+		case *ast.SchemaDefinition:
+			for _, operation := range definition.OperationTypes {
+				operations[operation.Operation] = operation.Type.Name.Value
+			}
+		case *ast.ObjectDefinition:
+			err = b.declare(definition.Name.Value, definition, &Object{
+				Name:        definition.Name.Value,
+				Description: sdlDescription(definition.Description),
+				Fields:      make(map[string]*Field),
+			})
+		case *ast.InterfaceDefinition:
+			iface := &Interface{
+				Name:        definition.Name.Value,
+				Description: sdlDescription(definition.Description),
+				Fields:      make(map[string]*Field),
+				Types:       make(map[string]*Object),
+			}
+			iface.ResolveType = sdlResolveInterface(iface)
+			err = b.declare(definition.Name.Value, definition, iface)
+		case *ast.ScalarDefinition:
+			err = b.declare(definition.Name.Value, definition, &Scalar{
+				Type:           definition.Name.Value,
+				Description:    sdlDescription(definition.Description),
+				SpecifiedByURL: sdlSpecifiedByURL(definition.Directives),
+			})
+		case *ast.EnumDefinition:
+			enum := &Enum{
+				Type:        definition.Name.Value,
+				Description: sdlDescription(definition.Description),
+				ReverseMap:  make(map[interface{}]string),
+			}
+			for _, value := range definition.Values {
+				name := value.Name.Value
+				enum.Values = append(enum.Values, name)
+				enum.ReverseMap[name] = name
+				if description := sdlDescription(value.Description); description != "" {
+					if enum.ValueDescriptions == nil {
+						enum.ValueDescriptions = make(map[string]string)
+					}
+					enum.ValueDescriptions[name] = description
+				}
+				if reason, ok := sdlDeprecationReason(value.Directives); ok {
+					if enum.DeprecationReasons == nil {
+						enum.DeprecationReasons = make(map[string]string)
+					}
+					enum.DeprecationReasons[name] = reason
+				}
+			}
+			err = b.declare(definition.Name.Value, definition, enum)
+		case *ast.UnionDefinition:
+			err = b.declare(definition.Name.Value, definition, &Union{
+				Name:        definition.Name.Value,
+				Description: sdlDescription(definition.Description),
+				Types:       make(map[string]*Object),
+			})
+		case *ast.InputObjectDefinition:
+			err = b.declare(definition.Name.Value, definition, &InputObject{
+				Name:        definition.Name.Value,
+				InputFields: make(map[string]Type),
+			})
+		default:
+			err = fmt.Errorf("unsupported definition %s", definition.GetKind())
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, name := range b.names {
+		switch definition := b.definitions[name].(type) {
+		case *ast.ObjectDefinition:
+			err = b.buildObject(b.types[name].(*Object), definition)
+		case *ast.InterfaceDefinition:
+			iface := b.types[name].(*Interface)
+			err = b.buildFields(iface.Name, iface.Fields, definition.Fields)
+		case *ast.UnionDefinition:
+			err = b.buildUnion(b.types[name].(*Union), definition)
+		case *ast.InputObjectDefinition:
+			err = b.buildInputObject(b.types[name].(*InputObject), definition)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Objects may implement interfaces defined after them, so they are
+	// checked once every type is built.
+	for _, name := range b.names {
+		if iface, ok := b.types[name].(*Interface); ok {
+			if err := checkImplementations(iface); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Defaults may refer to input objects defined after them, so they are
+	// checked once every type is built.
+	for _, name := range b.names {
+		if inputObject, ok := b.types[name].(*InputObject); ok {
+			if err := checkDefaults(inputObject); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	query, ok := b.types[operations["query"]].(*Object)
+	if !ok {
+		return nil, fmt.Errorf("query type %s is not a defined object", operations["query"])
+	}
+	var mutation *Object
+	if typ, ok := b.types[operations["mutation"]]; ok {
+		if mutation, ok = typ.(*Object); !ok {
+			return nil, fmt.Errorf("mutation type %s is not an object", operations["mutation"])
+		}
+	} else {
+		mutation = &Object{Name: "Mutation", Fields: make(map[string]*Field)}
+	}
+
+	return &Schema{
+		Query:    query,
+		Mutation: mutation,
+	}, nil
+}
+
+// BindResolver attaches resolve to the field fieldName of the object named
+// typeName, which must be reachable from the schema's query or mutation
+// type. Arguments are passed to resolve as a map[string]interface{} of
+// values like those generated by json.Unmarshal, so an Int is a float64.
+func (s *Schema) BindResolver(typeName, fieldName string, resolve Resolver) error {
+	object, ok := s.objects()[typeName]
+	if !ok {
+		return fmt.Errorf("unknown object %s", typeName)
+	}
+	field, ok := object.Fields[fieldName]
+	if !ok {
+		return fmt.Errorf("object %s has no field %s", typeName, fieldName)
+	}
+	field.Resolve = resolve
+	return nil
+}
+
+// objects returns the objects reachable from the schema's query and
+// mutation types by name.
+func (s *Schema) objects() map[string]*Object {
+	objects := make(map[string]*Object)
+	var visit func(typ Type)
+	visit = func(typ Type) {
+		switch typ := typ.(type) {
+		case *Object:
+			if _, ok := objects[typ.Name]; ok {
+				return
+			}
+			objects[typ.Name] = typ
+			for _, field := range typ.Fields {
+				visit(field.Type)
+			}
+		case *Union:
+			for _, object := range typ.Types {
+				visit(object)
+			}
+		case *Interface:
+			for _, field := range typ.Fields {
+				visit(field.Type)
+			}
+			for _, object := range typ.Types {
+				visit(object)
+			}
+		case *List:
+			visit(typ.Type)
+		case *NonNull:
+			visit(typ.Type)
+		}
+	}
+	visit(s.Query)
+	visit(s.Mutation)
+	visit(s.Subscription)
+	return objects
+}
+
+// sdlBuilder builds the types of a schema for ParseSDL.
+type sdlBuilder struct {
+	types       map[string]Type
+	definitions map[string]ast.Node
+	// names lists the declared types in order of definition.
+	names []string
+}
+
+func (b *sdlBuilder) declare(name string, definition ast.Node, typ Type) error {
+	if _, ok := b.types[name]; ok {
+		return fmt.Errorf("duplicate type %s", name)
+	}
+	b.types[name] = typ
+	b.definitions[name] = definition
+	b.names = append(b.names, name)
+	return nil
+}
+
+func (b *sdlBuilder) buildObject(object *Object, definition *ast.ObjectDefinition) error {
+	for _, named := range definition.Interfaces {
+		iface, ok := b.types[named.Name.Value].(*Interface)
+		if !ok {
+			return fmt.Errorf("object %s: %s is not a defined interface", object.Name, named.Name.Value)
+		}
+		iface.Types[object.Name] = object
+	}
+	return b.buildFields(object.Name, object.Fields, definition.Fields)
+}
+
+// buildFields adds the fields defined by definitions to fields, the fields
+// of the object or interface typeName.
+func (b *sdlBuilder) buildFields(typeName string, fields map[string]*Field, definitions []*ast.FieldDefinition) error {
+	for _, fieldDefinition := range definitions {
+		name := fieldDefinition.Name.Value
+		typ, err := b.resolveType(fieldDefinition.Type)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %s", typeName, name, err)
+		}
+		if !isOutputType(typ) {
+			return fmt.Errorf("%s.%s: %s is not an output type", typeName, name, typ)
+		}
+
+		field := &Field{
+			Type:             typ,
+			Args:             make(map[string]Type),
+			ArgDefaultValues: make(map[string]string),
+			Resolve:          sdlMapResolver(name),
+			Description:      sdlDescription(fieldDefinition.Description),
+		}
+		if reason, ok := sdlDeprecationReason(fieldDefinition.Directives); ok {
+			field.Deprecated = true
+			field.DeprecationReason = &reason
+		}
+		defaults := make(map[string]interface{})
+		for _, argument := range fieldDefinition.Arguments {
+			argName := argument.Name.Value
+			argType, err := b.resolveType(argument.Type)
+			if err != nil {
+				return fmt.Errorf("%s.%s(%s): %s", typeName, name, argName, err)
+			}
+			if !isInputType(argType) {
+				return fmt.Errorf("%s.%s(%s): %s is not an input type", typeName, name, argName, argType)
+			}
+			field.Args[argName] = argType
+			if reason, ok := sdlDeprecationReason(argument.Directives); ok {
+				if field.ArgDeprecationReasons == nil {
+					field.ArgDeprecationReasons = make(map[string]string)
+				}
+				field.ArgDeprecationReasons[argName] = reason
+			}
+
+			if argument.DefaultValue != nil {
+				value, err := valueToJson(argument.DefaultValue, nil)
+				if err != nil {
+					return fmt.Errorf("%s.%s(%s): %s", typeName, name, argName, err)
+				}
+				defaults[argName] = value
+				field.ArgDefaultValues[argName] = fmt.Sprint(printer.Print(argument.DefaultValue))
+			}
+		}
+		if len(defaults) > 0 {
+			field.ParseArguments = sdlParseArguments(field.Args, defaults)
+		}
+
+		fields[name] = field
+	}
+	return nil
+}
+
+func (b *sdlBuilder) buildUnion(union *Union, definition *ast.UnionDefinition) error {
+	for _, named := range definition.Types {
+		object, ok := b.types[named.Name.Value].(*Object)
+		if !ok {
+			return fmt.Errorf("union %s: member %s is not a defined object", union.Name, named.Name.Value)
+		}
+		union.Types[object.Name] = object
+	}
+	return nil
+}
+
+func (b *sdlBuilder) buildInputObject(inputObject *InputObject, definition *ast.InputObjectDefinition) error {
+	for _, fieldDefinition := range definition.Fields {
+		name := fieldDefinition.Name.Value
+		typ, err := b.resolveType(fieldDefinition.Type)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %s", inputObject.Name, name, err)
+		}
+		if !isInputType(typ) {
+			return fmt.Errorf("%s.%s: %s is not an input type", inputObject.Name, name, typ)
+		}
+		inputObject.InputFields[name] = typ
+		if fieldDefinition.DefaultValue != nil {
+			value, err := valueToJson(fieldDefinition.DefaultValue, nil)
+			if err != nil {
+				return fmt.Errorf("%s.%s: %s", inputObject.Name, name, err)
+			}
+			if inputObject.Defaults == nil {
+				inputObject.Defaults = make(map[string]interface{})
+				inputObject.DefaultValues = make(map[string]string)
+			}
+			inputObject.Defaults[name] = value
+			inputObject.DefaultValues[name] = fmt.Sprint(printer.Print(fieldDefinition.DefaultValue))
+		}
+		if reason, ok := sdlDeprecationReason(fieldDefinition.Directives); ok {
+			if inputObject.DeprecationReasons == nil {
+				inputObject.DeprecationReasons = make(map[string]string)
+			}
+			inputObject.DeprecationReasons[name] = reason
+		}
+	}
+	return nil
+}
+
+// checkImplementations checks that the objects implementing iface have every
+// field of iface.
+func checkImplementations(iface *Interface) error {
+	for _, object := range iface.Types {
+		for name := range iface.Fields {
+			if _, ok := object.Fields[name]; !ok {
+				return fmt.Errorf("object %s: missing field %s of interface %s", object.Name, name, iface.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// resolveType converts a type reference into the Type it names.
+func (b *sdlBuilder) resolveType(typ ast.Type) (Type, error) {
+	switch typ := typ.(type) {
+	case *ast.Named:
+		resolved, ok := b.types[typ.Name.Value]
+		if !ok {
+			return nil, fmt.Errorf("unknown type %s", typ.Name.Value)
+		}
+		return resolved, nil
+	case *ast.List:
+		inner, err := b.resolveType(typ.Type)
+		if err != nil {
+			return nil, err
+		}
+		return &List{Type: inner}, nil
+	case *ast.NonNull:
+		inner, err := b.resolveType(typ.Type)
+		if err != nil {
+			return nil, err
+		}
+		return &NonNull{Type: inner}, nil
+	default:
+		return nil, fmt.Errorf("unsupported type %v", typ)
+	}
+}
+
+// isInputType reports whether typ may be used for arguments.
+func isInputType(typ Type) bool {
+	switch typ := typ.(type) {
+	case *Scalar, *Enum, *InputObject:
+		return true
+	case *List:
+		return isInputType(typ.Type)
+	case *NonNull:
+		return isInputType(typ.Type)
+	default:
+		return false
+	}
+}
+
+// isOutputType reports whether typ may be used for fields.
+func isOutputType(typ Type) bool {
+	switch typ := typ.(type) {
+	case *Scalar, *Enum, *Object, *Union, *Interface:
+		return true
+	case *List:
+		return isOutputType(typ.Type)
+	case *NonNull:
+		return isOutputType(typ.Type)
+	default:
+		return false
+	}
+}
+
+// sdlMapResolver resolves the field name from a map[string]interface{}
+// source.
+func sdlMapResolver(name string) Resolver {
+	return func(ctx context.Context, source, args interface{}, selectionSet *SelectionSet) (interface{}, error) {
+		asMap, ok := source.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("no resolver bound for field %s and source is not a map", name)
+		}
+		return asMap[name], nil
+	}
+}
+
+// sdlResolveInterface resolves the values of iface from a
+// map[string]interface{} source whose "__typename" entry names the object.
+func sdlResolveInterface(iface *Interface) func(source interface{}) (*Object, error) {
+	return func(source interface{}) (*Object, error) {
+		asMap, ok := source.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("interface %s: source is not a map", iface.Name)
+		}
+		name, _ := asMap["__typename"].(string)
+		object, ok := iface.Types[name]
+		if !ok {
+			return nil, fmt.Errorf("interface %s: %q does not name an implementing object", iface.Name, name)
+		}
+		return object, nil
+	}
+}
+
+// sdlParseArguments parses arguments according to args, filling in defaults
+// for absent arguments.
+func sdlParseArguments(args map[string]Type, defaults map[string]interface{}) func(json interface{}) (interface{}, error) {
+	return func(json interface{}) (interface{}, error) {
+		asMap, ok := json.(map[string]interface{})
+		if json != nil && !ok {
+			return parseArguments(args, json)
+		}
+		return parseArguments(args, withDefaults(asMap, defaults))
+	}
+}
+
+// sdlDescription returns the text of a description, if any.
+func sdlDescription(description *ast.StringValue) string {
+	if description == nil {
+		return ""
+	}
+	return description.Value
+}
+
+// sdlDeprecationReason returns the reason argument of a @deprecated
+// directive, defaulting to "No longer supported", and whether there is one.
+func sdlDeprecationReason(directives []*ast.Directive) (string, bool) {
+	for _, directive := range directives {
+		if directive.Name.Value != "deprecated" {
+			continue
+		}
+		for _, argument := range directive.Arguments {
+			if reason, ok := argument.Value.(*ast.StringValue); argument.Name.Value == "reason" && ok {
+				return reason.Value, true
+			}
+		}
+		return "No longer supported", true
+	}
+	return "", false
+}
+
+// sdlSpecifiedByURL returns the url argument of a @specifiedBy directive, if
+// any.
+func sdlSpecifiedByURL(directives []*ast.Directive) string {
+	for _, directive := range directives {
+		if directive.Name.Value != "specifiedBy" {
+			continue
+		}
+		for _, argument := range directive.Arguments {
+			if url, ok := argument.Value.(*ast.StringValue); argument.Name.Value == "url" && ok {
+				return url.Value
+			}
+		}
+	}
+	return ""
+}
@@ -104,6 +104,13 @@ type SanitizedError interface {
 
 type SafeError struct {
 	message string
+	// code, if set, is reported in the "code" extension of the error.
+	code string
+	// reason, value, and limit, if set, categorize why a query was rejected,
+	// as returned by RejectionReason.
+	reason string
+	value  int64
+	limit  int64
 }
 
 type ClientError SafeError
@@ -132,8 +139,21 @@ func NewSafeError(format string, a ...interface{}) error {
 	return SafeError{message: fmt.Sprintf(format, a...)}
 }
 
+// newValidationError returns a ClientError for a request or query that failed
+// to parse or validate.
+func newValidationError(format string, a ...interface{}) error {
+	return ClientError{message: fmt.Sprintf(format, a...), code: ErrorCodeValidationFailed}
+}
+
+func (e ClientError) Extensions() map[string]interface{} {
+	if e.code == "" {
+		return map[string]interface{}{"code": ErrorCodeBadRequest}
+	}
+	return map[string]interface{}{"code": e.code}
+}
+
 func sanitizeError(err error) string {
-	if sanitized, ok := err.(SanitizedError); ok {
+	if sanitized, ok := ErrorCause(err).(SanitizedError); ok {
 		return sanitized.SanitizedError()
 	}
 	return "Internal server error"
@@ -216,7 +236,16 @@ func (c *conn) handleSubscribe(in *inEnvelope) error {
 		middlewares = append(middlewares, c.middlewares...)
 		middlewares = append(middlewares, func(input *ComputationInput, next MiddlewareNextFunc) *ComputationOutput {
 			output := next(input)
+			// Like the query received, a query rewritten by a middleware
+			// must be prepared before it runs.
+			if input.ParsedQuery != query {
+				if err := PrepareQuery(c.schema.Query, input.ParsedQuery.SelectionSet); err != nil {
+					output.Error = err
+					return output
+				}
+			}
 			output.Current, output.Error = e.Execute(input.Ctx, c.schema.Query, nil, input.ParsedQuery)
+			e.writeMetadata(output.Metadata)
 			return output
 		})
 
@@ -233,6 +262,9 @@ func (c *conn) handleSubscribe(in *inEnvelope) error {
 
 		output := RunMiddlewares(middlewares, computationInput)
 		current, err := output.Current, output.Error
+		if err == nil {
+			current, err = readReaders(current)
+		}
 
 		c.logger.FinishExecution(ctx, tags, time.Since(start))
 
@@ -247,7 +279,7 @@ func (c *conn) handleSubscribe(in *inEnvelope) error {
 				// without dumping the contents of the current computation cache.
 				// Note that we are swallowing the propagation of the error in this case,
 				// but we still log it.
-				if _, ok := err.(SanitizedError); !ok {
+				if _, ok := ErrorCause(err).(SanitizedError); !ok {
 					extraTags := map[string]string{"retry": "true"}
 					for k, v := range tags {
 						extraTags[k] = v
@@ -266,7 +298,7 @@ func (c *conn) handleSubscribe(in *inEnvelope) error {
 			})
 			go c.closeSubscription(id)
 
-			if _, ok := err.(SanitizedError); !ok {
+			if _, ok := ErrorCause(err).(SanitizedError); !ok {
 				c.logger.Error(ctx, err, tags)
 			}
 			return nil, err
@@ -344,6 +376,7 @@ func (c *conn) handleMutate(in *inEnvelope) error {
 		middlewares = append(middlewares, func(input *ComputationInput, next MiddlewareNextFunc) *ComputationOutput {
 			output := next(input)
 			output.Current, output.Error = e.Execute(input.Ctx, c.mutationSchema.Mutation, c.mutationSchema.Mutation, query)
+			e.writeMetadata(output.Metadata)
 			return output
 		})
 
@@ -360,6 +393,9 @@ func (c *conn) handleMutate(in *inEnvelope) error {
 
 		output := RunMiddlewares(middlewares, computationInput)
 		current, err := output.Current, output.Error
+		if err == nil {
+			current, err = readReaders(current)
+		}
 
 		c.logger.FinishExecution(ctx, tags, time.Since(start))
 
@@ -377,7 +413,7 @@ func (c *conn) handleMutate(in *inEnvelope) error {
 				return nil, err
 			}
 
-			if _, ok := err.(SanitizedError); !ok {
+			if _, ok := ErrorCause(err).(SanitizedError); !ok {
 				c.logger.Error(ctx, err, tags)
 			}
 			return nil, err
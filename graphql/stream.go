@@ -0,0 +1,234 @@
+package graphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"sort"
+	"unicode/utf8"
+)
+
+// A scalar field may resolve to an io.Reader, for large text that is
+// generated on the fly. The HTTP handler streams the reader's contents into
+// the response as a JSON string without holding them in memory; elsewhere,
+// such as in the WebSocket server, readers are read into strings in full.
+
+// containsReader reports whether value, as returned by the executor,
+// contains an io.Reader.
+func containsReader(value interface{}) bool {
+	switch value := value.(type) {
+	case io.Reader:
+		return true
+	case map[string]interface{}:
+		for _, v := range value {
+			if containsReader(v) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, v := range value {
+			if containsReader(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// readReaders replaces every io.Reader in value, as returned by the executor,
+// with a string of its contents.
+func readReaders(value interface{}) (interface{}, error) {
+	switch value := value.(type) {
+	case io.Reader:
+		bytes, err := ioutil.ReadAll(value)
+		if err != nil {
+			return nil, err
+		}
+		return string(bytes), nil
+	case map[string]interface{}:
+		for k, v := range value {
+			read, err := readReaders(v)
+			if err != nil {
+				return nil, err
+			}
+			value[k] = read
+		}
+	case []interface{}:
+		for i, v := range value {
+			read, err := readReaders(v)
+			if err != nil {
+				return nil, err
+			}
+			value[i] = read
+		}
+	}
+	return value, nil
+}
+
+// writeJSON writes value, as returned by the executor, to w as JSON. The
+// output matches json.Marshal, except that io.Readers are streamed as
+// strings.
+func writeJSON(w io.Writer, value interface{}) error {
+	switch value := value.(type) {
+	case io.Reader:
+		return writeJSONString(w, value)
+
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for k := range value {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		if _, err := io.WriteString(w, "{"); err != nil {
+			return err
+		}
+		for i, k := range keys {
+			if i > 0 {
+				if _, err := io.WriteString(w, ","); err != nil {
+					return err
+				}
+			}
+			if err := writeMarshaled(w, k); err != nil {
+				return err
+			}
+			if _, err := io.WriteString(w, ":"); err != nil {
+				return err
+			}
+			if err := writeJSON(w, value[k]); err != nil {
+				return err
+			}
+		}
+		_, err := io.WriteString(w, "}")
+		return err
+
+	case []interface{}:
+		if _, err := io.WriteString(w, "["); err != nil {
+			return err
+		}
+		for i, v := range value {
+			if i > 0 {
+				if _, err := io.WriteString(w, ","); err != nil {
+					return err
+				}
+			}
+			if err := writeJSON(w, v); err != nil {
+				return err
+			}
+		}
+		_, err := io.WriteString(w, "]")
+		return err
+
+	default:
+		return writeMarshaled(w, value)
+	}
+}
+
+// writeMarshaled writes value to w as marshaled by json.Marshal.
+func writeMarshaled(w io.Writer, value interface{}) error {
+	bytes, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(bytes)
+	return err
+}
+
+// writeJSONString writes the contents of r to w as a JSON string, escaped
+// like json.Marshal escapes strings, reading r a chunk at a time.
+func writeJSONString(w io.Writer, r io.Reader) error {
+	if _, err := io.WriteString(w, `"`); err != nil {
+		return err
+	}
+
+	buffer := make([]byte, 32*1024)
+	pending := 0
+	for {
+		n, readErr := r.Read(buffer[pending:])
+		n += pending
+
+		// Hold back a rune split across reads until the rest of it arrives.
+		complete := n
+		if readErr == nil {
+			complete = completeRunes(buffer[:n])
+		}
+		if complete > 0 {
+			escaped, err := json.Marshal(string(buffer[:complete]))
+			if err != nil {
+				return err
+			}
+			if _, err := w.Write(escaped[1 : len(escaped)-1]); err != nil {
+				return err
+			}
+		}
+		pending = copy(buffer, buffer[complete:n])
+
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+
+	_, err := io.WriteString(w, `"`)
+	return err
+}
+
+// completeRunes returns the length of the longest prefix of p that does not
+// end in an incomplete UTF-8 sequence.
+func completeRunes(p []byte) int {
+	// A UTF-8 sequence is at most utf8.UTFMax bytes long, so only the last
+	// few bytes can start an incomplete one.
+	for i := len(p) - 1; i >= 0 && i >= len(p)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(p[i]) {
+			if !utf8.FullRune(p[i:]) {
+				return i
+			}
+			break
+		}
+	}
+	return len(p)
+}
+
+// marshalResponse marshals response, reading any io.Readers in its data in
+// full.
+func marshalResponse(response httpResponse) ([]byte, error) {
+	if !containsReader(response.Data) {
+		return json.Marshal(response)
+	}
+
+	var buffer bytes.Buffer
+	if err := writeResponseJSON(&buffer, response); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// writeResponseJSON writes response to w, streaming any io.Readers in its
+// data.
+func writeResponseJSON(w io.Writer, response httpResponse) error {
+	if _, err := io.WriteString(w, `{"data":`); err != nil {
+		return err
+	}
+	if err := writeJSON(w, response.Data); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, `,"errors":`); err != nil {
+		return err
+	}
+	if err := writeMarshaled(w, response.Errors); err != nil {
+		return err
+	}
+	if len(response.Extensions) > 0 {
+		if _, err := io.WriteString(w, `,"extensions":`); err != nil {
+			return err
+		}
+		if err := writeMarshaled(w, response.Extensions); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "}")
+	return err
+}
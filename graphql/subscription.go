@@ -0,0 +1,388 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/samsarahq/thunder/batch"
+	"github.com/samsarahq/thunder/reactive"
+)
+
+// Subprotocols of the graphql-ws protocols spoken by HTTPSubHandler. The
+// current protocol names its frames subscribe, next, and complete; the
+// legacy protocol, from subscriptions-transport-ws, names them start, data,
+// and stop.
+const (
+	graphqlTransportWS = "graphql-transport-ws"
+	legacyGraphqlWS    = "graphql-ws"
+)
+
+// HTTPSubHandler returns a handler that serves GraphQL subscriptions, run
+// against schema.Subscription, over websockets speaking the graphql-ws
+// protocol. Both the current graphql-transport-ws subprotocol and the legacy
+// graphql-ws subprotocol are supported. As the spec requires, subscriptions
+// that select more than one root field are rejected.
+//
+// Every subscription is executed by its own reactive.Rerunner, and a new
+// result is pushed to the client whenever the subscription's dependencies
+// change and its result changes with them. Closing the socket stops every
+// subscription on it.
+//
+// The handler is configured with the same options as HTTPHandlerWithOptions;
+// options that only apply to HTTP responses, such as WithResponseCache, are
+// ignored.
+func HTTPSubHandler(schema *Schema, opts ...HTTPOption) http.Handler {
+	h := &httpHandler{
+		schema: schema,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return &subHandler{
+		httpHandler: h,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			Subprotocols:    []string{graphqlTransportWS, legacyGraphqlWS},
+			CheckOrigin: func(r *http.Request) bool {
+				return true
+			},
+		},
+	}
+}
+
+// WithSubscriptionRerunInterval sets the minimum interval between reruns of
+// a subscription served by HTTPSubHandler. It defaults to
+// DefaultMinRerunInterval.
+func WithSubscriptionRerunInterval(d time.Duration) HTTPOption {
+	return func(h *httpHandler) {
+		h.rerunInterval = d
+	}
+}
+
+type subHandler struct {
+	*httpHandler
+	upgrader websocket.Upgrader
+}
+
+func (h *subHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Websockets are long-lived, so they are not counted as in flight: only
+	// new connections are rejected while the handler is not ready.
+	if h.readiness != nil && !h.readiness.Ready() {
+		http.Error(w, "server is not ready", http.StatusServiceUnavailable)
+		return
+	}
+
+	socket, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("upgrader.Upgrade: %v", err)
+		return
+	}
+	defer socket.Close()
+
+	c := &subConn{
+		handler:       h.httpHandler,
+		socket:        socket,
+		ctx:           r.Context(),
+		legacy:        socket.Subprotocol() != graphqlTransportWS,
+		subscriptions: make(map[string]*reactive.Rerunner),
+	}
+	c.serve()
+}
+
+// subMessage is a frame of the graphql-ws protocol.
+type subMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// subOutMessage is a frame of the graphql-ws protocol sent to the client.
+type subOutMessage struct {
+	ID      string      `json:"id,omitempty"`
+	Type    string      `json:"type"`
+	Payload interface{} `json:"payload,omitempty"`
+}
+
+// subConn is a websocket connection served by HTTPSubHandler.
+type subConn struct {
+	handler *httpHandler
+	ctx     context.Context
+	// legacy is set if the client speaks the legacy graphql-ws subprotocol.
+	legacy bool
+
+	writeMu sync.Mutex
+	socket  *websocket.Conn
+
+	mu            sync.Mutex
+	initialized   bool
+	subscriptions map[string]*reactive.Rerunner
+}
+
+// serve handles the connection's frames until the socket is closed or the
+// client terminates the connection, and then stops every subscription.
+func (c *subConn) serve() {
+	defer c.closeSubscriptions()
+
+	for {
+		var message subMessage
+		if err := c.socket.ReadJSON(&message); err != nil {
+			if !isCloseError(err) {
+				log.Println("socket.ReadJSON:", err)
+			}
+			return
+		}
+
+		switch message.Type {
+		case "connection_init":
+			c.mu.Lock()
+			c.initialized = true
+			c.mu.Unlock()
+			c.write(subOutMessage{Type: "connection_ack"})
+
+		case "ping":
+			c.write(subOutMessage{Type: "pong"})
+
+		case "pong":
+
+		case "start", "subscribe":
+			if err := c.subscribe(&message); err != nil {
+				c.handler.reportError(c.ctx, err, nil)
+				c.writeError(message.ID, err)
+			}
+
+		case "stop", "complete":
+			c.closeSubscription(message.ID)
+
+		case "connection_terminate":
+			return
+
+		default:
+			c.writeError(message.ID, newValidationError("unknown message type %q", message.Type))
+		}
+	}
+}
+
+// write writes message to the socket, closing the socket if that fails.
+func (c *subConn) write(message subOutMessage) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if err := c.socket.WriteJSON(message); err != nil {
+		if !isCloseError(err) {
+			c.socket.Close()
+			log.Printf("socket.WriteJSON: %s\n", err)
+		}
+	}
+}
+
+// checkSingleRootField checks that selectionSet, the selections of a
+// subscription, selects exactly one root field, counting the fields of its
+// fragments by alias, as the GraphQL spec requires of subscriptions.
+func checkSingleRootField(selectionSet *SelectionSet) error {
+	aliases := make(map[string]bool)
+	collectAliases(selectionSet, aliases)
+	if len(aliases) != 1 {
+		return newValidationError("subscriptions must select a single root field, but selected %d", len(aliases))
+	}
+	return nil
+}
+
+// collectAliases adds the aliases of the selections in selectionSet and its
+// fragments to aliases.
+func collectAliases(selectionSet *SelectionSet, aliases map[string]bool) {
+	for _, selection := range selectionSet.Selections {
+		aliases[selection.Alias] = true
+	}
+	for _, fragment := range selectionSet.Fragments {
+		collectAliases(fragment.SelectionSet, aliases)
+	}
+}
+
+// writeError reports that the subscription id failed with err.
+func (c *subConn) writeError(id string, err error) {
+	errs := c.handler.filterErrors([]*GraphQLError{FormatError(err)})
+	if c.legacy {
+		var payload interface{}
+		if len(errs) > 0 {
+			payload = errs[0]
+		}
+		c.write(subOutMessage{ID: id, Type: "error", Payload: payload})
+		return
+	}
+	c.write(subOutMessage{ID: id, Type: "error", Payload: errs})
+}
+
+// subscribe starts the subscription requested by message.
+func (c *subConn) subscribe(message *subMessage) error {
+	var params httpPostBody
+	if err := json.Unmarshal(message.Payload, &params); err != nil {
+		return newValidationError("invalid subscription: %s", err)
+	}
+	if params.Variables == nil {
+		params.Variables = make(map[string]interface{})
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.initialized {
+		return newValidationError("connection not initialized")
+	}
+	if _, ok := c.subscriptions[message.ID]; ok {
+		return newValidationError("duplicate subscription")
+	}
+	if len(c.subscriptions)+1 > DefaultMaxSubscriptions {
+		return newValidationError("too many subscriptions")
+	}
+
+	if err := c.handler.checkVariables(params.Variables); err != nil {
+		return err
+	}
+
+	query, err := Parse(params.Query, params.Variables)
+	if err != nil {
+		return err
+	}
+	if params.OperationName != "" && params.OperationName != query.Name {
+		return newValidationError("unknown operation %q", params.OperationName)
+	}
+	if query.Kind != "subscription" {
+		return newValidationError("only subscriptions may be sent over a websocket")
+	}
+	schema := c.handler.schema.Subscription
+	if schema == nil {
+		return newValidationError("schema does not support subscriptions")
+	}
+	if err := c.handler.prepareOperation(schema, query); err != nil {
+		return err
+	}
+
+	rerunInterval := DefaultMinRerunInterval
+	if c.handler.rerunInterval > 0 {
+		rerunInterval = c.handler.rerunInterval
+	}
+
+	id := message.ID
+	e := Executor{
+		OnFieldResolved:       c.handler.fieldMetrics,
+		MaxTotalResolverTime:  c.handler.resolverTime,
+		MaxComplexity:         c.handler.maxComplexity,
+		ExpensiveFieldTimeout: c.handler.expensiveLimit,
+		Tracing:               c.handler.tracing,
+		PostProcess:           c.handler.postProcess,
+	}
+	var previous interface{}
+	initial := true
+	c.subscriptions[id] = reactive.NewRerunner(c.ctx, func(ctx context.Context) (interface{}, error) {
+		ctx = batch.WithBatching(ctx)
+
+		var middlewares []MiddlewareFunc
+		middlewares = append(middlewares, c.handler.middlewares...)
+		middlewares = append(middlewares, func(input *ComputationInput, next MiddlewareNextFunc) *ComputationOutput {
+			output := next(input)
+			if err := c.handler.prepareRewritten(schema, query, input); err != nil {
+				output.Error = err
+				return output
+			}
+			output.Current, output.Error = e.Execute(input.Ctx, schema, nil, input.ParsedQuery)
+			e.writeMetadata(output.Metadata)
+			return output
+		})
+
+		output := RunMiddlewares(middlewares, &ComputationInput{
+			Ctx:                  ctx,
+			Id:                   id,
+			ParsedQuery:          query,
+			Previous:             previous,
+			IsInitialComputation: initial,
+			Query:                params.Query,
+			Variables:            params.Variables,
+		})
+		current, err := output.Current, output.Error
+		fieldErrors := e.Errors()
+		if current != nil {
+			var readErr error
+			if current, readErr = readReaders(current); readErr != nil {
+				current, err = nil, readErr
+			}
+		}
+
+		if err != nil && current == nil {
+			if ErrorCause(err) == context.Canceled {
+				go c.closeSubscription(id)
+				return nil, err
+			}
+
+			c.handler.reportError(c.ctx, err, &params.Query)
+			if !initial {
+				// Keep the last result, and retry the computation without
+				// dumping its cache, as ServeJSONSocket does.
+				return nil, reactive.RetrySentinelError
+			}
+
+			c.writeError(id, err)
+			go c.closeSubscription(id)
+			return nil, err
+		}
+
+		if !initial && err == nil && reflect.DeepEqual(previous, current) {
+			return nil, nil
+		}
+		previous = current
+		initial = false
+
+		response := httpResponse{Data: current}
+		if err != nil {
+			// The subscription's failed fields were nulled out.
+			errs := fieldErrors
+			if len(errs) == 0 {
+				errs = []error{err}
+			}
+			formatted := make([]*GraphQLError, 0, len(errs))
+			for _, err := range errs {
+				c.handler.reportError(c.ctx, err, &params.Query)
+				formatted = append(formatted, FormatError(err))
+			}
+			response.Errors = c.handler.filterErrors(formatted)
+		}
+		response.Extensions = c.handler.responseExtensions(ctx, &e, output.Metadata)
+
+		frame := "next"
+		if c.legacy {
+			frame = "data"
+		}
+		c.write(subOutMessage{ID: id, Type: frame, Payload: response})
+		return nil, nil
+	}, rerunInterval)
+
+	return nil
+}
+
+// closeSubscription stops the subscription id, if it is running.
+func (c *subConn) closeSubscription(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if runner, ok := c.subscriptions[id]; ok {
+		runner.Stop()
+		delete(c.subscriptions, id)
+	}
+}
+
+// closeSubscriptions stops every subscription on the connection.
+func (c *subConn) closeSubscriptions() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for id, runner := range c.subscriptions {
+		runner.Stop()
+		delete(c.subscriptions, id)
+	}
+}
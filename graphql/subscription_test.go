@@ -0,0 +1,115 @@
+package graphql_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/kylelemons/godebug/pretty"
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/graphql/schemabuilder"
+	"github.com/samsarahq/thunder/reactive"
+)
+
+func TestHTTPSubHandler(t *testing.T) {
+	var count int64
+
+	schema := schemabuilder.NewSchema()
+	schema.Query()
+	schema.Mutation()
+	subscription := schema.Subscription()
+	subscription.FieldFunc("counter", func(ctx context.Context) int64 {
+		reactive.InvalidateAfter(ctx, 10*time.Millisecond)
+		return atomic.AddInt64(&count, 1)
+	})
+
+	server := httptest.NewServer(graphql.HTTPSubHandler(schema.MustBuild(), graphql.WithSubscriptionRerunInterval(time.Millisecond)))
+	defer server.Close()
+
+	dialer := websocket.Dialer{Subprotocols: []string{"graphql-transport-ws"}}
+	socket, _, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer socket.Close()
+
+	type message struct {
+		ID      string          `json:"id,omitempty"`
+		Type    string          `json:"type"`
+		Payload json.RawMessage `json:"payload,omitempty"`
+	}
+	read := func() message {
+		socket.SetReadDeadline(time.Now().Add(5 * time.Second))
+		var m message
+		if err := socket.ReadJSON(&m); err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+
+	if err := socket.WriteJSON(message{Type: "connection_init"}); err != nil {
+		t.Fatal(err)
+	}
+	if m := read(); m.Type != "connection_ack" {
+		t.Fatalf("expected connection_ack, but received %v", m)
+	}
+
+	if err := socket.WriteJSON(message{ID: "1", Type: "subscribe", Payload: json.RawMessage(`{"query": "subscription { counter }"}`)}); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{`{"data":{"counter":1},"errors":null}`, `{"data":{"counter":2},"errors":null}`} {
+		m := read()
+		if diff := pretty.Compare(m, message{ID: "1", Type: "next", Payload: json.RawMessage(expected)}); diff != "" {
+			t.Errorf("expected update to match, but received %s", diff)
+		}
+	}
+
+	if err := socket.WriteJSON(message{ID: "2", Type: "subscribe", Payload: json.RawMessage(`{"query": "{ counter }"}`)}); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		// Skip the updates to the first subscription.
+		if m := read(); m.ID == "2" {
+			if diff := pretty.Compare(m, message{ID: "2", Type: "error", Payload: json.RawMessage(`[{"message":"only subscriptions may be sent over a websocket","extensions":{"code":"GRAPHQL_VALIDATION_FAILED"}}]`)}); diff != "" {
+				t.Errorf("expected error to match, but received %s", diff)
+			}
+			break
+		}
+	}
+
+	if err := socket.WriteJSON(message{ID: "3", Type: "subscribe", Payload: json.RawMessage(`{"query": "subscription { counter other: counter }"}`)}); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		if m := read(); m.ID == "3" {
+			if diff := pretty.Compare(m, message{ID: "3", Type: "error", Payload: json.RawMessage(`[{"message":"subscriptions must select a single root field, but selected 2","extensions":{"code":"GRAPHQL_VALIDATION_FAILED"}}]`)}); diff != "" {
+				t.Errorf("expected error to match, but received %s", diff)
+			}
+			break
+		}
+	}
+}
+
+func TestHTTPSubscriptionOverPost(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	schema.Query()
+	schema.Mutation()
+	schema.Subscription().FieldFunc("counter", func() int64 { return 1 })
+
+	req, err := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query": "subscription { counter }"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	graphql.HTTPHandler(schema.MustBuild()).ServeHTTP(rr, req)
+
+	if diff := pretty.Compare(rr.Body.String(), "{\"data\":null,\"errors\":[{\"message\":\"subscriptions must be sent over a websocket\",\"extensions\":{\"code\":\"GRAPHQL_VALIDATION_FAILED\"}}]}\n"); diff != "" {
+		t.Errorf("expected response to match, but received %s", diff)
+	}
+}
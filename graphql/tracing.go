@@ -0,0 +1,92 @@
+package graphql
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Trace is a trace of the execution of a query in the Apollo Tracing format,
+// recorded when Executor.Tracing is set. Offsets and durations are in
+// nanoseconds.
+type Trace struct {
+	Version   int            `json:"version"`
+	StartTime time.Time      `json:"startTime"`
+	EndTime   time.Time      `json:"endTime"`
+	Duration  int64          `json:"duration"`
+	Execution TraceExecution `json:"execution"`
+}
+
+// TraceExecution holds the traces of a query's resolvers, ordered by when
+// they started.
+type TraceExecution struct {
+	Resolvers []*ResolverTrace `json:"resolvers"`
+}
+
+// ResolverTrace is the trace of a single resolver. Path holds the aliases of
+// the fields leading to the resolved field as strings, and list indices as
+// ints.
+type ResolverTrace struct {
+	Path        []interface{} `json:"path"`
+	ParentType  string        `json:"parentType"`
+	FieldName   string        `json:"fieldName"`
+	ReturnType  string        `json:"returnType"`
+	StartOffset int64         `json:"startOffset"`
+	Duration    int64         `json:"duration"`
+}
+
+// tracer records the resolvers of a query as they run. Resolvers run
+// concurrently, so it is safe to use from multiple goroutines.
+type tracer struct {
+	start time.Time
+
+	mu        sync.Mutex
+	resolvers []*ResolverTrace
+}
+
+// record records a resolver of field selected as fieldName on parentType,
+// which ran at ctx's path from start for d.
+func (t *tracer) record(ctx context.Context, parentType, fieldName string, field *Field, start time.Time, d time.Duration) {
+	keys := PathFromContext(ctx)
+	path := make([]interface{}, len(keys))
+	for i, key := range keys {
+		// Aliases can't start with a digit, so numeric keys are list indices.
+		if index, err := strconv.Atoi(key); err == nil {
+			path[i] = index
+		} else {
+			path[i] = key
+		}
+	}
+
+	resolver := &ResolverTrace{
+		Path:        path,
+		ParentType:  parentType,
+		FieldName:   fieldName,
+		ReturnType:  field.Type.String(),
+		StartOffset: int64(start.Sub(t.start)),
+		Duration:    int64(d),
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.resolvers = append(t.resolvers, resolver)
+}
+
+// finish returns the trace of the query, which ended now.
+func (t *tracer) finish() *Trace {
+	t.mu.Lock()
+	resolvers := append([]*ResolverTrace(nil), t.resolvers...)
+	t.mu.Unlock()
+
+	sort.SliceStable(resolvers, func(i, j int) bool { return resolvers[i].StartOffset < resolvers[j].StartOffset })
+	end := time.Now()
+	return &Trace{
+		Version:   1,
+		StartTime: t.start,
+		EndTime:   end,
+		Duration:  int64(end.Sub(t.start)),
+		Execution: TraceExecution{Resolvers: resolvers},
+	}
+}
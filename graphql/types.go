@@ -3,6 +3,9 @@ package graphql
 import (
 	"context"
 	"fmt"
+	"reflect"
+	"strings"
+	"time"
 )
 
 // Type represents a GraphQL type, and should be either an Object, a Scalar,
@@ -17,9 +20,31 @@ type Type interface {
 
 // Scalar is a leaf value.  A custom "Unwrapper" can be attached to the scalar
 // so it can have a custom unwrapping (if nil we will use the default unwrapper).
+// A custom "ParseValue" can be attached to convert argument values from their
+// JSON form (if nil, argument values are passed through as-is). Scalars
+// without either fall back to the funcs registered with RegisterScalar.
 type Scalar struct {
-	Type      string
-	Unwrapper func(interface{}) (interface{}, error)
+	Type        string
+	Description string
+	Unwrapper   func(interface{}) (interface{}, error)
+	ParseValue  func(interface{}) (interface{}, error)
+
+	// SpecifiedByURL, if set, links to the specification of the scalar's
+	// format. It is advertised in introspection as specifiedByURL.
+	SpecifiedByURL string
+}
+
+// Marshaler is implemented by Go types that convert themselves into a scalar
+// output value. The executor uses MarshalGraphQL for values of a Scalar
+// without an Unwrapper.
+type Marshaler interface {
+	MarshalGraphQL() (interface{}, error)
+}
+
+// Unmarshaler is implemented by Go types that parse themselves from a JSON
+// argument value.
+type Unmarshaler interface {
+	UnmarshalGraphQL(value interface{}) error
 }
 
 func (s *Scalar) isType() {}
@@ -30,13 +55,54 @@ func (s *Scalar) String() string {
 
 // Enum is a leaf value
 type Enum struct {
-	Type       string
-	Values     []string
-	ReverseMap map[interface{}]string
+	Type        string
+	Description string
+	Values      []string
+	ReverseMap  map[interface{}]string
+
+	// CaseInsensitive lets arguments name a value ignoring case, for clients
+	// that don't follow the spec. Exact matches are still preferred.
+	CaseInsensitive bool
+
+	// ValueDescriptions documents values in introspection and PrintSchema.
+	ValueDescriptions map[string]string
+	// DeprecationReasons holds the reasons that values are deprecated, for
+	// introspection. A value is deprecated if it has an entry, even an empty
+	// one.
+	DeprecationReasons map[string]string
 }
 
 func (e *Enum) isType() {}
 
+// MatchValue returns the value of e named by value. If e is CaseInsensitive
+// and no value matches exactly, value may match one value ignoring case; a
+// value that matches several is ambiguous.
+func (e *Enum) MatchValue(value string) (string, error) {
+	for _, enumValue := range e.Values {
+		if enumValue == value {
+			return enumValue, nil
+		}
+	}
+
+	if e.CaseInsensitive {
+		var matches []string
+		for _, enumValue := range e.Values {
+			if strings.EqualFold(enumValue, value) {
+				matches = append(matches, enumValue)
+			}
+		}
+		switch len(matches) {
+		case 1:
+			return matches[0], nil
+		case 0:
+		default:
+			return "", fmt.Errorf("ambiguous enum value %v", value)
+		}
+	}
+
+	return "", fmt.Errorf("unknown enum value %v", value)
+}
+
 func (e *Enum) String() string {
 	return e.Type
 }
@@ -73,6 +139,18 @@ func (l *List) String() string {
 type InputObject struct {
 	Name        string
 	InputFields map[string]Type
+	// DefaultValues holds the default values of input fields, as GraphQL
+	// literals, for introspection.
+	DefaultValues map[string]string
+	// Defaults holds the values of input fields omitted from an argument,
+	// like those generated by json.Unmarshal, for fields that don't provide
+	// their own ParseArguments. Schema.Validate checks them against the
+	// fields' types.
+	Defaults map[string]interface{}
+	// DeprecationReasons holds the reasons that input fields are deprecated,
+	// for introspection. A field is deprecated if it has an entry, even an
+	// empty one.
+	DeprecationReasons map[string]string
 }
 
 func (io *InputObject) isType() {}
@@ -93,10 +171,22 @@ func (n *NonNull) String() string {
 }
 
 // Union is a option between multiple types
+//
+// By default, a union's value is a struct with a field named after each
+// member type, one of which is set. If ResolveType is set, it instead
+// returns the name of the member type of a value, such as from a
+// discriminator field, and the value itself is resolved as that member.
 type Union struct {
 	Name        string
 	Description string
 	Types       map[string]*Object
+	ResolveType func(source interface{}) (string, error)
+
+	// Members maps the Go types of values to the names of the members of
+	// Types they resolve to, for unions without ResolveType whose resolvers
+	// return member values rather than a struct with a field per member.
+	// Pointers to the types match as well.
+	Members map[reflect.Type]string
 }
 
 func (*Union) isType() {}
@@ -105,6 +195,25 @@ func (u *Union) String() string {
 	return u.Name
 }
 
+// Interface is a set of fields shared by multiple object types
+//
+// A field of interface type resolves to a value of one of the objects in
+// Types, picked by ResolveType. Fields selected on the interface are
+// executed by the concrete object, which must have a field of the same name.
+type Interface struct {
+	Name        string
+	Description string
+	Fields      map[string]*Field
+	Types       map[string]*Object
+	ResolveType func(source interface{}) (*Object, error)
+}
+
+func (*Interface) isType() {}
+
+func (i *Interface) String() string {
+	return i.Name
+}
+
 // Verify *Scalar, *Object, *List, *InputObject, and *NonNull implement Type
 var _ Type = &Scalar{}
 var _ Type = &Object{}
@@ -113,25 +222,58 @@ var _ Type = &InputObject{}
 var _ Type = &NonNull{}
 var _ Type = &Enum{}
 var _ Type = &Union{}
+var _ Type = &Interface{}
 
 // A Resolver calculates the value of a field of an object
 type Resolver func(ctx context.Context, source, args interface{}, selectionSet *SelectionSet) (interface{}, error)
 
 // Field knows how to compute field values of an Object
 //
-// Fields are responsible for computing their value themselves.
+// Fields are responsible for computing their value themselves. If
+// ParseArguments is nil, arguments are parsed according to Args into a
+// map[string]interface{}.
 type Field struct {
 	Resolve        Resolver
 	Type           Type
 	Args           map[string]Type
 	ParseArguments func(json interface{}) (interface{}, error)
 
+	// Description documents the field in introspection and PrintSchema.
+	Description string
+
+	// Deprecated marks the field as deprecated in introspection and
+	// PrintSchema, for DeprecationReason if it is set. A deprecated field
+	// can still be queried.
+	Deprecated        bool
+	DeprecationReason *string
+
+	// ArgDefaultValues holds the default values of arguments, as GraphQL
+	// literals, for introspection.
+	ArgDefaultValues map[string]string
+	// ArgDeprecationReasons holds the reasons that arguments are deprecated,
+	// for introspection. An argument is deprecated if it has an entry, even
+	// an empty one.
+	ArgDeprecationReasons map[string]string
+
 	Expensive bool
+
+	// Estimate, if set, estimates the cost of resolving the field for
+	// Executor.MaxComplexity. Fields without an Estimate cost 1.
+	Estimate Estimator
+
+	// Timeout, if non-zero, bounds how long the resolver may run. The resolver's
+	// context is canceled after Timeout, and the field fails with a ClientError
+	// with the code ErrorCodeTimeout at its path, leaving the rest of the query
+	// intact.
+	Timeout time.Duration
 }
 
 type Schema struct {
 	Query    Type
 	Mutation Type
+	// Subscription, if set, is the root of subscriptions, which are served
+	// over websockets by HTTPSubHandler.
+	Subscription Type
 }
 
 // SelectionSet represents a core GraphQL query
@@ -174,9 +316,9 @@ type Selection struct {
 	Args         interface{}
 	SelectionSet *SelectionSet
 
-	// The parsed flag is used to make sure the args for this Selection are only
-	// parsed once.
-	parsed bool
+	// mergedFrom is the selection whose args a selection created by Flatten
+	// shares, so that the args are parsed once per execution.
+	mergedFrom *Selection
 }
 
 // A Fragment represents a reusable part of a GraphQL query
@@ -3,6 +3,7 @@ package graphql_test
 import (
 	"context"
 	"log"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -297,3 +298,227 @@ func TestUnionStruct(t *testing.T) {
 		t.Errorf("expected did not match result: %s", d)
 	}
 }
+
+func TestUnionResolveType(t *testing.T) {
+	schema, err := graphql.ParseSDL(`
+		type User { name: String! }
+		type Post { title: String! }
+		union Result = User | Post
+		type Query { result(id: Int!): Result }
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := []map[string]interface{}{
+		{"kind": "User", "name": "alice"},
+		{"kind": "Post", "title": "hello"},
+	}
+	if err := schema.BindResolver("Query", "result", func(ctx context.Context, source, args interface{}, selectionSet *graphql.SelectionSet) (interface{}, error) {
+		return results[int(args.(map[string]interface{})["id"].(float64))], nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	union := schema.Query.(*graphql.Object).Fields["result"].Type.(*graphql.Union)
+	union.ResolveType = func(source interface{}) (string, error) {
+		return source.(map[string]interface{})["kind"].(string), nil
+	}
+
+	q := graphql.MustParse(`{
+		user: result(id: 0) { __typename ... on User { name } ... on Post { title } }
+		post: result(id: 1) { __typename ... on User { name } ... on Post { title } }
+	}`, nil)
+	if err := graphql.PrepareQuery(schema.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+	e := graphql.Executor{}
+	result, err := e.Execute(context.Background(), schema.Query, nil, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d := pretty.Compare(internal.AsJSON(result), internal.ParseJSON(`{
+		"user": {"__typename": "User", "name": "alice"},
+		"post": {"__typename": "Post", "title": "hello"}
+	}`)); d != "" {
+		t.Errorf("expected result to match, but received %s", d)
+	}
+}
+
+func TestUnionOverlappingFields(t *testing.T) {
+	type Owner struct {
+		Name string
+		Age  int64
+	}
+	type Dog struct {
+		Sound string
+		Owner *Owner
+	}
+	type Cat struct {
+		Sound int64
+		Lives int64
+	}
+	type Pet struct {
+		schemabuilder.Union
+
+		*Dog
+		*Cat
+	}
+
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("pets", func() []*Pet {
+		return []*Pet{
+			{Dog: &Dog{Sound: "woof", Owner: &Owner{Name: "alice", Age: 30}}},
+			{Cat: &Cat{Sound: 3, Lives: 9}},
+		}
+	})
+	builtSchema := schema.MustBuild()
+
+	q := graphql.MustParse(`{
+		pets {
+			... on Dog { sound owner { name } }
+			... on Cat { sound }
+			... on Dog { owner { age } noise: sound }
+			... on Cat { noise: lives }
+		}
+	}`, nil)
+	if err := graphql.PrepareQuery(builtSchema.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+
+	e := graphql.Executor{}
+	result, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := pretty.Compare(internal.AsJSON(result), internal.ParseJSON(`{"pets": [
+		{"sound": "woof", "noise": "woof", "owner": {"name": "alice", "age": 30}},
+		{"sound": 3, "noise": 9}
+	]}`)); d != "" {
+		t.Errorf("expected did not match result: %s", d)
+	}
+
+	// Fragments on the same member still conflict.
+	_, err = graphql.Parse(`{ pets { ... on Cat { noise: sound } ... on Cat { noise: lives } } }`, nil)
+	if err == nil || err.Error() != "same alias with different name" {
+		t.Errorf("expected conflict, got %v", err)
+	}
+
+	// Fragments on an object all apply to it, whatever their type condition,
+	// and a fragment on an interface applies along with one on its object.
+	resolve := func(ctx context.Context, source, args interface{}, selectionSet *graphql.SelectionSet) (interface{}, error) {
+		return "x", nil
+	}
+	user := &graphql.Object{Name: "User", Fields: map[string]*graphql.Field{
+		"id":   {Type: &graphql.Scalar{Type: "string"}, Resolve: resolve},
+		"name": {Type: &graphql.Scalar{Type: "string"}, Resolve: resolve},
+	}}
+	robot := &graphql.Object{Name: "Robot", Fields: map[string]*graphql.Field{
+		"id":     {Type: &graphql.Scalar{Type: "string"}, Resolve: resolve},
+		"serial": {Type: &graphql.Scalar{Type: "string"}, Resolve: resolve},
+	}}
+	node := &graphql.Interface{
+		Name:   "Node",
+		Fields: map[string]*graphql.Field{"id": {Type: &graphql.Scalar{Type: "string"}}},
+		Types:  map[string]*graphql.Object{"User": user, "Robot": robot},
+		ResolveType: func(source interface{}) (*graphql.Object, error) {
+			return user, nil
+		},
+	}
+	root := &graphql.Object{Name: "Query", Fields: map[string]*graphql.Field{
+		"me":   {Type: user, Resolve: resolve},
+		"node": {Type: node, Resolve: resolve},
+	}}
+
+	for _, c := range []struct {
+		query string
+		err   string
+	}{
+		{`{ me { ... on User { x: id } ... on Other { x: name } } }`, "same alias with different name"},
+		{`{ node { ... on Node { x: id } ... on User { x: name } } }`, "same alias with different name"},
+		{`{ node { ... on User { x: name } ... on Robot { x: serial } } }`, ""},
+	} {
+		q, err := graphql.Parse(c.query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = graphql.PrepareQuery(root, q.SelectionSet)
+		if c.err == "" && err != nil {
+			t.Errorf("%s: expected no error, got %v", c.query, err)
+		}
+		if c.err != "" && (err == nil || err.Error() != c.err) {
+			t.Errorf("%s: expected %q, got %v", c.query, c.err, err)
+		}
+	}
+}
+
+func TestUnionMembers(t *testing.T) {
+	type User struct{ Name string }
+	type Post struct{ Title string }
+
+	user, err := graphql.ObjectFromStruct("User", User{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	post, err := graphql.ObjectFromStruct("Post", Post{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	union := &graphql.Union{
+		Name:    "Result",
+		Types:   map[string]*graphql.Object{"User": user, "Post": post},
+		Members: map[reflect.Type]string{reflect.TypeOf(User{}): "User", reflect.TypeOf(Post{}): "Post"},
+	}
+
+	query := &graphql.Object{Name: "Query", Fields: map[string]*graphql.Field{
+		"results": {
+			Type: &graphql.List{Type: union},
+			Resolve: func(ctx context.Context, source, args interface{}, selectionSet *graphql.SelectionSet) (interface{}, error) {
+				return []interface{}{&User{Name: "alice"}, Post{Title: "hello"}, nil}, nil
+			},
+		},
+		"bad": {
+			Type: union,
+			Resolve: func(ctx context.Context, source, args interface{}, selectionSet *graphql.SelectionSet) (interface{}, error) {
+				return "alice", nil
+			},
+		},
+	}}
+
+	q := graphql.MustParse(`
+		{ results { __typename ...UserFields ...PostFields } }
+		fragment UserFields on User { name }
+		fragment PostFields on Post { title }
+	`, nil)
+	if err := graphql.PrepareQuery(query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+	e := graphql.Executor{}
+	result, err := e.Execute(context.Background(), query, nil, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := pretty.Compare(internal.AsJSON(result), internal.ParseJSON(`{
+		"results": [
+			{"__typename": "User", "name": "alice"},
+			{"__typename": "Post", "title": "hello"},
+			null
+		]
+	}`)); d != "" {
+		t.Errorf("expected result to match, but received %s", d)
+	}
+
+	q = graphql.MustParse(`{ bad { __typename } }`, nil)
+	if err := graphql.PrepareQuery(query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+	e.Execute(context.Background(), query, nil, q)
+	errs := e.Errors()
+	if len(errs) != 1 || errs[0].Error() != "bad: value of type string is not a member of union Result" {
+		t.Fatalf("expected the unmatched value to fail, got %v", errs)
+	}
+	if _, ok := graphql.ErrorCause(errs[0]).(graphql.SafeError); !ok {
+		t.Errorf("expected a SafeError, got %T", graphql.ErrorCause(errs[0]))
+	}
+}
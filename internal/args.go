@@ -0,0 +1,36 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ArgsError combines the errors of parsing the arguments or input fields of
+// an object into one error, so that clients can fix them all in one go.
+// invalid holds the errors of arguments by name, and unknown the names of
+// arguments that don't exist. They are reported in a stable order. ArgsError
+// returns nil if there are none.
+func ArgsError(invalid map[string]error, unknown []string) error {
+	names := make([]string, 0, len(invalid))
+	for name := range invalid {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	errs := make([]string, 0, len(invalid)+len(unknown))
+	for _, name := range names {
+		errs = append(errs, fmt.Sprintf("%s: %s", name, invalid[name]))
+	}
+	unknown = append([]string(nil), unknown...)
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		errs = append(errs, fmt.Sprintf("unknown arg %s", name))
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(errs, "; "))
+}